@@ -0,0 +1,51 @@
+// Command imdb loads local copies of the IMDb non-commercial datasets into
+// the database configured by the same environment as the server.
+//
+//	go run ./cmd/imdb -dir ~/imdb -types movie -min-votes 1000 -from-year 1990
+//
+// An interrupted import resumes where it stopped when run again with the
+// same filters; -restart starts from the beginning, which is also how a
+// finished import is refreshed from newer files.
+package main
+
+import (
+	"FilmCollection/db"
+	"FilmCollection/imdb"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func main() {
+	var options imdb.Options
+	flag.StringVar(&options.Dir, "dir", ".", "directory with the dataset files")
+	types := flag.String("types", "movie", "comma separated title types to import, empty for all")
+	flag.IntVar(&options.MinVotes, "min-votes", 0, "minimum number of votes of a title")
+	flag.IntVar(&options.FromYear, "from-year", 0, "earliest start year of a title")
+	flag.IntVar(&options.ToYear, "to-year", 0, "latest start year of a title")
+	flag.IntVar(&options.BatchSize, "batch-size", 5000, "records per transaction")
+	restart := flag.Bool("restart", false, "forget the progress of earlier runs")
+	flag.Parse()
+	if *types != "" {
+		options.TitleTypes = strings.Split(*types, ",")
+	}
+	if db.Conn == nil {
+		fmt.Fprintln(os.Stderr, "imdb: no database connection")
+		os.Exit(1)
+	}
+	if *restart {
+		err := db.ResetImdbImport()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "imdb:", err)
+			os.Exit(1)
+		}
+	}
+	err := imdb.Run(options, func(format string, args ...interface{}) {
+		fmt.Fprintf(os.Stderr, "imdb: "+format+"\n", args...)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "imdb:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,68 @@
+// Command import loads films, actors and cast links from CSV or JSON files
+// into the database configured by the same environment as the server.
+//
+//	go run ./cmd/import [-dry-run] [-batch-size N] [-format csv|json] file...
+//
+// The report of every file is printed as JSON. The exit status is 1 when a
+// row failed.
+package main
+
+import (
+	"FilmCollection/db"
+	"FilmCollection/importer"
+	"FilmCollection/structs"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "only report what would change")
+	batchSize := flag.Int("batch-size", 0, "rows per transaction, 0 for one transaction per file")
+	format := flag.String("format", "", "csv or json, guessed from the file extension by default")
+	flag.Parse()
+	if flag.NArg() == 0 || *batchSize < 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if db.Conn == nil {
+		fmt.Fprintln(os.Stderr, "import: no database connection")
+		os.Exit(1)
+	}
+	failed := false
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	for _, name := range flag.Args() {
+		report, err := importFile(name, *format, db.ImportOptions{DryRun: *dryRun, BatchSize: *batchSize})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "import: %s: %v\n", name, err)
+			os.Exit(1)
+		}
+		failed = failed || report.Failed > 0
+		err = out.Encode(report)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "import:", err)
+			os.Exit(1)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func importFile(name, format string, options db.ImportOptions) (report structs.ImportReport, err error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return report, err
+	}
+	defer file.Close()
+	if format == "" {
+		format = importer.FormatOf(name)
+	}
+	rows, err := importer.Parse(file, format)
+	if err != nil {
+		return report, err
+	}
+	return db.Import(rows, options)
+}
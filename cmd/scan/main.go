@@ -0,0 +1,53 @@
+// Command scan records the video files of media directories with their
+// films, like the server does with MEDIA_DIRS and MEDIA_SCAN_INTERVAL.
+//
+//	go run ./cmd/scan [-interval 1h] [dir...]
+//
+// Without directories MEDIA_DIRS is scanned. Without -interval it scans
+// once and prints the report as JSON.
+package main
+
+import (
+	"FilmCollection/db"
+	"FilmCollection/library"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+func main() {
+	interval := flag.Duration("interval", 0, "scan again after this long, 0 to scan once")
+	flag.Parse()
+	dirs := flag.Args()
+	if len(dirs) == 0 {
+		dirs = library.Dirs
+	}
+	if len(dirs) == 0 {
+		fmt.Fprintln(os.Stderr, "scan: no directories given and MEDIA_DIRS is empty")
+		os.Exit(2)
+	}
+	if db.Conn == nil {
+		fmt.Fprintln(os.Stderr, "scan: no database connection")
+		os.Exit(1)
+	}
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	for {
+		report, err := library.Scan(append([]string(nil), dirs...))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "scan:", err)
+			os.Exit(1)
+		}
+		err = out.Encode(report)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "scan:", err)
+			os.Exit(1)
+		}
+		if *interval <= 0 {
+			return
+		}
+		time.Sleep(*interval)
+	}
+}
@@ -0,0 +1,26 @@
+// Command taste rebuilds the taste profiles behind /me/recommendations from
+// the users' ratings and diaries and the films' current actors, genres and
+// tags. Run it after bulk changes to the catalogue, such as imports or tag
+// merges, which the incrementally updated profiles do not follow.
+//
+//	go run ./cmd/taste
+package main
+
+import (
+	"FilmCollection/db"
+	"fmt"
+	"os"
+)
+
+func main() {
+	if db.Conn == nil {
+		fmt.Fprintln(os.Stderr, "taste: no database connection")
+		os.Exit(1)
+	}
+	users, err := db.RebuildTasteProfiles()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "taste:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("rebuilt the taste profiles of %d users\n", users)
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"FilmCollection/structs"
+	"time"
+)
+
+// GetActorAnalytics describes the career of an actor with at most
+// topCostars co-stars. It returns pgx.ErrNoRows for unknown actors. Dates
+// stored as 0001-01-01 are unknown: films without a release date are left
+// out of the yearly counts and ages are null.
+func GetActorAnalytics(id, topCostars int) (structs.ActorAnalytics, error) {
+	var analytics structs.ActorAnalytics
+	var birthDate *time.Time
+	err := Conn.QueryRow("SELECT id, name, COALESCE(gender, ''), NULLIF(birth_date, '0001-01-01') FROM actors WHERE id = $1", id).
+		Scan(&analytics.Actor.Id, &analytics.Actor.Name, &analytics.Actor.Gender, &birthDate)
+	if err != nil {
+		return analytics, err
+	}
+	if birthDate != nil {
+		analytics.Actor.BirthDate.Time = *birthDate
+	}
+
+	rows, err := Conn.Query(`SELECT DISTINCT f.id, f.name, NULLIF(f.release_date, '0001-01-01'), f.rating,
+			date_part('year', age(NULLIF(f.release_date, '0001-01-01'), NULLIF(a.birth_date, '0001-01-01')))::int
+		FROM moviecast mc JOIN films f ON f.id = mc.filmid JOIN actors a ON a.id = mc.actorid
+		WHERE mc.actorid = $1 ORDER BY NULLIF(f.release_date, '0001-01-01') NULLS LAST, f.id`, id)
+	if err != nil {
+		return analytics, err
+	}
+	analytics.Filmography = []structs.CareerFilm{}
+	analytics.FilmsPerYear = []structs.YearCount{}
+	// Unrated films are left out of the average, as in GetActorLeaderboard.
+	ratingSum, rated := 0, 0
+	for rows.Next() {
+		var film structs.CareerFilm
+		// Release date, rating and age are NULL when unknown.
+		var releaseDate *time.Time
+		err = rows.Scan(&film.FilmID, &film.Name, &releaseDate, &film.Rating, &film.AgeAtRelease)
+		if err != nil {
+			rows.Close()
+			return analytics, err
+		}
+		if releaseDate != nil {
+			film.ReleaseDate.Time = *releaseDate
+		}
+		analytics.Filmography = append(analytics.Filmography, film)
+		if film.Rating != nil {
+			ratingSum += *film.Rating
+			rated++
+		}
+		if releaseDate == nil {
+			continue
+		}
+		// Films are ordered by release date, so years arrive in order.
+		year := releaseDate.Year()
+		last := len(analytics.FilmsPerYear) - 1
+		if last >= 0 && analytics.FilmsPerYear[last].Year == year {
+			analytics.FilmsPerYear[last].Count++
+		} else {
+			analytics.FilmsPerYear = append(analytics.FilmsPerYear, structs.YearCount{Year: year, Count: 1})
+		}
+		if analytics.FirstRelease.IsZero() {
+			analytics.FirstRelease = film.ReleaseDate
+		}
+		analytics.LastRelease = film.ReleaseDate
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return analytics, err
+	}
+	analytics.Films = len(analytics.Filmography)
+	if rated > 0 {
+		analytics.AverageRating = float64(ratingSum) / float64(rated)
+	}
+	if !analytics.FirstRelease.IsZero() {
+		analytics.CareerYears = analytics.LastRelease.Year() - analytics.FirstRelease.Year()
+	}
+
+	analytics.TopCostars, err = GetCostars(id, topCostars)
+	return analytics, err
+}
+
+// GetActorLeaderboard ranks the actors with at least minFilms rated films
+// by the average rating of those films.
+func GetActorLeaderboard(minFilms, limit int) ([]structs.ActorRating, error) {
+	rows, err := Conn.Query(`SELECT a.id, a.name, count(*), avg(f.rating)::float8
+		FROM (SELECT DISTINCT actorid, filmid FROM moviecast) mc
+		JOIN actors a ON a.id = mc.actorid JOIN films f ON f.id = mc.filmid
+		WHERE f.rating IS NOT NULL
+		GROUP BY a.id, a.name HAVING count(*) >= $1
+		ORDER BY avg(f.rating) DESC, count(*) DESC, a.name, a.id LIMIT $2`, minFilms, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	leaderboard := []structs.ActorRating{}
+	for rows.Next() {
+		var actor structs.ActorRating
+		err = rows.Scan(&actor.ActorID, &actor.Name, &actor.Films, &actor.AverageRating)
+		if err != nil {
+			return nil, err
+		}
+		leaderboard = append(leaderboard, actor)
+	}
+	return leaderboard, rows.Err()
+}
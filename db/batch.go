@@ -0,0 +1,221 @@
+package db
+
+import (
+	"FilmCollection/structs"
+	"github.com/jackc/pgx"
+	"time"
+)
+
+const creditColumns = "filmid, actorid, COALESCE(character, ''), COALESCE(billing, 0), COALESCE(credit_type, '')"
+
+// CastByFilm returns the credits of every film in filmIDs, in billing order,
+// with a single query.
+func CastByFilm(filmIDs []int) (map[int][]structs.Credit, error) {
+	return credits("SELECT "+creditColumns+" FROM moviecast WHERE filmid = ANY($1) ORDER BY filmid, billing NULLS LAST, actorid", filmIDs, true)
+}
+
+// FilmographyByActor returns the credits of every actor in actorIDs, in
+// billing order, with a single query.
+func FilmographyByActor(actorIDs []int) (map[int][]structs.Credit, error) {
+	return credits("SELECT "+creditColumns+" FROM moviecast WHERE actorid = ANY($1) ORDER BY actorid, billing NULLS LAST, filmid", actorIDs, false)
+}
+
+func credits(sql string, ids []int, byFilm bool) (map[int][]structs.Credit, error) {
+	result := make(map[int][]structs.Credit, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+	rows, err := Conn.Query(sql, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var credit structs.Credit
+		err = rows.Scan(&credit.FilmID, &credit.ActorID, &credit.Character, &credit.Billing, &credit.CreditType)
+		if err != nil {
+			return nil, err
+		}
+		key := credit.ActorID
+		if byFilm {
+			key = credit.FilmID
+		}
+		result[key] = append(result[key], credit)
+	}
+	return result, rows.Err()
+}
+
+// execer is implemented by *pgx.ConnPool, *pgx.Conn and *pgx.Tx.
+type execer interface {
+	Exec(sql string, arguments ...interface{}) (pgx.CommandTag, error)
+}
+
+// AddCredit links an actor to a film. Empty role fields are stored as NULL.
+func AddCredit(filmID int, credit structs.Credit) error {
+	return addCredit(Conn, filmID, credit)
+}
+
+// SetFilmActors makes actorIDs the cast of a film. Actors who stay keep their
+// character, billing and credit type; zero ids are ignored.
+func SetFilmActors(filmID int, actorIDs []int) error {
+	ids := append([]int{}, actorIDs...)
+	tx, err := Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec("DELETE FROM moviecast WHERE filmid = $1 AND actorid <> ALL($2)", filmID, ids)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO moviecast (filmid, actorid)
+		SELECT DISTINCT $1::int, a FROM unnest($2::int[]) a
+		WHERE a <> 0 AND NOT EXISTS (SELECT 1 FROM moviecast WHERE filmid = $1 AND actorid = a)`, filmID, ids)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func addCredit(conn execer, filmID int, credit structs.Credit) error {
+	_, err := conn.Exec(`INSERT INTO moviecast (filmid, actorid, character, billing, credit_type)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, 0), NULLIF($5, ''))`,
+		filmID, credit.ActorID, credit.Character, credit.Billing, credit.CreditType)
+	return err
+}
+
+const filmColumns = "films.id, films.name, films.description, films.rating, films.release_date, COALESCE(films.runtime, 0)"
+
+// scanFilms reads rows selected with filmColumns and closes them.
+func scanFilms(rows *pgx.Rows) ([]structs.Film, error) {
+	defer rows.Close()
+	var films []structs.Film
+	for rows.Next() {
+		var film structs.Film
+		var releaseDate time.Time
+		err := rows.Scan(&film.Id, &film.Name, &film.Description, &film.Rating, &releaseDate, &film.Runtime)
+		if err != nil {
+			return nil, err
+		}
+		film.ReleaseDate = structs.Date{Time: releaseDate}
+		films = append(films, film)
+	}
+	return films, rows.Err()
+}
+
+// fillFilmRelations loads everything linked to films with one query per relation.
+func fillFilmRelations(films []structs.Film) error {
+	for _, fill := range []func([]structs.Film) error{fillCast, fillCrew, fillGenres, fillTags, fillCommunityRatings} {
+		if err := fill(films); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetFilmsByIDs loads films together with their relations in a constant
+// number of queries. Ids that don't exist are missing from the result.
+func GetFilmsByIDs(ids []int) (map[int]structs.Film, error) {
+	films := make(map[int]structs.Film, len(ids))
+	if len(ids) == 0 {
+		return films, nil
+	}
+	rows, err := Conn.Query("SELECT "+filmColumns+" FROM films WHERE id = ANY($1)", ids)
+	if err != nil {
+		return nil, err
+	}
+	list, err := scanFilms(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err = fillFilmRelations(list); err != nil {
+		return nil, err
+	}
+	for _, film := range list {
+		films[film.Id] = film
+	}
+	return films, nil
+}
+
+const actorColumns = "actors.id, actors.name, actors.gender, actors.birth_date"
+
+// scanActors reads rows selected with actorColumns and closes them.
+func scanActors(rows *pgx.Rows) ([]structs.Actor, error) {
+	defer rows.Close()
+	var actors []structs.Actor
+	for rows.Next() {
+		var actor structs.Actor
+		var birthDate time.Time
+		err := rows.Scan(&actor.Id, &actor.Name, &actor.Gender, &birthDate)
+		if err != nil {
+			return nil, err
+		}
+		actor.BirthDate = structs.Date{Time: birthDate}
+		actors = append(actors, actor)
+	}
+	return actors, rows.Err()
+}
+
+// GetActorsByIDs loads actors together with their filmography in two queries.
+// Ids that don't exist are missing from the result.
+func GetActorsByIDs(ids []int) (map[int]structs.Actor, error) {
+	actors := make(map[int]structs.Actor, len(ids))
+	if len(ids) == 0 {
+		return actors, nil
+	}
+	rows, err := Conn.Query("SELECT "+actorColumns+" FROM actors WHERE id = ANY($1)", ids)
+	if err != nil {
+		return nil, err
+	}
+	list, err := scanActors(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err = fillFilmography(list); err != nil {
+		return nil, err
+	}
+	for _, actor := range list {
+		actors[actor.Id] = actor
+	}
+	return actors, nil
+}
+
+// fillCast sets Cast and Actors of every film in place with a single query.
+func fillCast(films []structs.Film) error {
+	ids := make([]int, len(films))
+	for i, film := range films {
+		ids[i] = film.Id
+	}
+	cast, err := CastByFilm(ids)
+	if err != nil {
+		return err
+	}
+	for i := range films {
+		films[i].Cast = cast[films[i].Id]
+		films[i].Actors = nil
+		for _, credit := range films[i].Cast {
+			films[i].Actors = append(films[i].Actors, credit.ActorID)
+		}
+	}
+	return nil
+}
+
+// fillFilmography sets Roles and Films of every actor in place with a single query.
+func fillFilmography(actors []structs.Actor) error {
+	ids := make([]int, len(actors))
+	for i, actor := range actors {
+		ids[i] = actor.Id
+	}
+	filmography, err := FilmographyByActor(ids)
+	if err != nil {
+		return err
+	}
+	for i := range actors {
+		actors[i].Roles = filmography[actors[i].Id]
+		actors[i].Films = nil
+		for _, credit := range actors[i].Roles {
+			actors[i].Films = append(actors[i].Films, credit.FilmID)
+		}
+	}
+	return nil
+}
@@ -7,7 +7,17 @@ import (
 	"strconv"
 )
 
-var Conn *pgx.Conn
+// Conn is a pool of connections shared by the handlers. Every query and
+// transaction takes a connection of its own from it, so requests running
+// at the same time never share one.
+var Conn *pgx.ConnPool
+
+// maxConnections is the size of the Conn pool.
+const maxConnections = 10
+
+// config is kept to open connections of their own for long reads and
+// writes, see Export.
+var config pgx.ConnConfig
 
 func init() {
 	var err error
@@ -16,7 +26,7 @@ func init() {
 		panic("Failed to get port: " + err.Error())
 	}
 
-	config := pgx.ConnConfig{
+	config = pgx.ConnConfig{
 		User:     os.Getenv("POSTGRES_USER"),
 		Password: os.Getenv("POSTGRES_PASSWORD"),
 		Database: os.Getenv("POSTGRES_DB"),
@@ -24,7 +34,7 @@ func init() {
 		Host:     os.Getenv("POSTGRES_HOST"),
 	}
 
-	Conn, err = pgx.Connect(config)
+	Conn, err = pgx.NewConnPool(pgx.ConnPoolConfig{ConnConfig: config, MaxConnections: maxConnections})
 	if err != nil {
 		slog.Error("Failed to connect to the database: ", "error", err)
 		return
@@ -35,4 +45,9 @@ func init() {
 		slog.Error("Failed to init tables: ", "error", err)
 		return
 	}
+
+	err = backfillTaste()
+	if err != nil {
+		slog.Error("Failed to backfill taste profiles: ", "error", err)
+	}
 }
@@ -2,7 +2,7 @@ package db
 
 import (
 	"FilmCollection/structs"
-	"time"
+	"github.com/jackc/pgx"
 )
 
 func initTables() error {
@@ -16,6 +16,10 @@ func initTables() error {
 	if err != nil {
 		return err
 	}
+	_, err = Conn.Exec(`ALTER TABLE Films ADD COLUMN IF NOT EXISTS runtime integer CHECK (runtime > 0);`)
+	if err != nil {
+		return err
+	}
 	_, err = Conn.Exec(`CREATE TABLE IF NOT EXISTS Actors(
 		id    integer PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
 		name  varchar(30) NOT NULL,
@@ -34,6 +38,58 @@ func initTables() error {
 	if err != nil {
 		return err
 	}
+	_, err = Conn.Exec(`ALTER TABLE MovieCast
+		ADD COLUMN IF NOT EXISTS character varchar(100),
+		ADD COLUMN IF NOT EXISTS billing integer,
+		ADD COLUMN IF NOT EXISTS credit_type varchar(20) CHECK (credit_type IN ('lead', 'supporting', 'cameo', 'voice'))
+	;`)
+	if err != nil {
+		return err
+	}
+	// Actors holds every person; crew members are linked through Crew the
+	// same way actors are linked through MovieCast.
+	_, err = Conn.Exec(`CREATE TABLE IF NOT EXISTS Crew(
+		FilmID integer NOT NULL,
+		PersonID integer NOT NULL,
+		department varchar(20) NOT NULL CHECK (department IN ('directing', 'writing', 'production', 'music', 'camera', 'editing', 'sound', 'art')),
+		job varchar(50),
+		FOREIGN KEY (FilmID) REFERENCES Films (id),
+		FOREIGN KEY (PersonID) REFERENCES Actors (id)
+	);`)
+	if err != nil {
+		return err
+	}
+	_, err = Conn.Exec(`CREATE TABLE IF NOT EXISTS Genres(
+		id    integer PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+		name  varchar(50) NOT NULL UNIQUE,
+		parent_id integer REFERENCES Genres (id)
+	);`)
+	if err != nil {
+		return err
+	}
+	_, err = Conn.Exec(`CREATE TABLE IF NOT EXISTS FilmGenres(
+		FilmID integer NOT NULL REFERENCES Films (id) ON DELETE CASCADE,
+		GenreID integer NOT NULL REFERENCES Genres (id),
+		PRIMARY KEY (FilmID, GenreID)
+	);`)
+	if err != nil {
+		return err
+	}
+	_, err = Conn.Exec(`CREATE TABLE IF NOT EXISTS Tags(
+		id    integer PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+		name  varchar(50) NOT NULL UNIQUE
+	);`)
+	if err != nil {
+		return err
+	}
+	_, err = Conn.Exec(`CREATE TABLE IF NOT EXISTS FilmTags(
+		FilmID integer NOT NULL REFERENCES Films (id) ON DELETE CASCADE,
+		TagID integer NOT NULL REFERENCES Tags (id) ON DELETE CASCADE,
+		PRIMARY KEY (FilmID, TagID)
+	);`)
+	if err != nil {
+		return err
+	}
 	_, err = Conn.Exec(`CREATE TABLE IF NOT EXISTS Users(
 		id 	integer PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
 		login  varchar(50) NOT NULL,
@@ -43,62 +99,180 @@ func initTables() error {
 	if err != nil {
 		return err
 	}
+	_, err = Conn.Exec(`CREATE TABLE IF NOT EXISTS UserRatings(
+		UserID integer NOT NULL REFERENCES Users (id) ON DELETE CASCADE,
+		FilmID integer NOT NULL REFERENCES Films (id) ON DELETE CASCADE,
+		rating integer NOT NULL CHECK (rating >= 1 AND rating <= 10),
+		rated_at timestamptz NOT NULL DEFAULT now(),
+		PRIMARY KEY (UserID, FilmID)
+	);`)
+	if err != nil {
+		return err
+	}
+	_, err = Conn.Exec(`CREATE TABLE IF NOT EXISTS Reviews(
+		id    integer PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+		UserID integer NOT NULL REFERENCES Users (id) ON DELETE CASCADE,
+		FilmID integer NOT NULL REFERENCES Films (id) ON DELETE CASCADE,
+		body  varchar(10000) NOT NULL,
+		created_at timestamptz NOT NULL DEFAULT now(),
+		updated_at timestamptz NOT NULL DEFAULT now(),
+		UNIQUE (UserID, FilmID)
+	);`)
+	if err != nil {
+		return err
+	}
+	_, err = Conn.Exec(`ALTER TABLE Reviews
+		ADD COLUMN IF NOT EXISTS status varchar(20) NOT NULL DEFAULT 'published' CHECK (status IN ('pending', 'published', 'rejected', 'hidden')),
+		ADD COLUMN IF NOT EXISTS moderation_note varchar(200)
+	;`)
+	if err != nil {
+		return err
+	}
+	_, err = Conn.Exec(`CREATE TABLE IF NOT EXISTS ReviewReports(
+		id    integer PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+		ReviewID integer NOT NULL REFERENCES Reviews (id) ON DELETE CASCADE,
+		UserID integer NOT NULL REFERENCES Users (id) ON DELETE CASCADE,
+		reason varchar(500) NOT NULL,
+		resolved boolean NOT NULL DEFAULT false,
+		created_at timestamptz NOT NULL DEFAULT now(),
+		UNIQUE (ReviewID, UserID)
+	);`)
+	if err != nil {
+		return err
+	}
+	_, err = Conn.Exec(`CREATE TABLE IF NOT EXISTS Lists(
+		id    integer PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+		UserID integer NOT NULL REFERENCES Users (id) ON DELETE CASCADE,
+		name  varchar(150) NOT NULL,
+		description varchar(1000) NOT NULL DEFAULT '',
+		public boolean NOT NULL DEFAULT false,
+		share_token varchar(64) UNIQUE,
+		is_watchlist boolean NOT NULL DEFAULT false,
+		created_at timestamptz NOT NULL DEFAULT now(),
+		updated_at timestamptz NOT NULL DEFAULT now()
+	);`)
+	if err != nil {
+		return err
+	}
+	_, err = Conn.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS lists_one_watchlist ON Lists (UserID) WHERE is_watchlist;`)
+	if err != nil {
+		return err
+	}
+	_, err = Conn.Exec(`CREATE TABLE IF NOT EXISTS ListItems(
+		ListID integer NOT NULL REFERENCES Lists (id) ON DELETE CASCADE,
+		FilmID integer NOT NULL REFERENCES Films (id) ON DELETE CASCADE,
+		position integer NOT NULL,
+		note varchar(1000) NOT NULL DEFAULT '',
+		added_at timestamptz NOT NULL DEFAULT now(),
+		PRIMARY KEY (ListID, FilmID)
+	);`)
+	if err != nil {
+		return err
+	}
+	_, err = Conn.Exec(`CREATE TABLE IF NOT EXISTS Diary(
+		id    integer PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+		UserID integer NOT NULL REFERENCES Users (id) ON DELETE CASCADE,
+		FilmID integer NOT NULL REFERENCES Films (id) ON DELETE CASCADE,
+		watched_on date NOT NULL,
+		rating integer CHECK (rating >= 1 AND rating <= 10),
+		notes varchar(5000) NOT NULL DEFAULT '',
+		rewatch boolean NOT NULL DEFAULT false,
+		created_at timestamptz NOT NULL DEFAULT now()
+	);`)
+	if err != nil {
+		return err
+	}
+	_, err = Conn.Exec(`CREATE INDEX IF NOT EXISTS diary_user_watched_on ON Diary (UserID, watched_on);`)
+	if err != nil {
+		return err
+	}
+	_, err = Conn.Exec(`CREATE TABLE IF NOT EXISTS TasteSignals(
+		UserID integer NOT NULL REFERENCES Users (id) ON DELETE CASCADE,
+		FilmID integer NOT NULL REFERENCES Films (id) ON DELETE CASCADE,
+		signal double precision NOT NULL,
+		PRIMARY KEY (UserID, FilmID)
+	);`)
+	if err != nil {
+		return err
+	}
+	_, err = Conn.Exec(`CREATE TABLE IF NOT EXISTS TasteProfile(
+		UserID integer NOT NULL REFERENCES Users (id) ON DELETE CASCADE,
+		kind varchar(10) NOT NULL,
+		FeatureID integer NOT NULL,
+		weight double precision NOT NULL,
+		PRIMARY KEY (UserID, kind, FeatureID)
+	);`)
+	if err != nil {
+		return err
+	}
+	_, err = Conn.Exec(`ALTER TABLE Films ADD COLUMN IF NOT EXISTS imdb_id varchar(12) UNIQUE;`)
+	if err != nil {
+		return err
+	}
+	_, err = Conn.Exec(`ALTER TABLE Actors ADD COLUMN IF NOT EXISTS imdb_id varchar(12) UNIQUE;`)
+	if err != nil {
+		return err
+	}
+	// ImportProgress records how far each stage of a resumable import got.
+	_, err = Conn.Exec(`CREATE TABLE IF NOT EXISTS ImportProgress(
+		source varchar(20) NOT NULL,
+		stage varchar(20) NOT NULL,
+		options varchar(500) NOT NULL,
+		line bigint NOT NULL DEFAULT 0,
+		done boolean NOT NULL DEFAULT false,
+		updated_at timestamptz NOT NULL DEFAULT now(),
+		PRIMARY KEY (source, stage)
+	);`)
+	if err != nil {
+		return err
+	}
+	_, err = Conn.Exec(`CREATE TABLE IF NOT EXISTS ImdbPrincipals(
+		tconst varchar(12) NOT NULL,
+		nconst varchar(12) NOT NULL,
+		ordering integer NOT NULL,
+		category varchar(20) NOT NULL,
+		character varchar(100) NOT NULL DEFAULT '',
+		PRIMARY KEY (tconst, nconst, ordering)
+	);`)
+	if err != nil {
+		return err
+	}
+	// FilmFiles records the video files the library scanner found for films.
+	_, err = Conn.Exec(`CREATE TABLE IF NOT EXISTS FilmFiles(
+		path varchar(4096) PRIMARY KEY,
+		FilmID integer NOT NULL REFERENCES Films (id) ON DELETE CASCADE,
+		size bigint NOT NULL,
+		modified_at timestamptz NOT NULL,
+		scanned_at timestamptz NOT NULL DEFAULT now()
+	);`)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
+// GetActorByID returns the actor with its filmography or pgx.ErrNoRows.
 func GetActorByID(id int) (structs.Actor, error) {
-	q := Conn.QueryRow("SELECT * FROM actors WHERE id = $1", id)
-	var actor structs.Actor
-	var birthDate time.Time
-	err := q.Scan(&actor.Id, &actor.Name, &actor.Gender, &birthDate)
-	actor.BirthDate = structs.Date{birthDate}
+	actors, err := GetActorsByIDs([]int{id})
 	if err != nil {
 		return structs.Actor{}, err
 	}
-	rows, err := Conn.Query("SELECT filmid FROM moviecast WHERE actorid = $1", id)
-	if err != nil {
-		return structs.Actor{}, err
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var filmID int
-		err = rows.Scan(&filmID)
-		if err != nil {
-			return structs.Actor{}, err
-		}
-		actor.Films = append(actor.Films, filmID)
+	actor, ok := actors[id]
+	if !ok {
+		return structs.Actor{}, pgx.ErrNoRows
 	}
 	return actor, nil
 }
 
+// GetFilmByID returns the film with its cast or pgx.ErrNoRows.
 func GetFilmByID(id int) (structs.Film, error) {
-	q := Conn.QueryRow("SELECT * FROM films WHERE id = $1", id)
-	var film structs.Film
-	var releaseDate time.Time
-	err := q.Scan(&film.Id, &film.Name, &film.Description, &film.Rating, &releaseDate)
-	film.ReleaseDate = structs.Date{releaseDate}
+	films, err := GetFilmsByIDs([]int{id})
 	if err != nil {
 		return structs.Film{}, err
 	}
-	rows, err := Conn.Query("SELECT actorid FROM moviecast WHERE filmid = $1", id)
-	if err != nil {
-		return structs.Film{}, err
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var actorID int
-		err = rows.Scan(&actorID)
-		if err != nil {
-			return structs.Film{}, err
-		}
-		film.Actors = append(film.Actors, actorID)
-	}
-	if err != nil {
-		return structs.Film{}, err
+	film, ok := films[id]
+	if !ok {
+		return structs.Film{}, pgx.ErrNoRows
 	}
 	return film, nil
 }
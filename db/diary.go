@@ -0,0 +1,126 @@
+package db
+
+import (
+	"FilmCollection/structs"
+	"errors"
+	"github.com/jackc/pgx"
+	"time"
+)
+
+// ErrDiaryEntryNotFound is returned when a diary entry does not exist or
+// belongs to another user.
+var ErrDiaryEntryNotFound = errors.New("diary entry not found")
+
+// DiaryFilter selects diary entries of a user. Nil bounds are open.
+type DiaryFilter struct {
+	UserID int
+	From   *time.Time
+	To     *time.Time
+	FilmID *int
+}
+
+// AddDiaryEntry logs a watch and returns the id of the entry. It returns
+// ErrFilmNotFound for unknown films. Like the other diary changes it updates
+// the user's taste profile.
+func AddDiaryEntry(entry structs.DiaryEntry) (int, error) {
+	tx, err := Conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	var id int
+	err = tx.QueryRow(`INSERT INTO diary (userid, filmid, watched_on, rating, notes, rewatch)
+		SELECT $1, id, $3, NULLIF($4, 0), $5, $6 FROM films WHERE id = $2 RETURNING id`,
+		entry.UserID, entry.FilmID, entry.WatchedOn.Time, entry.Rating, entry.Notes, entry.Rewatch).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrFilmNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	err = refreshTaste(tx, entry.UserID, entry.FilmID)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// UpdateDiaryEntry replaces the date, rating, notes and rewatch flag of an
+// entry of entry.UserID.
+func UpdateDiaryEntry(entry structs.DiaryEntry) error {
+	tx, err := Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var filmID int
+	err = tx.QueryRow(`UPDATE diary SET watched_on = $3, rating = NULLIF($4, 0), notes = $5, rewatch = $6
+		WHERE id = $1 AND userid = $2 RETURNING filmid`,
+		entry.Id, entry.UserID, entry.WatchedOn.Time, entry.Rating, entry.Notes, entry.Rewatch).Scan(&filmID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrDiaryEntryNotFound
+	}
+	if err != nil {
+		return err
+	}
+	err = refreshTaste(tx, entry.UserID, filmID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteDiaryEntry removes an entry of the user.
+func DeleteDiaryEntry(id, userID int) error {
+	tx, err := Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var filmID int
+	err = tx.QueryRow("DELETE FROM diary WHERE id = $1 AND userid = $2 RETURNING filmid", id, userID).Scan(&filmID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrDiaryEntryNotFound
+	}
+	if err != nil {
+		return err
+	}
+	err = refreshTaste(tx, userID, filmID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetDiary returns the entries matching filter, most recent watch first.
+func GetDiary(filter DiaryFilter, limit int) ([]structs.DiaryEntry, error) {
+	var q query
+	q.and("d.userid = ?", filter.UserID)
+	if filter.From != nil {
+		q.and("d.watched_on >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		q.and("d.watched_on <= ?", *filter.To)
+	}
+	if filter.FilmID != nil {
+		q.and("d.filmid = ?", *filter.FilmID)
+	}
+	sql := `SELECT d.id, d.userid, d.filmid, f.name, d.watched_on, COALESCE(d.rating, 0), d.notes, d.rewatch, d.created_at
+		FROM diary d JOIN films f ON f.id = d.filmid` + q.whereSQL() +
+		" ORDER BY d.watched_on DESC, d.id DESC LIMIT " + q.arg(limit)
+	rows, err := Conn.Query(sql, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []structs.DiaryEntry{}
+	for rows.Next() {
+		var entry structs.DiaryEntry
+		err = rows.Scan(&entry.Id, &entry.UserID, &entry.FilmID, &entry.FilmName, &entry.WatchedOn.Time, &entry.Rating, &entry.Notes, &entry.Rewatch, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
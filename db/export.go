@@ -0,0 +1,98 @@
+package db
+
+import (
+	"FilmCollection/structs"
+	"context"
+	"github.com/jackc/pgx"
+	"time"
+)
+
+// ExportSink receives the records of Export: every film, then every actor,
+// both ordered by id, then every cast link ordered by film.
+type ExportSink interface {
+	Film(structs.FilmRecord) error
+	Actor(structs.Person) error
+	Credit(structs.Credit) error
+}
+
+// Export streams the whole catalogue into sink one row at a time, so memory
+// use does not grow with the catalogue. All rows come from one repeatable
+// read snapshot on a connection of its own, which keeps the Conn pool free
+// for other requests while the export runs. Errors returned by sink stop the export.
+func Export(sink ExportSink) error {
+	conn, err := pgx.Connect(config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	tx, err := conn.BeginEx(context.Background(), &pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT id, name, COALESCE(description, ''), COALESCE(rating, 0), release_date, COALESCE(runtime, 0) FROM films ORDER BY id")
+	if err != nil {
+		return err
+	}
+	err = exportRows(rows, func() error {
+		var film structs.FilmRecord
+		var releaseDate *time.Time
+		err := rows.Scan(&film.Id, &film.Name, &film.Description, &film.Rating, &releaseDate, &film.Runtime)
+		if err != nil {
+			return err
+		}
+		if releaseDate != nil {
+			film.ReleaseDate.Time = *releaseDate
+		}
+		return sink.Film(film)
+	})
+	if err != nil {
+		return err
+	}
+
+	rows, err = tx.Query("SELECT id, name, COALESCE(gender, ''), birth_date FROM actors ORDER BY id")
+	if err != nil {
+		return err
+	}
+	err = exportRows(rows, func() error {
+		var actor structs.Person
+		var birthDate *time.Time
+		err := rows.Scan(&actor.Id, &actor.Name, &actor.Gender, &birthDate)
+		if err != nil {
+			return err
+		}
+		if birthDate != nil {
+			actor.BirthDate.Time = *birthDate
+		}
+		return sink.Actor(actor)
+	})
+	if err != nil {
+		return err
+	}
+
+	rows, err = tx.Query("SELECT " + creditColumns + " FROM moviecast ORDER BY filmid, actorid, billing NULLS LAST")
+	if err != nil {
+		return err
+	}
+	return exportRows(rows, func() error {
+		var credit structs.Credit
+		err := rows.Scan(&credit.FilmID, &credit.ActorID, &credit.Character, &credit.Billing, &credit.CreditType)
+		if err != nil {
+			return err
+		}
+		return sink.Credit(credit)
+	})
+}
+
+// exportRows calls scan for every row and closes rows.
+func exportRows(rows *pgx.Rows, scan func() error) error {
+	defer rows.Close()
+	for rows.Next() {
+		err := scan()
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
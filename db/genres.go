@@ -0,0 +1,135 @@
+package db
+
+import (
+	"FilmCollection/structs"
+	"errors"
+)
+
+// ErrGenreInUse is returned by DeleteGenre for genres linked to films or
+// having subgenres.
+var ErrGenreInUse = errors.New("genre is in use")
+
+// ErrGenreCycle is returned by UpdateGenre when the new parent is the genre
+// itself or one of its subgenres.
+var ErrGenreCycle = errors.New("genre cannot be its own ancestor")
+
+// genreSubtreeSQL selects the ids of the genres in ? and all their subgenres.
+const genreSubtreeSQL = `WITH RECURSIVE subtree(id) AS (
+		SELECT id FROM genres WHERE id = ANY(?)
+		UNION SELECT g.id FROM genres g JOIN subtree ON g.parent_id = subtree.id
+	) SELECT id FROM subtree`
+
+// GenresByFilm returns the genres of every film in filmIDs with a single query.
+func GenresByFilm(filmIDs []int) (map[int][]structs.Genre, error) {
+	result := make(map[int][]structs.Genre, len(filmIDs))
+	if len(filmIDs) == 0 {
+		return result, nil
+	}
+	rows, err := Conn.Query(`SELECT fg.filmid, g.id, g.name, COALESCE(g.parent_id, 0)
+		FROM filmgenres fg JOIN genres g ON g.id = fg.genreid
+		WHERE fg.filmid = ANY($1) ORDER BY fg.filmid, g.name`, filmIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var filmID int
+		var genre structs.Genre
+		err = rows.Scan(&filmID, &genre.Id, &genre.Name, &genre.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		result[filmID] = append(result[filmID], genre)
+	}
+	return result, rows.Err()
+}
+
+// fillGenres sets Genres of every film in place with a single query.
+func fillGenres(films []structs.Film) error {
+	ids := make([]int, len(films))
+	for i, film := range films {
+		ids[i] = film.Id
+	}
+	genres, err := GenresByFilm(ids)
+	if err != nil {
+		return err
+	}
+	for i := range films {
+		films[i].Genres = genres[films[i].Id]
+	}
+	return nil
+}
+
+// GetGenres returns the whole taxonomy ordered by name.
+func GetGenres() ([]structs.Genre, error) {
+	rows, err := Conn.Query("SELECT id, name, COALESCE(parent_id, 0) FROM genres ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	genres := []structs.Genre{}
+	for rows.Next() {
+		var genre structs.Genre
+		err = rows.Scan(&genre.Id, &genre.Name, &genre.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		genres = append(genres, genre)
+	}
+	return genres, rows.Err()
+}
+
+// AddGenre stores genre and returns its id.
+func AddGenre(genre structs.Genre) (int, error) {
+	var id int
+	err := Conn.QueryRow("INSERT INTO genres (name, parent_id) VALUES ($1, NULLIF($2, 0)) RETURNING id", genre.Name, genre.ParentID).Scan(&id)
+	return id, err
+}
+
+// UpdateGenre renames genre and moves it under genre.ParentID.
+func UpdateGenre(genre structs.Genre) error {
+	if genre.ParentID != 0 {
+		var q query
+		q.and("? IN ("+genreSubtreeSQL+")", genre.ParentID, []int{genre.Id})
+		var cycle bool
+		err := Conn.QueryRow("SELECT "+q.where[0], q.args...).Scan(&cycle)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return ErrGenreCycle
+		}
+	}
+	_, err := Conn.Exec("UPDATE genres SET name = $1, parent_id = NULLIF($2, 0) WHERE id = $3", genre.Name, genre.ParentID, genre.Id)
+	return err
+}
+
+// DeleteGenre removes a genre unless a film or a subgenre still refers to it.
+func DeleteGenre(id int) error {
+	var inUse bool
+	err := Conn.QueryRow(`SELECT EXISTS (SELECT 1 FROM filmgenres WHERE genreid = $1)
+		OR EXISTS (SELECT 1 FROM genres WHERE parent_id = $1)`, id).Scan(&inUse)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return ErrGenreInUse
+	}
+	_, err = Conn.Exec("DELETE FROM genres WHERE id = $1", id)
+	return err
+}
+
+// SetFilmGenres replaces the genres linked to a film.
+func SetFilmGenres(filmID int, genres []structs.Genre) error {
+	_, err := Conn.Exec("DELETE FROM filmgenres WHERE filmid = $1", filmID)
+	if err != nil {
+		return err
+	}
+	for _, genre := range genres {
+		_, err = Conn.Exec("INSERT INTO filmgenres (filmid, genreid) VALUES ($1, $2) ON CONFLICT DO NOTHING", filmID, genre.Id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
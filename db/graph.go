@@ -0,0 +1,288 @@
+package db
+
+import (
+	"FilmCollection/structs"
+	"errors"
+)
+
+// ErrNoPath is returned by ShortestActorPath when the actors are not
+// connected within the allowed number of links.
+var ErrNoPath = errors.New("no path found")
+
+// ErrPathSearchLimit is returned by ShortestActorPath when the search
+// visited PathSearchLimit actors without finding a path.
+var ErrPathSearchLimit = errors.New("search limit reached before a path was found")
+
+// PathSearchLimit bounds the number of actors a path search may visit.
+var PathSearchLimit = 100000
+
+// hop is how the breadth-first search reached an actor: from actor through
+// film, depth links away from the start of its side.
+type hop struct {
+	actor, film, depth int
+}
+
+// costarEdge links actor to costar through a shared film.
+type costarEdge struct {
+	actor, costar, film int
+}
+
+// costarEdges returns one edge to every co-star of the given actors.
+func costarEdges(actors []int) ([]costarEdge, error) {
+	rows, err := Conn.Query(`SELECT DISTINCT ON (a.actorid, b.actorid) a.actorid, b.actorid, a.filmid
+		FROM moviecast a JOIN moviecast b ON b.filmid = a.filmid AND b.actorid <> a.actorid
+		WHERE a.actorid = ANY($1) ORDER BY a.actorid, b.actorid, a.filmid`, actors)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var edges []costarEdge
+	for rows.Next() {
+		var e costarEdge
+		err = rows.Scan(&e.actor, &e.costar, &e.film)
+		if err != nil {
+			return nil, err
+		}
+		edges = append(edges, e)
+	}
+	return edges, rows.Err()
+}
+
+// ShortestActorPath finds a shortest actor - film - actor chain from one
+// actor to another with a breadth-first search run from both ends, one
+// level at a time on the smaller frontier. It gives up after maxDepth links
+// or PathSearchLimit visited actors. Both actors must exist.
+func ShortestActorPath(from, to, maxDepth int) (structs.ActorPath, error) {
+	parents := [2]map[int]hop{{from: {actor: -1}}, {to: {actor: -1}}}
+	frontiers := [2][]int{{from}, {to}}
+	meet := -1
+	if from == to {
+		meet = from
+	}
+	for depth := 0; meet < 0 && depth < maxDepth; depth++ {
+		side := 0
+		if len(frontiers[1]) < len(frontiers[0]) {
+			side = 1
+		}
+		if len(frontiers[side]) == 0 {
+			return structs.ActorPath{}, ErrNoPath
+		}
+		edges, err := costarEdges(frontiers[side])
+		if err != nil {
+			return structs.ActorPath{}, err
+		}
+		var next []int
+		best := -1
+		for _, e := range edges {
+			if _, seen := parents[side][e.costar]; seen {
+				continue
+			}
+			parents[side][e.costar] = hop{actor: e.actor, film: e.film, depth: parents[side][e.actor].depth + 1}
+			next = append(next, e.costar)
+			// Finish the level and keep the meeting point closest to the
+			// other end, so that the path is a shortest one.
+			if other, ok := parents[1-side][e.costar]; ok && (best < 0 || other.depth < best) {
+				best = other.depth
+				meet = e.costar
+			}
+		}
+		if len(parents[0])+len(parents[1]) > PathSearchLimit && meet < 0 {
+			return structs.ActorPath{}, ErrPathSearchLimit
+		}
+		frontiers[side] = next
+	}
+	if meet < 0 {
+		return structs.ActorPath{}, ErrNoPath
+	}
+
+	// Walk back to the start, then forward to the end.
+	actors := []int{meet}
+	var films []int
+	for a := meet; parents[0][a].actor >= 0; a = parents[0][a].actor {
+		actors = append([]int{parents[0][a].actor}, actors...)
+		films = append([]int{parents[0][a].film}, films...)
+	}
+	for a := meet; parents[1][a].actor >= 0; a = parents[1][a].actor {
+		actors = append(actors, parents[1][a].actor)
+		films = append(films, parents[1][a].film)
+	}
+	actorNames, err := namesByID("actors", actors)
+	if err != nil {
+		return structs.ActorPath{}, err
+	}
+	filmNames, err := namesByID("films", films)
+	if err != nil {
+		return structs.ActorPath{}, err
+	}
+	path := structs.ActorPath{Degrees: len(films), Links: []structs.PathLink{}}
+	for i, film := range films {
+		path.Links = append(path.Links, structs.PathLink{
+			From: structs.Ref{Id: actors[i], Name: actorNames[actors[i]]},
+			Film: structs.Ref{Id: film, Name: filmNames[film]},
+			To:   structs.Ref{Id: actors[i+1], Name: actorNames[actors[i+1]]},
+		})
+	}
+	return path, nil
+}
+
+// namesByID returns the names of the rows of table ("actors" or "films")
+// with the given ids.
+func namesByID(table string, ids []int) (map[int]string, error) {
+	names := make(map[int]string, len(ids))
+	if len(ids) == 0 {
+		return names, nil
+	}
+	rows, err := Conn.Query("SELECT id, name FROM "+table+" WHERE id = ANY($1)", ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var name string
+		err = rows.Scan(&id, &name)
+		if err != nil {
+			return nil, err
+		}
+		names[id] = name
+	}
+	return names, rows.Err()
+}
+
+// GetCostars returns the actors who appeared with the actor, most shared
+// films first.
+func GetCostars(actorID, limit int) ([]structs.Costar, error) {
+	rows, err := Conn.Query(`SELECT b.actorid, actors.name, count(DISTINCT a.filmid), array_agg(DISTINCT a.filmid)
+		FROM moviecast a JOIN moviecast b ON b.filmid = a.filmid AND b.actorid <> a.actorid
+		JOIN actors ON actors.id = b.actorid
+		WHERE a.actorid = $1
+		GROUP BY b.actorid, actors.name ORDER BY count(DISTINCT a.filmid) DESC, actors.name, b.actorid LIMIT $2`, actorID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	costars := []structs.Costar{}
+	for rows.Next() {
+		var costar structs.Costar
+		var films []int32
+		err = rows.Scan(&costar.ActorID, &costar.Name, &costar.SharedFilms, &films)
+		if err != nil {
+			return nil, err
+		}
+		for _, film := range films {
+			costar.Films = append(costar.Films, int(film))
+		}
+		costars = append(costars, costar)
+	}
+	return costars, rows.Err()
+}
+
+// GraphCenter limits an exported graph to the nodes at most Depth moviecast
+// links away from an actor or a film. Actor-film counts as one link.
+type GraphCenter struct {
+	ActorID *int
+	FilmID  *int
+	Depth   int
+}
+
+// GetGraph loads the actor-film network, whole when center is nil.
+func GetGraph(center *GraphCenter) (structs.Graph, error) {
+	var graph structs.Graph
+	// Nil id lists select everything.
+	var actorIDs, filmIDs []int
+	if center != nil {
+		actors, films := map[int]bool{}, map[int]bool{}
+		var frontierActors, frontierFilms []int
+		if center.ActorID != nil {
+			actors[*center.ActorID] = true
+			frontierActors = []int{*center.ActorID}
+		}
+		if center.FilmID != nil {
+			films[*center.FilmID] = true
+			frontierFilms = []int{*center.FilmID}
+		}
+		for hop := 0; hop < center.Depth && len(frontierActors)+len(frontierFilms) > 0; hop++ {
+			nextFilms, err := linkedIDs("SELECT DISTINCT filmid FROM moviecast WHERE actorid = ANY($1)", frontierActors, films)
+			if err != nil {
+				return graph, err
+			}
+			nextActors, err := linkedIDs("SELECT DISTINCT actorid FROM moviecast WHERE filmid = ANY($1)", frontierFilms, actors)
+			if err != nil {
+				return graph, err
+			}
+			frontierActors, frontierFilms = nextActors, nextFilms
+		}
+		actorIDs, filmIDs = []int{}, []int{}
+		for id := range actors {
+			actorIDs = append(actorIDs, id)
+		}
+		for id := range films {
+			filmIDs = append(filmIDs, id)
+		}
+	}
+
+	rows, err := Conn.Query("SELECT "+actorColumns+" FROM actors WHERE $1::int[] IS NULL OR actors.id = ANY($1) ORDER BY actors.id", actorIDs)
+	if err != nil {
+		return graph, err
+	}
+	actors, err := scanActors(rows)
+	if err != nil {
+		return graph, err
+	}
+	graph.Actors = []structs.Person{}
+	for _, actor := range actors {
+		graph.Actors = append(graph.Actors, actor.Person)
+	}
+	rows, err = Conn.Query("SELECT "+filmColumns+" FROM films WHERE $1::int[] IS NULL OR films.id = ANY($1) ORDER BY films.id", filmIDs)
+	if err != nil {
+		return graph, err
+	}
+	films, err := scanFilms(rows)
+	if err != nil {
+		return graph, err
+	}
+	graph.Films = append([]structs.Film{}, films...)
+	rows, err = Conn.Query(`SELECT DISTINCT ON (actorid, filmid) actorid, filmid, COALESCE(character, ''), COALESCE(credit_type, '')
+		FROM moviecast WHERE ($1::int[] IS NULL OR actorid = ANY($1)) AND ($2::int[] IS NULL OR filmid = ANY($2))
+		ORDER BY actorid, filmid, billing NULLS LAST`, actorIDs, filmIDs)
+	if err != nil {
+		return graph, err
+	}
+	defer rows.Close()
+	graph.Edges = []structs.GraphEdge{}
+	for rows.Next() {
+		var edge structs.GraphEdge
+		err = rows.Scan(&edge.ActorID, &edge.FilmID, &edge.Character, &edge.CreditType)
+		if err != nil {
+			return graph, err
+		}
+		graph.Edges = append(graph.Edges, edge)
+	}
+	return graph, rows.Err()
+}
+
+// linkedIDs runs sql for the frontier ids and returns the ids it selects
+// that are not in seen yet, adding them to seen.
+func linkedIDs(sql string, frontier []int, seen map[int]bool) ([]int, error) {
+	if len(frontier) == 0 {
+		return nil, nil
+	}
+	rows, err := Conn.Query(sql, frontier)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var next []int
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[id] {
+			seen[id] = true
+			next = append(next, id)
+		}
+	}
+	return next, rows.Err()
+}
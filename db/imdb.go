@@ -0,0 +1,253 @@
+package db
+
+import (
+	"errors"
+	"github.com/jackc/pgx"
+)
+
+// ErrImportOptions is returned by GetImportProgress when the recorded
+// progress belongs to an import with other options.
+var ErrImportOptions = errors.New("progress was recorded with other options, restart the import")
+
+// ImportProgress is how far a stage of a resumable import got: the last
+// committed line of its file, or done.
+type ImportProgress struct {
+	Stage string
+	Line  int64
+	Done  bool
+}
+
+// ImdbTitle is a film of the IMDb dataset. Year, Runtime and Rating are 0
+// when unknown.
+type ImdbTitle struct {
+	Tconst  string
+	Name    string
+	Year    int
+	Runtime int
+	Rating  int
+}
+
+// ImdbPrincipal is an actor credit of the IMDb dataset.
+type ImdbPrincipal struct {
+	Tconst    string
+	Nconst    string
+	Ordering  int
+	Category  string
+	Character string
+}
+
+// ImdbName is a person of the IMDb dataset. BirthYear is 0 when unknown.
+type ImdbName struct {
+	Nconst    string
+	Name      string
+	Gender    string
+	BirthYear int
+}
+
+// GetImportProgress returns the progress of the stages of source by stage.
+// Progress of an import with other options is an error.
+func GetImportProgress(source, options string) (map[string]ImportProgress, error) {
+	rows, err := Conn.Query("SELECT stage, options, line, done FROM importprogress WHERE source = $1", source)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	progress := make(map[string]ImportProgress)
+	for rows.Next() {
+		var stage ImportProgress
+		var recorded string
+		err = rows.Scan(&stage.Stage, &recorded, &stage.Line, &stage.Done)
+		if err != nil {
+			return nil, err
+		}
+		if recorded != options {
+			return nil, ErrImportOptions
+		}
+		progress[stage.Stage] = stage
+	}
+	return progress, rows.Err()
+}
+
+// ResetImdbImport forgets the progress and the staged credits of the IMDb
+// import. Imported films and actors are kept and updated by the next run.
+func ResetImdbImport() error {
+	_, err := Conn.Exec("DELETE FROM importprogress WHERE source = 'imdb'")
+	if err != nil {
+		return err
+	}
+	_, err = Conn.Exec("DELETE FROM imdbprincipals")
+	return err
+}
+
+// saveProgress records progress of an imdb stage inside tx.
+func saveProgress(tx *pgx.Tx, options string, progress ImportProgress) error {
+	_, err := tx.Exec(`INSERT INTO importprogress (source, stage, options, line, done) VALUES ('imdb', $1, $2, $3, $4)
+		ON CONFLICT (source, stage) DO UPDATE SET options = excluded.options, line = excluded.line, done = excluded.done, updated_at = now()`,
+		progress.Stage, options, progress.Line, progress.Done)
+	return err
+}
+
+// SaveImdbTitles creates or updates the films of titles by their IMDb id
+// and records progress in the same transaction. Descriptions are kept.
+func SaveImdbTitles(titles []ImdbTitle, options string, progress ImportProgress) error {
+	tconsts := make([]string, len(titles))
+	names := make([]string, len(titles))
+	years := make([]int, len(titles))
+	runtimes := make([]int, len(titles))
+	ratings := make([]int, len(titles))
+	for i, title := range titles {
+		tconsts[i], names[i], years[i], runtimes[i], ratings[i] = title.Tconst, title.Name, title.Year, title.Runtime, title.Rating
+	}
+	tx, err := Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`INSERT INTO films (imdb_id, name, description, release_date, rating, runtime)
+		SELECT tconst, name, '', CASE WHEN year = 0 THEN '0001-01-01'::date ELSE make_date(year, 1, 1) END, rating, NULLIF(runtime, 0)
+		FROM unnest($1::text[], $2::text[], $3::int[], $4::int[], $5::int[]) AS t(tconst, name, year, runtime, rating)
+		ON CONFLICT (imdb_id) DO UPDATE SET name = excluded.name, release_date = excluded.release_date,
+			rating = excluded.rating, runtime = excluded.runtime`,
+		tconsts, names, years, runtimes, ratings)
+	if err != nil {
+		return err
+	}
+	err = saveProgress(tx, options, progress)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ImdbFilms returns the IMDb ids of the imported films.
+func ImdbFilms() (map[string]bool, error) {
+	rows, err := Conn.Query("SELECT imdb_id FROM films WHERE imdb_id IS NOT NULL")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	films := make(map[string]bool)
+	for rows.Next() {
+		var tconst string
+		err = rows.Scan(&tconst)
+		if err != nil {
+			return nil, err
+		}
+		films[tconst] = true
+	}
+	return films, rows.Err()
+}
+
+// StagedImdbNames returns the IMDb ids of the people of the staged credits
+// with their gender, "female" for actresses and "male" otherwise.
+func StagedImdbNames() (map[string]string, error) {
+	rows, err := Conn.Query(`SELECT nconst, CASE WHEN bool_or(category = 'actress') THEN 'female' ELSE 'male' END
+		FROM imdbprincipals GROUP BY nconst`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names := make(map[string]string)
+	for rows.Next() {
+		var nconst, gender string
+		err = rows.Scan(&nconst, &gender)
+		if err != nil {
+			return nil, err
+		}
+		names[nconst] = gender
+	}
+	return names, rows.Err()
+}
+
+// StageImdbPrincipals keeps credits until their actors are imported and
+// records progress in the same transaction.
+func StageImdbPrincipals(principals []ImdbPrincipal, options string, progress ImportProgress) error {
+	tconsts := make([]string, len(principals))
+	nconsts := make([]string, len(principals))
+	orderings := make([]int, len(principals))
+	categories := make([]string, len(principals))
+	characters := make([]string, len(principals))
+	for i, p := range principals {
+		tconsts[i], nconsts[i], orderings[i], categories[i], characters[i] = p.Tconst, p.Nconst, p.Ordering, p.Category, p.Character
+	}
+	tx, err := Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`INSERT INTO imdbprincipals (tconst, nconst, ordering, category, character)
+		SELECT * FROM unnest($1::text[], $2::text[], $3::int[], $4::text[], $5::text[])
+		ON CONFLICT DO NOTHING`, tconsts, nconsts, orderings, categories, characters)
+	if err != nil {
+		return err
+	}
+	err = saveProgress(tx, options, progress)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SaveImdbNames creates or updates the actors of names by their IMDb id
+// and records progress in the same transaction.
+func SaveImdbNames(names []ImdbName, options string, progress ImportProgress) error {
+	nconsts := make([]string, len(names))
+	fullNames := make([]string, len(names))
+	genders := make([]string, len(names))
+	birthYears := make([]int, len(names))
+	for i, name := range names {
+		nconsts[i], fullNames[i], genders[i], birthYears[i] = name.Nconst, name.Name, name.Gender, name.BirthYear
+	}
+	tx, err := Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`INSERT INTO actors (imdb_id, name, gender, birth_date)
+		SELECT nconst, name, gender, CASE WHEN year = 0 THEN '0001-01-01'::date ELSE make_date(year, 1, 1) END
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::int[]) AS n(nconst, name, gender, year)
+		ON CONFLICT (imdb_id) DO UPDATE SET name = excluded.name, gender = excluded.gender, birth_date = excluded.birth_date`,
+		nconsts, fullNames, genders, birthYears)
+	if err != nil {
+		return err
+	}
+	err = saveProgress(tx, options, progress)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// LinkImdbCast turns the staged credits into cast links, replacing the
+// roles of links that exist already, and clears the staging table. It
+// returns the number of new links.
+func LinkImdbCast(options string) (int64, error) {
+	tx, err := Conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	const credits = `SELECT DISTINCT ON (f.id, a.id) f.id AS filmid, a.id AS actorid, p.character, p.ordering
+		FROM imdbprincipals p JOIN films f ON f.imdb_id = p.tconst JOIN actors a ON a.imdb_id = p.nconst
+		ORDER BY f.id, a.id, p.ordering`
+	_, err = tx.Exec(`UPDATE moviecast mc SET character = NULLIF(c.character, ''), billing = c.ordering
+		FROM (` + credits + `) c WHERE mc.filmid = c.filmid AND mc.actorid = c.actorid`)
+	if err != nil {
+		return 0, err
+	}
+	tag, err := tx.Exec(`INSERT INTO moviecast (filmid, actorid, character, billing)
+		SELECT c.filmid, c.actorid, NULLIF(c.character, ''), c.ordering FROM (` + credits + `) c
+		WHERE NOT EXISTS (SELECT 1 FROM moviecast mc WHERE mc.filmid = c.filmid AND mc.actorid = c.actorid)`)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("DELETE FROM imdbprincipals")
+	if err != nil {
+		return 0, err
+	}
+	err = saveProgress(tx, options, ImportProgress{Stage: "cast", Done: true})
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), tx.Commit()
+}
@@ -0,0 +1,328 @@
+package db
+
+import (
+	"FilmCollection/structs"
+	"errors"
+	"github.com/jackc/pgx"
+)
+
+// ErrActorNotFound is returned for cast rows of Import with unknown actors.
+var ErrActorNotFound = errors.New("actor not found")
+
+// ImportOptions controls how Import commits. With BatchSize zero all rows
+// share one transaction that is only committed when every row succeeds;
+// otherwise every BatchSize rows are committed on their own. A dry run
+// checks and applies every row but rolls all changes back at the end, so
+// later batches still see the records created by earlier ones.
+type ImportOptions struct {
+	DryRun    bool
+	BatchSize int
+}
+
+// Import creates or updates the films, actors and cast links of rows in
+// order, so later rows can refer to records created by earlier ones.
+// Failing rows are reported in the results rather than returned as errors.
+// The import runs on a connection of its own, so that it does not hold one
+// of the Conn pool for its whole length.
+func Import(rows []structs.ImportRow, options ImportOptions) (structs.ImportReport, error) {
+	report := structs.ImportReport{DryRun: options.DryRun, Rows: len(rows), Results: []structs.ImportResult{}}
+	conn, err := pgx.Connect(config)
+	if err != nil {
+		return report, err
+	}
+	defer conn.Close()
+	var dryRun *pgx.Tx
+	if options.DryRun {
+		dryRun, err = conn.Begin()
+		if err != nil {
+			return report, err
+		}
+		defer dryRun.Rollback()
+	}
+	size := options.BatchSize
+	if size <= 0 || size > len(rows) {
+		size = len(rows)
+	}
+	for start := 0; start < len(rows); start += size {
+		end := start + size
+		if end > len(rows) {
+			end = len(rows)
+		}
+		var results []structs.ImportResult
+		committed := false
+		if dryRun != nil {
+			results, err = dryRunBatch(dryRun, rows[start:end])
+		} else {
+			results, committed, err = importBatch(conn, rows[start:end])
+		}
+		if err != nil {
+			return report, err
+		}
+		report.Batches++
+		if committed {
+			report.BatchesCommitted++
+		}
+		for _, result := range results {
+			switch result.Action {
+			case "create":
+				report.Created++
+			case "update":
+				report.Updated++
+			case "link":
+				report.Linked++
+			default:
+				report.Failed++
+			}
+		}
+		report.Results = append(report.Results, results...)
+	}
+	return report, nil
+}
+
+// importBatch applies rows in one transaction and commits it when no row
+// failed.
+func importBatch(conn *pgx.Conn, rows []structs.ImportRow) ([]structs.ImportResult, bool, error) {
+	tx, err := conn.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+	results, failed := importRows(tx, rows)
+	if failed {
+		return results, false, nil
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, false, err
+	}
+	for i := range results {
+		results[i].Committed = true
+	}
+	return results, true, nil
+}
+
+// dryRunBatch applies rows inside a savepoint of the dry run transaction
+// and undoes them when a row failed, as importBatch would not commit them.
+func dryRunBatch(tx *pgx.Tx, rows []structs.ImportRow) ([]structs.ImportResult, error) {
+	_, err := tx.Exec("SAVEPOINT import_batch")
+	if err != nil {
+		return nil, err
+	}
+	results, failed := importRows(tx, rows)
+	if failed {
+		_, err = tx.Exec("ROLLBACK TO SAVEPOINT import_batch")
+	} else {
+		_, err = tx.Exec("RELEASE SAVEPOINT import_batch")
+	}
+	return results, err
+}
+
+// importRows applies rows in tx and tells whether one of them failed.
+func importRows(tx *pgx.Tx, rows []structs.ImportRow) ([]structs.ImportResult, bool) {
+	results := make([]structs.ImportResult, 0, len(rows))
+	failed := false
+	for _, row := range rows {
+		result := structs.ImportResult{Line: row.Line, Type: row.Type}
+		err := importRow(tx, row, &result)
+		if err != nil {
+			result.Action, result.Error = "error", err.Error()
+			failed = true
+		}
+		results = append(results, result)
+	}
+	return results, failed
+}
+
+// importRow validates and applies one row inside a savepoint, so that a
+// failing statement does not abort the rest of the batch.
+func importRow(tx *pgx.Tx, row structs.ImportRow, result *structs.ImportResult) error {
+	err := validateImportRow(row)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("SAVEPOINT import_row")
+	if err != nil {
+		return err
+	}
+	switch row.Type {
+	case "film":
+		err = importFilm(tx, row, result)
+	case "actor":
+		err = importActor(tx, row, result)
+	default:
+		err = importCast(tx, row, result)
+	}
+	if err != nil {
+		_, rollbackErr := tx.Exec("ROLLBACK TO SAVEPOINT import_row")
+		if rollbackErr != nil {
+			return rollbackErr
+		}
+		return err
+	}
+	_, err = tx.Exec("RELEASE SAVEPOINT import_row")
+	return err
+}
+
+func validateImportRow(row structs.ImportRow) error {
+	if row.ParseError != "" {
+		return errors.New(row.ParseError)
+	}
+	switch row.Type {
+	case "film":
+		if row.Name == "" {
+			return errors.New("name not specified")
+		}
+		if row.Rating < 0 || row.Rating > 10 {
+			return errors.New("rating must be between 0 and 10")
+		}
+		if row.Runtime < 0 {
+			return errors.New("invalid runtime format")
+		}
+	case "actor":
+		if row.Name == "" {
+			return errors.New("name not specified")
+		}
+	case "cast":
+		if row.FilmID == 0 && row.FilmName == "" {
+			return errors.New("film_id or film_name not specified")
+		}
+		if row.ActorID == 0 && row.ActorName == "" {
+			return errors.New("actor_id or actor_name not specified")
+		}
+		if row.Billing < 0 {
+			return errors.New("invalid billing format")
+		}
+		if row.CreditType != "" {
+			valid := false
+			for _, creditType := range structs.CreditTypes {
+				valid = valid || creditType == row.CreditType
+			}
+			if !valid {
+				return errors.New("invalid credit_type format")
+			}
+		}
+	default:
+		return errors.New("invalid type format")
+	}
+	return nil
+}
+
+// matchID returns the id of the film or actor with id, or when id is zero
+// of the only one with the name and release or birth date. It returns
+// pgx.ErrNoRows when there is none.
+func matchID(tx *pgx.Tx, table string, id int, name string, date structs.Date) (int, error) {
+	if id != 0 {
+		err := tx.QueryRow("SELECT id FROM "+table+" WHERE id = $1", id).Scan(&id)
+		return id, err
+	}
+	dateColumn := "release_date"
+	if table == "actors" {
+		dateColumn = "birth_date"
+	}
+	rows, err := tx.Query("SELECT id FROM "+table+" WHERE name = $1 AND "+dateColumn+" = $2 LIMIT 2", name, date.Format("2006-01-02"))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		err = rows.Scan(&id)
+		if err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	switch len(ids) {
+	case 0:
+		return 0, pgx.ErrNoRows
+	case 1:
+		return ids[0], nil
+	}
+	return 0, errors.New("several " + table + " have this name and " + dateColumn + ", set the id")
+}
+
+func importFilm(tx *pgx.Tx, row structs.ImportRow, result *structs.ImportResult) error {
+	id, err := matchID(tx, "films", row.Id, row.Name, row.ReleaseDate)
+	if err == pgx.ErrNoRows && row.Id == 0 {
+		result.Action = "create"
+		return tx.QueryRow("INSERT INTO films (name, description, release_date, rating, runtime) VALUES ($1, $2, $3, $4, NULLIF($5, 0)) RETURNING id",
+			row.Name, row.Description, row.ReleaseDate.Format("2006-01-02"), row.Rating, row.Runtime).Scan(&result.Id)
+	}
+	if err == pgx.ErrNoRows {
+		return ErrFilmNotFound
+	}
+	if err != nil {
+		return err
+	}
+	result.Action, result.Id = "update", id
+	_, err = tx.Exec(`UPDATE films SET name = $2, description = COALESCE(NULLIF($3, ''), description),
+		release_date = COALESCE($4::date, release_date), rating = COALESCE(NULLIF($5, 0), rating),
+		runtime = COALESCE(NULLIF($6, 0), runtime) WHERE id = $1`,
+		id, row.Name, row.Description, givenDate(row.ReleaseDate), row.Rating, row.Runtime)
+	return err
+}
+
+func importActor(tx *pgx.Tx, row structs.ImportRow, result *structs.ImportResult) error {
+	id, err := matchID(tx, "actors", row.Id, row.Name, row.BirthDate)
+	if err == pgx.ErrNoRows && row.Id == 0 {
+		result.Action = "create"
+		return tx.QueryRow("INSERT INTO actors (name, gender, birth_date) VALUES ($1, $2, $3) RETURNING id",
+			row.Name, row.Gender, row.BirthDate.Format("2006-01-02")).Scan(&result.Id)
+	}
+	if err == pgx.ErrNoRows {
+		return ErrActorNotFound
+	}
+	if err != nil {
+		return err
+	}
+	result.Action, result.Id = "update", id
+	_, err = tx.Exec(`UPDATE actors SET name = $2, gender = COALESCE(NULLIF($3, ''), gender),
+		birth_date = COALESCE($4::date, birth_date) WHERE id = $1`,
+		id, row.Name, row.Gender, givenDate(row.BirthDate))
+	return err
+}
+
+// givenDate returns the date of an update row, nil when the row leaves it
+// out and the stored date is kept.
+func givenDate(d structs.Date) *string {
+	if d.IsZero() {
+		return nil
+	}
+	s := d.Format("2006-01-02")
+	return &s
+}
+
+// importCast links the film and the actor of the row, or replaces the role
+// when they are linked already.
+func importCast(tx *pgx.Tx, row structs.ImportRow, result *structs.ImportResult) error {
+	var err error
+	result.FilmID, err = matchID(tx, "films", row.FilmID, row.FilmName, row.FilmReleaseDate)
+	if err == pgx.ErrNoRows {
+		return ErrFilmNotFound
+	}
+	if err != nil {
+		return err
+	}
+	result.ActorID, err = matchID(tx, "actors", row.ActorID, row.ActorName, row.ActorBirthDate)
+	if err == pgx.ErrNoRows {
+		return ErrActorNotFound
+	}
+	if err != nil {
+		return err
+	}
+	credit := structs.Credit{ActorID: result.ActorID, Character: row.Character, Billing: row.Billing, CreditType: row.CreditType}
+	tag, err := tx.Exec(`UPDATE moviecast SET character = NULLIF($3, ''), billing = NULLIF($4, 0), credit_type = NULLIF($5, '')
+		WHERE filmid = $1 AND actorid = $2`, result.FilmID, credit.ActorID, credit.Character, credit.Billing, credit.CreditType)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		result.Action = "update"
+		return nil
+	}
+	result.Action = "link"
+	return addCredit(tx, result.FilmID, credit)
+}
@@ -0,0 +1,208 @@
+package db
+
+import (
+	"FilmCollection/structs"
+	"errors"
+	"github.com/jackc/pgx"
+	"unicode/utf8"
+)
+
+// maxFilmName is the length of the name column of Films.
+const maxFilmName = 30
+
+// errSeveralFilms is the reason of Letterboxd lines matching more than one
+// film.
+var errSeveralFilms = errors.New("several films match")
+
+// ImportLetterboxd adds the lines of a Letterboxd export to the user's
+// ratings, diary and watchlist. Films are matched by name, ignoring case,
+// and release year when the line has one. Ratings replace the user's
+// ratings; diary entries and watchlist films the user already has are
+// skipped. Lines that cannot be matched are reported, not returned as
+// errors. A dry run rolls every change back. Like Import, it runs on a
+// connection of its own.
+func ImportLetterboxd(userID int, rows []structs.LetterboxdRow, dryRun bool) (structs.LetterboxdReport, error) {
+	report := structs.LetterboxdReport{DryRun: dryRun, Rows: len(rows), Unmatched: []structs.LetterboxdUnmatched{}}
+	conn, err := pgx.Connect(config)
+	if err != nil {
+		return report, err
+	}
+	defer conn.Close()
+	tx, err := conn.Begin()
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback()
+	var rated []int
+	for _, row := range rows {
+		unmatched := func(reason string) {
+			report.Unmatched = append(report.Unmatched, structs.LetterboxdUnmatched{
+				File: row.File, Line: row.Line, Name: row.Name, Year: row.Year, Reason: reason,
+			})
+		}
+		if row.ParseError != "" {
+			unmatched(row.ParseError)
+			continue
+		}
+		filmID, err := matchLetterboxdFilm(tx, row.Name, row.Year)
+		if errors.Is(err, pgx.ErrNoRows) {
+			unmatched("film not found")
+			continue
+		}
+		if errors.Is(err, errSeveralFilms) {
+			unmatched(err.Error())
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+		added, err := importLetterboxdRow(tx, userID, filmID, row)
+		if err != nil {
+			return report, err
+		}
+		if !added {
+			report.Skipped++
+			continue
+		}
+		switch row.File {
+		case "ratings":
+			report.Ratings++
+			rated = append(rated, filmID)
+		case "diary":
+			report.Diary++
+			rated = append(rated, filmID)
+		case "watchlist":
+			report.Watchlist++
+		}
+	}
+	if dryRun {
+		return report, nil
+	}
+	refreshed := make(map[int]bool, len(rated))
+	for _, filmID := range rated {
+		if refreshed[filmID] {
+			continue
+		}
+		refreshed[filmID] = true
+		err = refreshTaste(tx, userID, filmID)
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, tx.Commit()
+}
+
+// matchLetterboxdFilm returns the id of the only film with the name and,
+// unless year is 0, released that year. It returns pgx.ErrNoRows or
+// errSeveralFilms otherwise. Longer names are cut to the length of the
+// column first, as they were when the film was stored.
+func matchLetterboxdFilm(tx *pgx.Tx, name string, year int) (int, error) {
+	if utf8.RuneCountInString(name) > maxFilmName {
+		name = string([]rune(name)[:maxFilmName])
+	}
+	rows, err := tx.Query(`SELECT id FROM films WHERE lower(name) = lower($1)
+		AND ($2 = 0 OR extract(year FROM release_date) = $2) LIMIT 2`, name, year)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	switch len(ids) {
+	case 0:
+		return 0, pgx.ErrNoRows
+	case 1:
+		return ids[0], nil
+	}
+	return 0, errSeveralFilms
+}
+
+// importLetterboxdRow stores a matched line and tells whether it was new to
+// the user.
+func importLetterboxdRow(tx *pgx.Tx, userID, filmID int, row structs.LetterboxdRow) (bool, error) {
+	// Dates are passed as text so that they are taken as days of the
+	// database's time zone, the one GetLetterboxdExport reads them in.
+	date := ""
+	if !row.Date.IsZero() {
+		date = row.Date.Format("2006-01-02")
+	}
+	switch row.File {
+	case "ratings":
+		_, err := tx.Exec(`INSERT INTO userratings (userid, filmid, rating, rated_at)
+			VALUES ($1, $2, $3, COALESCE(NULLIF($4, '')::date, now()))
+			ON CONFLICT (userid, filmid) DO UPDATE SET rating = EXCLUDED.rating, rated_at = EXCLUDED.rated_at`,
+			userID, filmID, row.Rating, date)
+		return err == nil, err
+	case "diary":
+		watchedOn := date
+		if !row.WatchedDate.IsZero() {
+			watchedOn = row.WatchedDate.Format("2006-01-02")
+		}
+		tag, err := tx.Exec(`INSERT INTO diary (userid, filmid, watched_on, rating, rewatch, created_at)
+			SELECT $1, $2, COALESCE(NULLIF($3, '')::date, current_date), NULLIF($4, 0), $5, COALESCE(NULLIF($6, '')::date, now())
+			WHERE NOT EXISTS (SELECT 1 FROM diary WHERE userid = $1 AND filmid = $2
+				AND watched_on = COALESCE(NULLIF($3, '')::date, current_date))`,
+			userID, filmID, watchedOn, row.Rating, row.Rewatch, date)
+		return tag.RowsAffected() > 0, err
+	case "watchlist":
+		var listID int
+		err := tx.QueryRow(`INSERT INTO lists (userid, name, is_watchlist) VALUES ($1, 'Watchlist', true)
+			ON CONFLICT (userid) WHERE is_watchlist DO UPDATE SET is_watchlist = true RETURNING id`, userID).Scan(&listID)
+		if err != nil {
+			return false, err
+		}
+		tag, err := tx.Exec(`INSERT INTO listitems (listid, filmid, position, added_at)
+			SELECT $1, $2, COALESCE(max(position), 0) + 1, COALESCE(NULLIF($3, '')::date, now()) FROM listitems WHERE listid = $1
+			ON CONFLICT (listid, filmid) DO NOTHING`, listID, filmID, date)
+		return tag.RowsAffected() > 0, err
+	}
+	return false, errors.New("unknown Letterboxd file " + row.File)
+}
+
+// GetLetterboxdExport returns the user's ratings, diary and watchlist as
+// the lines of a Letterboxd export, keyed by file, oldest first as
+// Letterboxd writes them.
+func GetLetterboxdExport(userID int) (map[string][]structs.LetterboxdRow, error) {
+	const film = `f.name, CASE WHEN f.release_date = '0001-01-01' THEN 0 ELSE extract(year FROM f.release_date)::int END`
+	queries := map[string]string{
+		"ratings": `SELECT r.rated_at::date, ` + film + `, r.rating, false, r.rated_at::date
+			FROM userratings r JOIN films f ON f.id = r.filmid WHERE r.userid = $1 ORDER BY r.rated_at, f.id`,
+		"diary": `SELECT d.created_at::date, ` + film + `, COALESCE(d.rating, 0), d.rewatch, d.watched_on
+			FROM diary d JOIN films f ON f.id = d.filmid WHERE d.userid = $1 ORDER BY d.watched_on, d.id`,
+		"watchlist": `SELECT li.added_at::date, ` + film + `, 0, false, li.added_at::date
+			FROM listitems li JOIN lists l ON l.id = li.listid JOIN films f ON f.id = li.filmid
+			WHERE l.userid = $1 AND l.is_watchlist ORDER BY li.position`,
+	}
+	files := make(map[string][]structs.LetterboxdRow, len(queries))
+	for file, sql := range queries {
+		rows, err := Conn.Query(sql, userID)
+		if err != nil {
+			return nil, err
+		}
+		files[file] = []structs.LetterboxdRow{}
+		for rows.Next() {
+			row := structs.LetterboxdRow{File: file}
+			err = rows.Scan(&row.Date, &row.Name, &row.Year, &row.Rating, &row.Rewatch, &row.WatchedDate)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			files[file] = append(files[file], row)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,199 @@
+package db
+
+import (
+	"FilmCollection/structs"
+	"errors"
+	"github.com/jackc/pgx"
+	"strings"
+	"time"
+)
+
+// LibraryFile is a video file recorded by the library scanner.
+type LibraryFile struct {
+	FilmID     int
+	Size       int64
+	ModifiedAt time.Time
+}
+
+// ScannedFilm is a video file with the metadata the scanner read from its
+// NFO file or its name. Year and the numbers are 0 when unknown.
+type ScannedFilm struct {
+	Path        string
+	Size        int64
+	ModifiedAt  time.Time
+	ImdbID      string
+	Name        string
+	Year        int
+	ReleaseDate structs.Date
+	Description string
+	Rating      int
+	Runtime     int
+	Cast        []ScannedCredit
+}
+
+// ScannedCredit is an actor listed in an NFO file.
+type ScannedCredit struct {
+	Name      string
+	Character string
+	Billing   int
+}
+
+// Library is a connection of the library scanner. A scan runs on a
+// connection of its own, like Export, so that it does not hold one of the
+// Conn pool for its whole length.
+type Library struct {
+	conn *pgx.Conn
+}
+
+// OpenLibrary connects the library scanner to the database.
+func OpenLibrary() (*Library, error) {
+	conn, err := pgx.Connect(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Library{conn: conn}, nil
+}
+
+// Close closes the connection of the scanner.
+func (l *Library) Close() error {
+	return l.conn.Close()
+}
+
+// Files returns the recorded files under any of dirs by path.
+func (l *Library) Files(dirs []string) (map[string]LibraryFile, error) {
+	rows, err := l.conn.Query("SELECT path, filmid, size, modified_at FROM filmfiles WHERE path LIKE ANY($1)", likePrefixes(dirs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	files := make(map[string]LibraryFile)
+	for rows.Next() {
+		var path string
+		var file LibraryFile
+		err = rows.Scan(&path, &file.FilmID, &file.Size, &file.ModifiedAt)
+		if err != nil {
+			return nil, err
+		}
+		files[path] = file
+	}
+	return files, rows.Err()
+}
+
+// likePrefixes turns directories into LIKE patterns matching the paths
+// below them.
+func likePrefixes(dirs []string) []string {
+	escape := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	patterns := make([]string, len(dirs))
+	for i, dir := range dirs {
+		patterns[i] = escape.Replace(strings.TrimSuffix(dir, "/")) + "/%"
+	}
+	return patterns
+}
+
+// RemoveFiles forgets files that are gone. Their films are kept.
+func (l *Library) RemoveFiles(paths []string) error {
+	_, err := l.conn.Exec("DELETE FROM filmfiles WHERE path = ANY($1)", paths)
+	return err
+}
+
+// SaveFilm records the file of a scanned film. The film is found by
+// its IMDb id, or by name and release year, and created with its cast when
+// there is none; actors are found by name or created. It returns the film
+// id and whether the film was created.
+func (l *Library) SaveFilm(film ScannedFilm) (int, bool, error) {
+	tx, err := l.conn.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+	id, err := matchScannedFilm(tx, film)
+	created := err == pgx.ErrNoRows
+	if created {
+		id, err = createScannedFilm(tx, film)
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	_, err = tx.Exec(`INSERT INTO filmfiles (path, filmid, size, modified_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (path) DO UPDATE SET filmid = excluded.filmid, size = excluded.size,
+			modified_at = excluded.modified_at, scanned_at = now()`,
+		film.Path, id, film.Size, film.ModifiedAt)
+	if err != nil {
+		return 0, false, err
+	}
+	return id, created, tx.Commit()
+}
+
+// matchScannedFilm returns the id of the film of a scanned file or
+// pgx.ErrNoRows.
+func matchScannedFilm(tx *pgx.Tx, film ScannedFilm) (int, error) {
+	var id int
+	if film.ImdbID != "" {
+		err := tx.QueryRow("SELECT id FROM films WHERE imdb_id = $1", film.ImdbID).Scan(&id)
+		if err != pgx.ErrNoRows {
+			return id, err
+		}
+	}
+	rows, err := tx.Query(`SELECT id FROM films WHERE lower(name) = lower($1)
+		AND ($2 = 0 OR extract(year FROM release_date)::int = $2) ORDER BY id LIMIT 2`, film.Name, film.Year)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		err = rows.Scan(&id)
+		if err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	switch len(ids) {
+	case 0:
+		return 0, pgx.ErrNoRows
+	case 1:
+		return ids[0], nil
+	}
+	return 0, errors.New("several films are named " + film.Name + ", add the year or an NFO file with the IMDb id")
+}
+
+func createScannedFilm(tx *pgx.Tx, film ScannedFilm) (int, error) {
+	releaseDate := film.ReleaseDate.Time
+	if releaseDate.IsZero() && film.Year != 0 {
+		releaseDate = time.Date(film.Year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	var imdbID *string
+	if film.ImdbID != "" {
+		imdbID = &film.ImdbID
+	}
+	var id int
+	err := tx.QueryRow(`INSERT INTO films (name, description, release_date, rating, runtime, imdb_id)
+		VALUES ($1, $2, $3, $4, NULLIF($5, 0), $6) RETURNING id`,
+		film.Name, film.Description, releaseDate.Format("2006-01-02"), film.Rating, film.Runtime, imdbID).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	linked := make(map[int]bool)
+	for _, credit := range film.Cast {
+		var actorID int
+		err = tx.QueryRow("SELECT id FROM actors WHERE lower(name) = lower($1) ORDER BY id LIMIT 1", credit.Name).Scan(&actorID)
+		if err == pgx.ErrNoRows {
+			err = tx.QueryRow("INSERT INTO actors (name, gender, birth_date) VALUES ($1, '', '0001-01-01') RETURNING id", credit.Name).Scan(&actorID)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if linked[actorID] {
+			continue
+		}
+		linked[actorID] = true
+		err = addCredit(tx, id, structs.Credit{ActorID: actorID, Character: credit.Character, Billing: credit.Billing})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
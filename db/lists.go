@@ -0,0 +1,352 @@
+package db
+
+import (
+	"FilmCollection/structs"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/jackc/pgx"
+)
+
+// ErrListNotFound is returned when a list does not exist or is not visible
+// to the user.
+var ErrListNotFound = errors.New("list not found")
+
+// ErrListItemNotFound is returned by UpdateListNote when the film is not on
+// a list of the user.
+var ErrListItemNotFound = errors.New("film is not on the list")
+
+// ErrFilmNotFound is returned by AddListItem, AddDiaryEntry and Import for
+// unknown films.
+var ErrFilmNotFound = errors.New("film not found")
+
+// ErrListOrder is returned by ReorderList when the new order is not a
+// permutation of the films on the list.
+var ErrListOrder = errors.New("new order must list every film of the list exactly once")
+
+const listColumns = `id, userid, name, description, public, COALESCE(share_token, ''), is_watchlist, created_at, updated_at`
+
+// AddList creates a list owned by list.UserID and returns its id.
+func AddList(list structs.List) (int, error) {
+	var id int
+	err := Conn.QueryRow("INSERT INTO lists (userid, name, description, public) VALUES ($1, $2, $3, $4) RETURNING id",
+		list.UserID, list.Name, list.Description, list.Public).Scan(&id)
+	return id, err
+}
+
+// GetList returns a list with its items if userID owns it or it is public.
+// The share token is only returned to the owner.
+func GetList(id, userID int) (structs.List, error) {
+	rows, err := Conn.Query("SELECT "+listColumns+" FROM lists WHERE id = $1 AND (userid = $2 OR public)", id, userID)
+	if err != nil {
+		return structs.List{}, err
+	}
+	return firstList(rows, userID)
+}
+
+// GetListByToken returns the list shared with token, whoever asks for it.
+func GetListByToken(token string, userID int) (structs.List, error) {
+	rows, err := Conn.Query("SELECT "+listColumns+" FROM lists WHERE share_token = $1", token)
+	if err != nil {
+		return structs.List{}, err
+	}
+	return firstList(rows, userID)
+}
+
+// firstList returns the only list selected by rows or ErrListNotFound.
+func firstList(rows *pgx.Rows, userID int) (structs.List, error) {
+	lists, err := scanLists(rows, userID)
+	if err != nil {
+		return structs.List{}, err
+	}
+	if len(lists) == 0 {
+		return structs.List{}, ErrListNotFound
+	}
+	return lists[0], nil
+}
+
+// GetUserLists returns every list of a user with its items, watchlist first.
+func GetUserLists(userID int) ([]structs.List, error) {
+	rows, err := Conn.Query("SELECT "+listColumns+" FROM lists WHERE userid = $1 ORDER BY is_watchlist DESC, created_at, id", userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanLists(rows, userID)
+}
+
+// GetWatchlist returns the user's watchlist, creating it on first use.
+func GetWatchlist(userID int) (structs.List, error) {
+	_, err := Conn.Exec(`INSERT INTO lists (userid, name, is_watchlist) VALUES ($1, 'Watchlist', true)
+		ON CONFLICT (userid) WHERE is_watchlist DO NOTHING`, userID)
+	if err != nil {
+		return structs.List{}, err
+	}
+	rows, err := Conn.Query("SELECT "+listColumns+" FROM lists WHERE userid = $1 AND is_watchlist", userID)
+	if err != nil {
+		return structs.List{}, err
+	}
+	return firstList(rows, userID)
+}
+
+// scanLists reads rows selected with listColumns, closes them and fills the
+// items of all lists with one more query. Share tokens of lists not owned by
+// userID are cleared.
+func scanLists(rows *pgx.Rows, userID int) ([]structs.List, error) {
+	lists := []structs.List{}
+	for rows.Next() {
+		var list structs.List
+		err := rows.Scan(&list.Id, &list.UserID, &list.Name, &list.Description, &list.Public, &list.ShareToken, &list.Watchlist, &list.CreatedAt, &list.UpdatedAt)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if list.UserID != userID {
+			list.ShareToken = ""
+		}
+		list.Items = []structs.ListItem{}
+		lists = append(lists, list)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(lists) == 0 {
+		return lists, nil
+	}
+	ids := make([]int, len(lists))
+	index := make(map[int]int, len(lists))
+	for i, list := range lists {
+		ids[i] = list.Id
+		index[list.Id] = i
+	}
+	itemRows, err := Conn.Query(`SELECT li.listid, li.filmid, f.name, li.position, li.note, li.added_at
+		FROM listitems li JOIN films f ON f.id = li.filmid
+		WHERE li.listid = ANY($1) ORDER BY li.listid, li.position`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+	for itemRows.Next() {
+		var item structs.ListItem
+		err = itemRows.Scan(&item.ListID, &item.FilmID, &item.FilmName, &item.Position, &item.Note, &item.AddedAt)
+		if err != nil {
+			return nil, err
+		}
+		i := index[item.ListID]
+		lists[i].Items = append(lists[i].Items, item)
+	}
+	return lists, itemRows.Err()
+}
+
+// UpdateList changes the fields of update that are set. Only the owner can
+// update a list.
+func UpdateList(userID int, update structs.ListUpdate) error {
+	tag, err := Conn.Exec(`UPDATE lists SET name = COALESCE($3, name), description = COALESCE($4, description),
+		public = COALESCE($5, public), updated_at = now() WHERE id = $1 AND userid = $2`,
+		update.Id, userID, update.Name, update.Description, update.Public)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrListNotFound
+	}
+	return nil
+}
+
+// ShareList creates a new share token for the list, invalidating the old
+// link, or removes sharing when enabled is false.
+func ShareList(id, userID int, enabled bool) error {
+	var token *string
+	if enabled {
+		b := make([]byte, 16)
+		_, err := rand.Read(b)
+		if err != nil {
+			return err
+		}
+		s := hex.EncodeToString(b)
+		token = &s
+	}
+	tag, err := Conn.Exec("UPDATE lists SET share_token = $3, updated_at = now() WHERE id = $1 AND userid = $2", id, userID, token)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrListNotFound
+	}
+	return nil
+}
+
+// DeleteList removes a list of the user with its items.
+func DeleteList(id, userID int) error {
+	tag, err := Conn.Exec("DELETE FROM lists WHERE id = $1 AND userid = $2", id, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrListNotFound
+	}
+	return nil
+}
+
+// lockList checks that the user owns the list and locks it so that
+// concurrent changes of the item positions are serialized.
+func lockList(tx *pgx.Tx, id, userID int) error {
+	var found int
+	err := tx.QueryRow("SELECT id FROM lists WHERE id = $1 AND userid = $2 FOR UPDATE", id, userID).Scan(&found)
+	if err == pgx.ErrNoRows {
+		return ErrListNotFound
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE lists SET updated_at = now() WHERE id = $1", id)
+	return err
+}
+
+// AddListItem puts a film on the list at item.Position, or at the end when
+// the position is zero or past the end. Adding a film already on the list
+// only replaces its note.
+func AddListItem(userID int, item structs.ListItem) error {
+	tx, err := Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = lockList(tx, item.ListID, userID)
+	if err != nil {
+		return err
+	}
+	tag, err := tx.Exec("UPDATE listitems SET note = $3 WHERE listid = $1 AND filmid = $2", item.ListID, item.FilmID, item.Note)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return tx.Commit()
+	}
+	var size int
+	err = tx.QueryRow("SELECT count(*) FROM listitems WHERE listid = $1", item.ListID).Scan(&size)
+	if err != nil {
+		return err
+	}
+	if item.Position < 1 || item.Position > size {
+		item.Position = size + 1
+	}
+	_, err = tx.Exec("UPDATE listitems SET position = position + 1 WHERE listid = $1 AND position >= $2", item.ListID, item.Position)
+	if err != nil {
+		return err
+	}
+	tag, err = tx.Exec("INSERT INTO listitems (listid, filmid, position, note) SELECT $1, id, $3, $4 FROM films WHERE id = $2",
+		item.ListID, item.FilmID, item.Position, item.Note)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrFilmNotFound
+	}
+	return tx.Commit()
+}
+
+// UpdateListNote replaces the note of a film on the list.
+func UpdateListNote(userID int, item structs.ListItem) error {
+	tag, err := Conn.Exec(`UPDATE listitems li SET note = $4 FROM lists l
+		WHERE l.id = li.listid AND li.listid = $1 AND li.filmid = $2 AND l.userid = $3`,
+		item.ListID, item.FilmID, userID, item.Note)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrListItemNotFound
+	}
+	return nil
+}
+
+// RemoveListItem takes a film off the list and closes the gap in positions.
+func RemoveListItem(userID, listID, filmID int) error {
+	tx, err := Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = lockList(tx, listID, userID)
+	if err != nil {
+		return err
+	}
+	var position int
+	err = tx.QueryRow("DELETE FROM listitems WHERE listid = $1 AND filmid = $2 RETURNING position", listID, filmID).Scan(&position)
+	if err == pgx.ErrNoRows {
+		return tx.Commit()
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE listitems SET position = position - 1 WHERE listid = $1 AND position > $2", listID, position)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReorderList sets the positions of all films on the list to their order in
+// filmIDs.
+func ReorderList(userID int, order structs.ListOrder) error {
+	tx, err := Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = lockList(tx, order.ListID, userID)
+	if err != nil {
+		return err
+	}
+	var size int
+	err = tx.QueryRow("SELECT count(*) FROM listitems WHERE listid = $1", order.ListID).Scan(&size)
+	if err != nil {
+		return err
+	}
+	if len(uniqueInts(order.FilmIDs)) != len(order.FilmIDs) || len(order.FilmIDs) != size {
+		return ErrListOrder
+	}
+	tag, err := tx.Exec(`UPDATE listitems li SET position = o.position
+		FROM unnest($2::int[]) WITH ORDINALITY AS o(filmid, position)
+		WHERE li.listid = $1 AND li.filmid = o.filmid`, order.ListID, order.FilmIDs)
+	if err != nil {
+		return err
+	}
+	if int(tag.RowsAffected()) != size {
+		return ErrListOrder
+	}
+	return tx.Commit()
+}
+
+// MarkWatchlisted sets OnWatchlist of every film in place for the user.
+func MarkWatchlisted(films []structs.Film, userID int) error {
+	if len(films) == 0 {
+		return nil
+	}
+	ids := make([]int, len(films))
+	for i, film := range films {
+		ids[i] = film.Id
+	}
+	rows, err := Conn.Query(`SELECT li.filmid FROM listitems li JOIN lists l ON l.id = li.listid
+		WHERE l.userid = $1 AND l.is_watchlist AND li.filmid = ANY($2)`, userID, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	watchlisted := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return err
+		}
+		watchlisted[id] = true
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	for i := range films {
+		films[i].OnWatchlist = watchlisted[films[i].Id]
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"FilmCollection/structs"
+	"errors"
+)
+
+// ErrReviewNotFound is returned by ModerateReview and ReportReview when the
+// review does not exist or is not visible.
+var ErrReviewNotFound = errors.New("review not found")
+
+// GetUserReviews returns every review of a user whatever its status, newest first.
+func GetUserReviews(userID int) ([]structs.Review, error) {
+	rows, err := Conn.Query("SELECT "+reviewColumns+reviewJoins+" WHERE r.userid = $1 ORDER BY r.created_at DESC, r.id", userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanReviews(rows)
+}
+
+// GetReviewsByStatus returns reviews with the given status, oldest first so
+// that the moderation queue is worked through in order of submission.
+func GetReviewsByStatus(status string, limit int) ([]structs.Review, error) {
+	rows, err := Conn.Query("SELECT "+reviewColumns+reviewJoins+" WHERE r.status = $1 ORDER BY r.updated_at, r.id LIMIT $2", status, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanReviews(rows)
+}
+
+// ModerateReview sets the status of a review and resolves its open reports.
+// It returns ErrReviewNotFound if there is no such review.
+func ModerateReview(id int, status, note string) error {
+	tx, err := Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	tag, err := tx.Exec("UPDATE reviews SET status = $2, moderation_note = NULLIF($3, '') WHERE id = $1", id, status, note)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrReviewNotFound
+	}
+	_, err = tx.Exec("UPDATE reviewreports SET resolved = true WHERE reviewid = $1", id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReportReview records a user's report about a published review. Reporting
+// the same review again replaces the reason and reopens the report. It
+// returns ErrReviewNotFound if the review is not visible to the reporter.
+func ReportReview(report structs.ReviewReport) error {
+	tag, err := Conn.Exec(`INSERT INTO reviewreports (reviewid, userid, reason)
+		SELECT id, $2, $3 FROM reviews WHERE id = $1 AND status = 'published'
+		ON CONFLICT (reviewid, userid) DO UPDATE SET reason = EXCLUDED.reason, resolved = false, created_at = now()`,
+		report.ReviewID, report.UserID, report.Reason)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrReviewNotFound
+	}
+	return nil
+}
+
+// GetReviewReports returns reports from all users, newest first. Resolved
+// reports are only included when asked for.
+func GetReviewReports(includeResolved bool, limit int) ([]structs.ReviewReport, error) {
+	rows, err := Conn.Query(`SELECT rr.id, rr.reviewid, rr.userid, u.login, rr.reason, rr.resolved, rr.created_at
+		FROM reviewreports rr JOIN users u ON u.id = rr.userid
+		WHERE $1 OR NOT rr.resolved
+		ORDER BY rr.created_at DESC, rr.id LIMIT $2`, includeResolved, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	reports := []structs.ReviewReport{}
+	for rows.Next() {
+		var report structs.ReviewReport
+		err = rows.Scan(&report.Id, &report.ReviewID, &report.UserID, &report.Login, &report.Reason, &report.Resolved, &report.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"FilmCollection/structs"
+	"github.com/jackc/pgx/pgtype"
+	"time"
+)
+
+// CrewByFilm returns the crew credits of every film in filmIDs with a single query.
+func CrewByFilm(filmIDs []int) (map[int][]structs.CrewCredit, error) {
+	result := make(map[int][]structs.CrewCredit, len(filmIDs))
+	if len(filmIDs) == 0 {
+		return result, nil
+	}
+	rows, err := Conn.Query(`SELECT filmid, personid, department, COALESCE(job, '') FROM crew
+		WHERE filmid = ANY($1) ORDER BY filmid, department, personid`, filmIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var credit structs.CrewCredit
+		err = rows.Scan(&credit.FilmID, &credit.PersonID, &credit.Department, &credit.Job)
+		if err != nil {
+			return nil, err
+		}
+		result[credit.FilmID] = append(result[credit.FilmID], credit)
+	}
+	return result, rows.Err()
+}
+
+// fillCrew sets Crew of every film in place with a single query.
+func fillCrew(films []structs.Film) error {
+	ids := make([]int, len(films))
+	for i, film := range films {
+		ids[i] = film.Id
+	}
+	crew, err := CrewByFilm(ids)
+	if err != nil {
+		return err
+	}
+	for i := range films {
+		films[i].Crew = crew[films[i].Id]
+	}
+	return nil
+}
+
+// AddCrewCredit links a person to a film in a non-acting department.
+func AddCrewCredit(filmID int, credit structs.CrewCredit) error {
+	_, err := Conn.Exec("INSERT INTO crew (filmid, personid, department, job) VALUES ($1, $2, $3, NULLIF($4, ''))",
+		filmID, credit.PersonID, credit.Department, credit.Job)
+	return err
+}
+
+// GetPersonPage returns a person with all of their acting and crew credits
+// grouped by department, newest films first, or pgx.ErrNoRows.
+func GetPersonPage(id int) (structs.PersonPage, error) {
+	var page structs.PersonPage
+	var birthDate time.Time
+	err := Conn.QueryRow("SELECT id, name, gender, birth_date FROM actors WHERE id = $1", id).
+		Scan(&page.Id, &page.Name, &page.Gender, &birthDate)
+	if err != nil {
+		return structs.PersonPage{}, err
+	}
+	page.BirthDate = structs.Date{Time: birthDate}
+	rows, err := Conn.Query(`SELECT 'acting', f.id, f.name, f.release_date, COALESCE(mc.character, '')
+			FROM moviecast mc JOIN films f ON f.id = mc.filmid WHERE mc.actorid = $1
+		UNION ALL
+		SELECT c.department, f.id, f.name, f.release_date, COALESCE(c.job, '')
+			FROM crew c JOIN films f ON f.id = c.filmid WHERE c.personid = $1
+		ORDER BY 1, 4 DESC NULLS LAST, 2`, id)
+	if err != nil {
+		return structs.PersonPage{}, err
+	}
+	defer rows.Close()
+	page.Credits = make(map[string][]structs.PersonCredit)
+	for rows.Next() {
+		var department string
+		var credit structs.PersonCredit
+		var releaseDate pgtype.Date
+		err = rows.Scan(&department, &credit.FilmID, &credit.FilmName, &releaseDate, &credit.Role)
+		if err != nil {
+			return structs.PersonPage{}, err
+		}
+		credit.ReleaseDate = structs.Date{Time: releaseDate.Time}
+		page.Credits[department] = append(page.Credits[department], credit)
+	}
+	return page, rows.Err()
+}
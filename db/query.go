@@ -0,0 +1,40 @@
+package db
+
+import (
+	"strconv"
+	"strings"
+)
+
+// query collects WHERE conditions together with their positional arguments,
+// so values coming from a request never end up inside the SQL text.
+type query struct {
+	where []string
+	args  []interface{}
+}
+
+// arg registers v as the next positional argument and returns its placeholder.
+func (q *query) arg(v interface{}) string {
+	q.args = append(q.args, v)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+// and adds cond to the WHERE clause. Every "?" in cond is replaced with the
+// placeholder of the corresponding value from args.
+func (q *query) and(cond string, args ...interface{}) {
+	var b strings.Builder
+	for _, v := range args {
+		i := strings.IndexByte(cond, '?')
+		b.WriteString(cond[:i])
+		b.WriteString(q.arg(v))
+		cond = cond[i+1:]
+	}
+	b.WriteString(cond)
+	q.where = append(q.where, b.String())
+}
+
+func (q *query) whereSQL() string {
+	if len(q.where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.where, " AND ")
+}
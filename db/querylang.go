@@ -0,0 +1,268 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// QueryTerm is a single condition of the film search language, e.g.
+// `rating>=8`, `year:1990..1999`, `actor:"Sergei Bodrov"`, `genre:thriller`, `tag:heist` or `-horror`.
+// Rating and year bounds are normalised to an inclusive Min/Max range.
+type QueryTerm struct {
+	Field   string
+	Text    string
+	ActorID int
+	Min     *int
+	Max     *int
+	Negated bool
+}
+
+// QuerySyntaxError points at the byte (1-based) of the query where parsing failed.
+type QuerySyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *QuerySyntaxError) Error() string {
+	return fmt.Sprintf("invalid keyword query at position %d: %s", e.Pos, e.Msg)
+}
+
+var queryFields = map[string]bool{"title": true, "rating": true, "year": true, "actor": true, "genre": true, "tag": true}
+
+// ParseFilmQuery splits s into terms. Words that don't start with a known
+// field name are matched against the film title, so plain keywords keep
+// working as before.
+func ParseFilmQuery(s string) ([]QueryTerm, error) {
+	p := queryParser{s: s}
+	var terms []QueryTerm
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.s) {
+			return terms, nil
+		}
+		term, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+}
+
+type queryParser struct {
+	s   string
+	pos int
+}
+
+func (p *queryParser) errorf(pos int, format string, args ...interface{}) error {
+	return &QuerySyntaxError{Pos: pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+// spaceAt tells whether the rune at pos is white space. Queries are UTF-8,
+// so single bytes of multibyte runes must not be tested on their own.
+func (p *queryParser) spaceAt(pos int) (bool, int) {
+	r, size := utf8.DecodeRuneInString(p.s[pos:])
+	return unicode.IsSpace(r), size
+}
+
+func (p *queryParser) skipSpaces() {
+	for p.pos < len(p.s) {
+		space, size := p.spaceAt(p.pos)
+		if !space {
+			return
+		}
+		p.pos += size
+	}
+}
+
+func (p *queryParser) term() (QueryTerm, error) {
+	var term QueryTerm
+	if p.s[p.pos] == '-' {
+		term.Negated = true
+		p.pos++
+		if space, _ := p.spaceAt(p.pos); p.pos >= len(p.s) || space {
+			return term, p.errorf(p.pos-1, "expected a term after '-'")
+		}
+	}
+	start := p.pos
+	if field, op, ok := p.fieldPrefix(); ok {
+		term.Field = field
+		valueStart := p.pos
+		value, err := p.value()
+		if err != nil {
+			return term, err
+		}
+		if value == "" {
+			return term, p.errorf(valueStart, "expected a value after %q", field+op)
+		}
+		return term, p.fill(&term, op, value, valueStart)
+	}
+	p.pos = start
+	value, err := p.value()
+	if err != nil {
+		return term, err
+	}
+	term.Field = "title"
+	term.Text = value
+	return term, nil
+}
+
+// fieldPrefix consumes `field op` if the input continues with a known field.
+func (p *queryParser) fieldPrefix() (string, string, bool) {
+	end := p.pos
+	for end < len(p.s) {
+		r, size := utf8.DecodeRuneInString(p.s[end:])
+		if !unicode.IsLetter(r) {
+			break
+		}
+		end += size
+	}
+	field := strings.ToLower(p.s[p.pos:end])
+	if !queryFields[field] {
+		return "", "", false
+	}
+	for _, op := range []string{">=", "<=", ":", "=", ">", "<"} {
+		if strings.HasPrefix(p.s[end:], op) {
+			p.pos = end + len(op)
+			return field, op, true
+		}
+	}
+	return "", "", false
+}
+
+// value reads a bare word or a double-quoted phrase.
+func (p *queryParser) value() (string, error) {
+	if p.pos < len(p.s) && p.s[p.pos] == '"' {
+		start := p.pos
+		end := strings.IndexByte(p.s[p.pos+1:], '"')
+		if end < 0 {
+			return "", p.errorf(start, "unterminated quote")
+		}
+		value := p.s[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		return value, nil
+	}
+	start := p.pos
+	end := strings.IndexFunc(p.s[p.pos:], unicode.IsSpace)
+	if end < 0 {
+		end = len(p.s) - p.pos
+	}
+	p.pos += end
+	return p.s[start:p.pos], nil
+}
+
+func (p *queryParser) fill(term *QueryTerm, op, value string, pos int) error {
+	switch term.Field {
+	case "title", "genre", "tag":
+		if op != ":" && op != "=" {
+			return p.errorf(pos-len(op), "operator %q is not supported for %s", op, term.Field)
+		}
+		term.Text = value
+	case "actor":
+		if op != ":" && op != "=" {
+			return p.errorf(pos-len(op), "operator %q is not supported for actor", op)
+		}
+		if id, err := strconv.Atoi(value); err == nil {
+			term.ActorID = id
+		} else {
+			term.Text = value
+		}
+	case "rating", "year":
+		return p.fillRange(term, op, value, pos)
+	}
+	return nil
+}
+
+func (p *queryParser) fillRange(term *QueryTerm, op, value string, pos int) error {
+	number := func(s string, at int) (*int, error) {
+		if s == "" {
+			return nil, nil
+		}
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, p.errorf(at, "expected a number for %s, got %q", term.Field, s)
+		}
+		return &v, nil
+	}
+	if (op == ":" || op == "=") && strings.Contains(value, "..") {
+		i := strings.Index(value, "..")
+		var err error
+		if term.Min, err = number(value[:i], pos); err != nil {
+			return err
+		}
+		if term.Max, err = number(value[i+2:], pos+i+2); err != nil {
+			return err
+		}
+		if term.Min == nil && term.Max == nil {
+			return p.errorf(pos, "range for %s needs at least one bound", term.Field)
+		}
+		if term.Min != nil && term.Max != nil && *term.Min > *term.Max {
+			return p.errorf(pos, "empty range %q", value)
+		}
+		return nil
+	}
+	v, err := number(value, pos)
+	if err != nil {
+		return err
+	}
+	switch op {
+	case ":", "=":
+		term.Min, term.Max = v, v
+	case ">=":
+		term.Min = v
+	case ">":
+		*v++
+		term.Min = v
+	case "<=":
+		term.Max = v
+	case "<":
+		*v--
+		term.Max = v
+	}
+	return nil
+}
+
+// apply adds the SQL for a single term to q.
+func (t QueryTerm) apply(q *query) {
+	var sub query
+	sub.args = q.args
+	switch t.Field {
+	case "title":
+		sub.and("films.name ILIKE ?", "%"+t.Text+"%")
+	case "rating":
+		if t.Min != nil {
+			sub.and("films.rating >= ?", *t.Min)
+		}
+		if t.Max != nil {
+			sub.and("films.rating <= ?", *t.Max)
+		}
+	case "year":
+		if t.Min != nil {
+			sub.and("films.release_date >= make_date(?, 1, 1)", *t.Min)
+		}
+		if t.Max != nil {
+			sub.and("films.release_date < make_date(?::int + 1, 1, 1)", *t.Max)
+		}
+	case "genre":
+		sub.and(`EXISTS (SELECT 1 FROM filmgenres fg WHERE fg.filmid = films.id AND fg.genreid IN (WITH RECURSIVE subtree(id) AS (
+				SELECT id FROM genres WHERE name ILIKE ?
+				UNION SELECT g.id FROM genres g JOIN subtree ON g.parent_id = subtree.id
+			) SELECT id FROM subtree))`, t.Text)
+	case "tag":
+		sub.and("EXISTS (SELECT 1 FROM filmtags ft JOIN tags t ON t.id = ft.tagid WHERE ft.filmid = films.id AND t.name = ?)", NormalizeTag(t.Text))
+	case "actor":
+		if t.Text == "" {
+			sub.and("EXISTS (SELECT 1 FROM moviecast mc WHERE mc.filmid = films.id AND mc.actorid = ?)", t.ActorID)
+		} else {
+			sub.and("EXISTS (SELECT 1 FROM moviecast mc JOIN actors a ON a.id = mc.actorid WHERE mc.filmid = films.id AND a.name ILIKE ?)", "%"+t.Text+"%")
+		}
+	}
+	q.args = sub.args
+	cond := strings.Join(sub.where, " AND ")
+	if t.Negated {
+		cond = "NOT COALESCE((" + cond + "), false)"
+	}
+	q.where = append(q.where, cond)
+}
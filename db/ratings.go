@@ -0,0 +1,171 @@
+package db
+
+import (
+	"FilmCollection/structs"
+	"github.com/jackc/pgx"
+)
+
+// fillCommunityRatings sets CommunityRating and Votes of every film in place
+// with a single query.
+func fillCommunityRatings(films []structs.Film) error {
+	if len(films) == 0 {
+		return nil
+	}
+	ids := make([]int, len(films))
+	for i, film := range films {
+		ids[i] = film.Id
+	}
+	rows, err := Conn.Query("SELECT filmid, avg(rating)::float8, count(*) FROM userratings WHERE filmid = ANY($1) GROUP BY filmid", ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	type aggregate struct {
+		average float64
+		votes   int
+	}
+	byFilm := make(map[int]aggregate, len(ids))
+	for rows.Next() {
+		var filmID int
+		var a aggregate
+		err = rows.Scan(&filmID, &a.average, &a.votes)
+		if err != nil {
+			return err
+		}
+		byFilm[filmID] = a
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	for i := range films {
+		a := byFilm[films[i].Id]
+		films[i].CommunityRating = a.average
+		films[i].Votes = a.votes
+	}
+	return nil
+}
+
+// RateFilm stores or replaces the user's rating of a film and updates the
+// user's taste profile.
+func RateFilm(rating structs.UserRating) error {
+	tx, err := Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`INSERT INTO userratings (userid, filmid, rating) VALUES ($1, $2, $3)
+		ON CONFLICT (userid, filmid) DO UPDATE SET rating = EXCLUDED.rating, rated_at = now()`,
+		rating.UserID, rating.FilmID, rating.Rating)
+	if err != nil {
+		return err
+	}
+	err = refreshTaste(tx, rating.UserID, rating.FilmID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteRating removes the user's rating of a film and updates the user's
+// taste profile.
+func DeleteRating(userID, filmID int) error {
+	tx, err := Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec("DELETE FROM userratings WHERE userid = $1 AND filmid = $2", userID, filmID)
+	if err != nil {
+		return err
+	}
+	err = refreshTaste(tx, userID, filmID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetUserRatings returns every rating of a user, most recent first.
+func GetUserRatings(userID int) ([]structs.UserRating, error) {
+	rows, err := Conn.Query("SELECT filmid, userid, rating, rated_at FROM userratings WHERE userid = $1 ORDER BY rated_at DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ratings := []structs.UserRating{}
+	for rows.Next() {
+		var rating structs.UserRating
+		err = rows.Scan(&rating.FilmID, &rating.UserID, &rating.Rating, &rating.RatedAt)
+		if err != nil {
+			return nil, err
+		}
+		ratings = append(ratings, rating)
+	}
+	return ratings, rows.Err()
+}
+
+// SaveReview stores or replaces the user's review of a film and returns its
+// id. An edited review takes the status of the new body, except that a
+// review a moderator rejected or hid goes back to pending with its
+// moderation note kept, so editing it cannot publish it again.
+func SaveReview(review structs.Review) (int, error) {
+	var id int
+	err := Conn.QueryRow(`INSERT INTO reviews (userid, filmid, body, status, moderation_note) VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+		ON CONFLICT (userid, filmid) DO UPDATE SET body = EXCLUDED.body,
+			status = CASE WHEN reviews.status IN ('rejected', 'hidden') THEN 'pending' ELSE EXCLUDED.status END,
+			moderation_note = CASE WHEN reviews.status IN ('rejected', 'hidden') THEN reviews.moderation_note ELSE EXCLUDED.moderation_note END,
+			updated_at = now()
+		RETURNING id`, review.UserID, review.FilmID, review.Body, review.Status, review.ModerationNote).Scan(&id)
+	return id, err
+}
+
+// DeleteReview removes the user's review of a film.
+func DeleteReview(userID, filmID int) error {
+	_, err := Conn.Exec("DELETE FROM reviews WHERE userid = $1 AND filmid = $2", userID, filmID)
+	return err
+}
+
+const reviewColumns = `r.id, r.filmid, r.userid, u.login, r.body, COALESCE(ur.rating, 0), r.created_at, r.updated_at, r.status, COALESCE(r.moderation_note, '')`
+
+const reviewJoins = ` FROM reviews r JOIN users u ON u.id = r.userid
+	LEFT JOIN userratings ur ON ur.userid = r.userid AND ur.filmid = r.filmid`
+
+// GetReviewByID returns a single review or pgx.ErrNoRows.
+func GetReviewByID(id int) (structs.Review, error) {
+	rows, err := Conn.Query("SELECT "+reviewColumns+reviewJoins+" WHERE r.id = $1", id)
+	if err != nil {
+		return structs.Review{}, err
+	}
+	reviews, err := scanReviews(rows)
+	if err != nil {
+		return structs.Review{}, err
+	}
+	if len(reviews) == 0 {
+		return structs.Review{}, pgx.ErrNoRows
+	}
+	return reviews[0], nil
+}
+
+// GetFilmReviews returns the published reviews of a film, newest first.
+func GetFilmReviews(filmID, limit int) ([]structs.Review, error) {
+	rows, err := Conn.Query("SELECT "+reviewColumns+reviewJoins+" WHERE r.filmid = $1 AND r.status = 'published' ORDER BY r.created_at DESC, r.id LIMIT $2", filmID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanReviews(rows)
+}
+
+// scanReviews reads rows selected with reviewColumns and closes them.
+func scanReviews(rows *pgx.Rows) ([]structs.Review, error) {
+	defer rows.Close()
+	reviews := []structs.Review{}
+	for rows.Next() {
+		var review structs.Review
+		err := rows.Scan(&review.Id, &review.FilmID, &review.UserID, &review.Login, &review.Body, &review.Rating, &review.CreatedAt, &review.UpdatedAt, &review.Status, &review.ModerationNote)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, rows.Err()
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"FilmCollection/structs"
+	"github.com/jackc/pgx"
+	"time"
+)
+
+// yearEntriesSQL selects the diary entries of user $1 watched in [$2, $3)
+// with the runtime of the film and whether the watch is a rewatch.
+const yearEntriesSQL = `WITH entries AS (
+		SELECT d.filmid, d.watched_on, d.rating, f.runtime,
+			d.rewatch OR EXISTS (
+				SELECT 1 FROM diary p WHERE p.userid = d.userid AND p.filmid = d.filmid
+					AND (p.watched_on < d.watched_on OR (p.watched_on = d.watched_on AND p.id < d.id))
+			) AS rewatch
+		FROM diary d JOIN films f ON f.id = d.filmid
+		WHERE d.userid = $1 AND d.watched_on >= $2 AND d.watched_on < $3
+	) `
+
+// GetYearReview computes the viewing statistics of a user for a year.
+// TopActors holds at most topActors actors.
+func GetYearReview(userID, year, topActors int) (structs.YearReview, error) {
+	review := structs.YearReview{Year: year}
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(1, 0, 0)
+	err := Conn.QueryRow(yearEntriesSQL+`SELECT count(*), count(DISTINCT filmid),
+			count(*) FILTER (WHERE NOT rewatch), count(*) FILTER (WHERE rewatch),
+			COALESCE(sum(runtime), 0), count(*) FILTER (WHERE runtime IS NULL),
+			COALESCE(avg(rating), 0)::float8
+		FROM entries`, userID, from, to).Scan(&review.FilmsWatched, &review.UniqueFilms,
+		&review.FirstWatches, &review.Rewatches, &review.TotalRuntime, &review.UnknownRuntime, &review.AverageRating)
+	if err != nil {
+		return review, err
+	}
+
+	ratings := make(map[int]int)
+	rows, err := Conn.Query(yearEntriesSQL+"SELECT rating, count(*) FROM entries WHERE rating IS NOT NULL GROUP BY rating", userID, from, to)
+	if err != nil {
+		return review, err
+	}
+	err = scanCounts(rows, ratings)
+	if err != nil {
+		return review, err
+	}
+	for rating := 1; rating <= 10; rating++ {
+		review.RatingDistribution = append(review.RatingDistribution, structs.RatingCount{Rating: rating, Count: ratings[rating]})
+	}
+
+	months := make(map[int]int)
+	rows, err = Conn.Query(yearEntriesSQL+"SELECT extract(month FROM watched_on)::int, count(*) FROM entries GROUP BY 1", userID, from, to)
+	if err != nil {
+		return review, err
+	}
+	err = scanCounts(rows, months)
+	if err != nil {
+		return review, err
+	}
+	for month := 1; month <= 12; month++ {
+		review.Months = append(review.Months, structs.MonthCount{Month: month, Count: months[month]})
+		if months[month] > months[review.BusiestMonth] {
+			review.BusiestMonth = month
+		}
+	}
+
+	rows, err = Conn.Query(yearEntriesSQL+`SELECT a.id, a.name, count(*) FROM entries e
+		JOIN moviecast mc ON mc.filmid = e.filmid JOIN actors a ON a.id = mc.actorid
+		GROUP BY a.id, a.name ORDER BY count(*) DESC, a.name, a.id LIMIT $4`, userID, from, to, topActors)
+	if err != nil {
+		return review, err
+	}
+	defer rows.Close()
+	review.TopActors = []structs.ActorCount{}
+	for rows.Next() {
+		var actor structs.ActorCount
+		err = rows.Scan(&actor.ActorID, &actor.Name, &actor.Count)
+		if err != nil {
+			return review, err
+		}
+		review.TopActors = append(review.TopActors, actor)
+	}
+	return review, rows.Err()
+}
+
+// scanCounts reads (key, count) rows into counts and closes them.
+func scanCounts(rows *pgx.Rows, counts map[int]int) error {
+	defer rows.Close()
+	for rows.Next() {
+		var key, count int
+		err := rows.Scan(&key, &count)
+		if err != nil {
+			return err
+		}
+		counts[key] = count
+	}
+	return rows.Err()
+}
@@ -0,0 +1,229 @@
+package db
+
+import (
+	"FilmCollection/structs"
+	"github.com/jackc/pgx"
+	"sort"
+)
+
+// The taste profile of a user is a weight per actor, genre and tag, the sum
+// of the signals of the films the user rated or watched that have it. A
+// rating maps to a signal from -1 (1/10) to 1 (10/10); a watch without any
+// rating counts as a mild 0.25. TasteSignals remembers the signal applied for
+// every film, so a new rating or diary entry only adds the difference for
+// that one film instead of recomputing the profile.
+//
+// The difference is added for the film's actors, genres and tags at that
+// moment. When they change later, e.g. through UpdateFilm, MergeTags or the
+// bulk imports, the profile drifts from the signals until
+// RebuildTasteProfiles recomputes it, which cmd/taste does on demand.
+
+// RecommendationWeights scale the profile weights of each feature kind.
+var RecommendationWeights = map[string]float64{"actor": 1, "genre": 0.5, "tag": 0.5}
+
+// filmFeaturesSQL selects (filmid, kind, featureid, name) of every film.
+const filmFeaturesSQL = `SELECT DISTINCT mc.filmid, 'actor' AS kind, a.id AS featureid, a.name
+		FROM moviecast mc JOIN actors a ON a.id = mc.actorid
+	UNION ALL SELECT fg.filmid, 'genre', g.id, g.name FROM filmgenres fg JOIN genres g ON g.id = fg.genreid
+	UNION ALL SELECT ft.filmid, 'tag', tg.id, tg.name FROM filmtags ft JOIN tags tg ON tg.id = ft.tagid`
+
+// signalSQL computes the signal of the film p.filmid for the user p.userid,
+// 0 when the user neither rated nor watched it.
+const signalSQL = `COALESCE(
+		(SELECT (rating - 5.5) / 4.5 FROM userratings ur WHERE ur.userid = p.userid AND ur.filmid = p.filmid),
+		(SELECT (rating - 5.5) / 4.5 FROM diary d WHERE d.userid = p.userid AND d.filmid = p.filmid AND rating IS NOT NULL
+			ORDER BY watched_on DESC, id DESC LIMIT 1),
+		(SELECT 0.25 FROM diary d WHERE d.userid = p.userid AND d.filmid = p.filmid LIMIT 1),
+		0)::float8`
+
+// refreshTaste brings the contribution of one film to the user's taste
+// profile in line with the user's current rating and diary entries of it.
+// It runs in the transaction of the rating or diary change, so the change
+// and the profile are stored together or not at all.
+func refreshTaste(tx *pgx.Tx, userID, filmID int) error {
+	var signal float64
+	err := tx.QueryRow("SELECT "+signalSQL+" FROM (SELECT $1::int AS userid, $2::int AS filmid) p", userID, filmID).Scan(&signal)
+	if err != nil {
+		return err
+	}
+	var applied float64
+	err = tx.QueryRow("SELECT signal FROM tastesignals WHERE userid = $1 AND filmid = $2 FOR UPDATE", userID, filmID).Scan(&applied)
+	if err != nil && err != pgx.ErrNoRows {
+		return err
+	}
+	if signal == applied {
+		return nil
+	}
+	_, err = tx.Exec(`INSERT INTO tasteprofile (userid, kind, featureid, weight)
+		SELECT $1, kind, featureid, $3 FROM (`+filmFeaturesSQL+`) features WHERE filmid = $2
+		ON CONFLICT (userid, kind, featureid) DO UPDATE SET weight = tasteprofile.weight + EXCLUDED.weight`,
+		userID, filmID, signal-applied)
+	if err != nil {
+		return err
+	}
+	if signal == 0 {
+		_, err = tx.Exec("DELETE FROM tastesignals WHERE userid = $1 AND filmid = $2", userID, filmID)
+	} else {
+		_, err = tx.Exec(`INSERT INTO tastesignals (userid, filmid, signal) VALUES ($1, $2, $3)
+			ON CONFLICT (userid, filmid) DO UPDATE SET signal = EXCLUDED.signal`, userID, filmID, signal)
+	}
+	return err
+}
+
+// backfillTaste rebuilds the taste profiles when a stored signal is missing
+// or differs from the ratings and diary, as for those stored before
+// profiles existed or written to the tables directly.
+func backfillTaste() error {
+	var stale bool
+	err := Conn.QueryRow(`SELECT EXISTS (
+		SELECT 1 FROM (SELECT userid, filmid FROM userratings UNION SELECT userid, filmid FROM diary
+			UNION SELECT userid, filmid FROM tastesignals) p
+		LEFT JOIN tastesignals ts ON ts.userid = p.userid AND ts.filmid = p.filmid
+		WHERE COALESCE(ts.signal, 0) <> ` + signalSQL + `)`).Scan(&stale)
+	if err != nil || !stale {
+		return err
+	}
+	_, err = RebuildTasteProfiles()
+	return err
+}
+
+// RebuildTasteProfiles recomputes every signal from the ratings and diary
+// and every profile from the signals and the films' current actors, genres
+// and tags. It returns the number of users with a profile.
+func RebuildTasteProfiles() (int, error) {
+	tx, err := Conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec("LOCK TABLE tastesignals, tasteprofile IN EXCLUSIVE MODE")
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("DELETE FROM tastesignals")
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(`INSERT INTO tastesignals (userid, filmid, signal)
+		SELECT p.userid, p.filmid, ` + signalSQL + `
+		FROM (SELECT userid, filmid FROM userratings UNION SELECT userid, filmid FROM diary) p`)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("DELETE FROM tastesignals WHERE signal = 0")
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("DELETE FROM tasteprofile")
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(`INSERT INTO tasteprofile (userid, kind, featureid, weight)
+		SELECT ts.userid, features.kind, features.featureid, sum(ts.signal)
+		FROM tastesignals ts JOIN (` + filmFeaturesSQL + `) features ON features.filmid = ts.filmid
+		GROUP BY ts.userid, features.kind, features.featureid`)
+	if err != nil {
+		return 0, err
+	}
+	var users int
+	err = tx.QueryRow("SELECT count(DISTINCT userid) FROM tasteprofile").Scan(&users)
+	if err != nil {
+		return 0, err
+	}
+	return users, tx.Commit()
+}
+
+// RecommendationFilter narrows the recommended films. GenreIDs include
+// their subgenres; MaxRuntime excludes films of unknown length.
+type RecommendationFilter struct {
+	GenreIDs   []int
+	MaxRuntime *int
+}
+
+// GetRecommendations ranks the films the user has neither rated nor watched
+// by the sum of the profile weights of their actors, genres and tags.
+func GetRecommendations(userID int, filter RecommendationFilter, limit int) ([]structs.Recommendation, error) {
+	var q query
+	q.and("tp.userid = ?", userID)
+	q.and("tp.weight <> 0")
+	q.and("NOT EXISTS (SELECT 1 FROM userratings ur WHERE ur.userid = tp.userid AND ur.filmid = features.filmid)")
+	q.and("NOT EXISTS (SELECT 1 FROM diary d WHERE d.userid = tp.userid AND d.filmid = features.filmid)")
+	if len(filter.GenreIDs) > 0 {
+		q.and("EXISTS (SELECT 1 FROM filmgenres fg WHERE fg.filmid = features.filmid AND fg.genreid IN ("+genreSubtreeSQL+"))", filter.GenreIDs)
+	}
+	if filter.MaxRuntime != nil {
+		q.and("EXISTS (SELECT 1 FROM films f WHERE f.id = features.filmid AND f.runtime <= ?)", *filter.MaxRuntime)
+	}
+	rows, err := Conn.Query(`SELECT features.filmid, features.kind, features.name, tp.weight
+		FROM (`+filmFeaturesSQL+`) features
+		JOIN tasteprofile tp ON tp.kind = features.kind AND tp.featureid = features.featureid`+q.whereSQL(), q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	type contribution struct {
+		kind, name string
+		points     float64
+	}
+	scores := make(map[int]float64)
+	contributions := make(map[int][]contribution)
+	for rows.Next() {
+		var filmID int
+		var c contribution
+		var weight float64
+		err = rows.Scan(&filmID, &c.kind, &c.name, &weight)
+		if err != nil {
+			return nil, err
+		}
+		c.points = weight * RecommendationWeights[c.kind]
+		scores[filmID] += c.points
+		contributions[filmID] = append(contributions[filmID], c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	var ids []int
+	for id, score := range scores {
+		if score > 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	films, err := GetFilmsByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	recommendations := []structs.Recommendation{}
+	for _, id := range ids {
+		film, ok := films[id]
+		if !ok {
+			continue
+		}
+		cs := contributions[id]
+		sort.SliceStable(cs, func(i, j int) bool { return cs[i].points > cs[j].points })
+		reasons := []string{}
+		for _, c := range cs {
+			if c.points <= 0 || len(reasons) == 3 {
+				break
+			}
+			switch c.kind {
+			case "actor":
+				reasons = append(reasons, "you liked films with "+c.name)
+			default:
+				reasons = append(reasons, "you liked "+c.kind+" "+c.name)
+			}
+		}
+		recommendations = append(recommendations, structs.Recommendation{Film: film, Score: scores[id], Reasons: reasons})
+	}
+	return recommendations, nil
+}
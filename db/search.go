@@ -0,0 +1,156 @@
+package db
+
+import (
+	"FilmCollection/structs"
+	"time"
+)
+
+// FilmFilter describes the optional conditions accepted by SearchFilms.
+// Nil pointers and empty slices mean "no restriction".
+type FilmFilter struct {
+	Query        []QueryTerm
+	MinRating    *int
+	MaxRating    *int
+	ReleasedFrom *time.Time
+	ReleasedTo   *time.Time
+	ActorIDs     []int
+	AllActors    bool
+	MinCast      *int
+	MaxCast      *int
+	CrewPersonID *int
+	Department   string
+	GenreIDs     []int
+	Tags         []string
+	ExcludeTags  []string
+}
+
+// ActorFilter describes the optional conditions accepted by SearchActors.
+type ActorFilter struct {
+	Keyword      string
+	Gender       string
+	BornFromYear *int
+	BornToYear   *int
+	FilmID       *int
+}
+
+const castSizeSQL = "(SELECT count(*) FROM moviecast mc WHERE mc.filmid = films.id)"
+
+func (f FilmFilter) apply(q *query) {
+	for _, term := range f.Query {
+		term.apply(q)
+	}
+	if f.MinRating != nil {
+		q.and("films.rating >= ?", *f.MinRating)
+	}
+	if f.MaxRating != nil {
+		q.and("films.rating <= ?", *f.MaxRating)
+	}
+	if f.ReleasedFrom != nil {
+		q.and("films.release_date >= ?", *f.ReleasedFrom)
+	}
+	if f.ReleasedTo != nil {
+		q.and("films.release_date <= ?", *f.ReleasedTo)
+	}
+	if len(f.ActorIDs) > 0 {
+		if f.AllActors {
+			ids := uniqueInts(f.ActorIDs)
+			q.and("(SELECT count(DISTINCT mc.actorid) FROM moviecast mc WHERE mc.filmid = films.id AND mc.actorid = ANY(?)) = ?", ids, len(ids))
+		} else {
+			q.and("EXISTS (SELECT 1 FROM moviecast mc WHERE mc.filmid = films.id AND mc.actorid = ANY(?))", f.ActorIDs)
+		}
+	}
+	if f.MinCast != nil {
+		q.and(castSizeSQL+" >= ?", *f.MinCast)
+	}
+	if f.MaxCast != nil {
+		q.and(castSizeSQL+" <= ?", *f.MaxCast)
+	}
+	if len(f.GenreIDs) > 0 {
+		q.and("EXISTS (SELECT 1 FROM filmgenres fg WHERE fg.filmid = films.id AND fg.genreid IN ("+genreSubtreeSQL+"))", f.GenreIDs)
+	}
+	if len(f.Tags) > 0 {
+		tags := normalizeTags(f.Tags)
+		q.and("(SELECT count(*) FROM filmtags ft JOIN tags t ON t.id = ft.tagid WHERE ft.filmid = films.id AND t.name = ANY(?)) = ?", tags, len(tags))
+	}
+	if len(f.ExcludeTags) > 0 {
+		q.and("NOT EXISTS (SELECT 1 FROM filmtags ft JOIN tags t ON t.id = ft.tagid WHERE ft.filmid = films.id AND t.name = ANY(?))", normalizeTags(f.ExcludeTags))
+	}
+	if f.CrewPersonID != nil {
+		if f.Department == "" {
+			q.and("EXISTS (SELECT 1 FROM crew c WHERE c.filmid = films.id AND c.personid = ?)", *f.CrewPersonID)
+		} else {
+			q.and("EXISTS (SELECT 1 FROM crew c WHERE c.filmid = films.id AND c.personid = ? AND c.department = ?)", *f.CrewPersonID, f.Department)
+		}
+	}
+}
+
+func (f ActorFilter) apply(q *query) {
+	q.and("actors.name ILIKE ?", "%"+f.Keyword+"%")
+	if f.Gender != "" {
+		q.and("actors.gender ILIKE ?", f.Gender)
+	}
+	if f.BornFromYear != nil {
+		q.and("actors.birth_date >= make_date(?, 1, 1)", *f.BornFromYear)
+	}
+	if f.BornToYear != nil {
+		q.and("actors.birth_date < make_date(?::int + 1, 1, 1)", *f.BornToYear)
+	}
+	if f.FilmID != nil {
+		q.and("EXISTS (SELECT 1 FROM moviecast mc WHERE mc.actorid = actors.id AND mc.filmid = ?)", *f.FilmID)
+	}
+}
+
+// SearchFilms returns a page of films matching filter ordered by sort,
+// together with their relations, in a constant number of queries.
+func SearchFilms(filter FilmFilter, sort []SortKey, limit int) ([]structs.Film, error) {
+	var q query
+	filter.apply(&q)
+	sql := "SELECT " + filmColumns + " FROM films" +
+		q.whereSQL() + orderBy(sort, FilmSortColumns) + " LIMIT " + q.arg(limit)
+	rows, err := Conn.Query(sql, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	films, err := scanFilms(rows)
+	if err != nil {
+		return nil, err
+	}
+	return films, fillFilmRelations(films)
+}
+
+// SearchActors returns a page of actors matching filter ordered by sort,
+// together with their filmography, in two queries.
+func SearchActors(filter ActorFilter, sort []SortKey, limit int) ([]structs.Actor, error) {
+	var q query
+	filter.apply(&q)
+	sql := "SELECT " + actorColumns + " FROM actors" +
+		q.whereSQL() + orderBy(sort, ActorSortColumns) + " LIMIT " + q.arg(limit)
+	rows, err := Conn.Query(sql, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	actors, err := scanActors(rows)
+	if err != nil {
+		return nil, err
+	}
+	return actors, fillFilmography(actors)
+}
+
+func direction(desc bool) string {
+	if desc {
+		return " DESC"
+	}
+	return " ASC"
+}
+
+func uniqueInts(values []int) []int {
+	seen := make(map[int]bool, len(values))
+	var unique []int
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
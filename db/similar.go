@@ -0,0 +1,143 @@
+package db
+
+import (
+	"FilmCollection/structs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SimilarityWeights are the points a candidate film gets per shared actor,
+// genre and tag, and at most for being released in the same year and for
+// having the same rating. Year points fade out over YearWindow years and
+// rating points over RatingWindow points.
+var SimilarityWeights = struct {
+	Actor, Genre, Tag, Year, Rating float64
+	YearWindow, RatingWindow        int
+}{
+	Actor: 3, Genre: 2, Tag: 1, Year: 1, Rating: 1,
+	YearWindow: 10, RatingWindow: 5,
+}
+
+// similarCandidatesSQL selects every film sharing at least one actor, genre
+// or tag with film $1, with the names of the shared ones and the distance in
+// release year and rating (-1 when unknown).
+const similarCandidatesSQL = `WITH target AS (
+		SELECT id, rating, release_date FROM films WHERE id = $1
+	), shared_actors AS (
+		SELECT o.filmid, array_agg(a.name ORDER BY a.name) AS names
+		FROM moviecast m JOIN moviecast o ON o.actorid = m.actorid AND o.filmid <> m.filmid
+		JOIN actors a ON a.id = m.actorid
+		WHERE m.filmid = $1 GROUP BY o.filmid
+	), shared_genres AS (
+		SELECT o.filmid, array_agg(g.name ORDER BY g.name) AS names
+		FROM filmgenres m JOIN filmgenres o ON o.genreid = m.genreid AND o.filmid <> m.filmid
+		JOIN genres g ON g.id = m.genreid
+		WHERE m.filmid = $1 GROUP BY o.filmid
+	), shared_tags AS (
+		SELECT o.filmid, array_agg(tg.name ORDER BY tg.name) AS names
+		FROM filmtags m JOIN filmtags o ON o.tagid = m.tagid AND o.filmid <> m.filmid
+		JOIN tags tg ON tg.id = m.tagid
+		WHERE m.filmid = $1 GROUP BY o.filmid
+	)
+	SELECT f.id, COALESCE(sa.names, '{}'), COALESCE(sg.names, '{}'), COALESCE(st.names, '{}'),
+		COALESCE(abs(extract(year FROM f.release_date) - extract(year FROM t.release_date))::int, -1),
+		COALESCE(abs(f.rating - t.rating), -1)
+	FROM films f CROSS JOIN target t
+	LEFT JOIN shared_actors sa ON sa.filmid = f.id
+	LEFT JOIN shared_genres sg ON sg.filmid = f.id
+	LEFT JOIN shared_tags st ON st.filmid = f.id
+	WHERE f.id <> t.id AND (sa.filmid IS NOT NULL OR sg.filmid IS NOT NULL OR st.filmid IS NOT NULL)`
+
+// GetSimilarFilms returns up to limit films ranked by their similarity to
+// film id. The caller should check that the film exists.
+func GetSimilarFilms(id, limit int) ([]structs.SimilarFilm, error) {
+	rows, err := Conn.Query(similarCandidatesSQL, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var similar []structs.SimilarFilm
+	for rows.Next() {
+		var candidate structs.SimilarFilm
+		var actors, genres, tags []string
+		var years, ratings int
+		err = rows.Scan(&candidate.Film.Id, &actors, &genres, &tags, &years, &ratings)
+		if err != nil {
+			return nil, err
+		}
+		candidate.Score, candidate.Reasons = similarityScore(actors, genres, tags, years, ratings)
+		similar = append(similar, candidate)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	sort.SliceStable(similar, func(i, j int) bool {
+		if similar[i].Score != similar[j].Score {
+			return similar[i].Score > similar[j].Score
+		}
+		return similar[i].Film.Id < similar[j].Film.Id
+	})
+	if len(similar) > limit {
+		similar = similar[:limit]
+	}
+	ids := make([]int, len(similar))
+	for i, s := range similar {
+		ids[i] = s.Film.Id
+	}
+	films, err := GetFilmsByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	result := []structs.SimilarFilm{}
+	for _, s := range similar {
+		if film, ok := films[s.Film.Id]; ok {
+			s.Film = film
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
+
+// similarityScore weighs the shared actors, genres and tags and the distance
+// in release year and rating of a candidate. A negative distance is unknown.
+func similarityScore(actors, genres, tags []string, years, ratings int) (float64, []string) {
+	w := SimilarityWeights
+	score := w.Actor*float64(len(actors)) + w.Genre*float64(len(genres)) + w.Tag*float64(len(tags))
+	reasons := []string{}
+	for _, shared := range []struct {
+		names []string
+		noun  string
+	}{{actors, "actor"}, {genres, "genre"}, {tags, "tag"}} {
+		if len(shared.names) == 0 {
+			continue
+		}
+		reasons = append(reasons, plural(len(shared.names), "shared "+shared.noun)+": "+strings.Join(shared.names, ", "))
+	}
+	if years >= 0 && years < w.YearWindow {
+		score += w.Year * float64(w.YearWindow-years) / float64(w.YearWindow)
+		if years == 0 {
+			reasons = append(reasons, "released the same year")
+		} else {
+			reasons = append(reasons, "released "+plural(years, "year")+" apart")
+		}
+	}
+	if ratings >= 0 && ratings < w.RatingWindow {
+		score += w.Rating * float64(w.RatingWindow-ratings) / float64(w.RatingWindow)
+		if ratings == 0 {
+			reasons = append(reasons, "same rating")
+		} else {
+			reasons = append(reasons, "rating within "+plural(ratings, "point"))
+		}
+	}
+	return score, reasons
+}
+
+// plural formats a count with its noun, e.g. "3 shared actors".
+func plural(n int, noun string) string {
+	if n != 1 {
+		noun += "s"
+	}
+	return strconv.Itoa(n) + " " + noun
+}
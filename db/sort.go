@@ -0,0 +1,69 @@
+package db
+
+import (
+	"errors"
+	"strings"
+)
+
+// SortKey is one entry of an ORDER BY list. Name is a key of the entity's
+// sort whitelist, never raw SQL.
+type SortKey struct {
+	Name string
+	Desc bool
+}
+
+// FilmSortColumns and ActorSortColumns map the public sort keys to SQL.
+// Besides plain columns they contain derived keys computed from moviecast.
+var FilmSortColumns = map[string]string{
+	"id":               "films.id",
+	"name":             "films.name",
+	"description":      "films.description",
+	"rating":           "films.rating",
+	"release_date":     "films.release_date",
+	"runtime":          "films.runtime",
+	"cast_size":        castSizeSQL,
+	"community_rating": "(SELECT avg(ur.rating) FROM userratings ur WHERE ur.filmid = films.id)",
+}
+
+var ActorSortColumns = map[string]string{
+	"id":         "actors.id",
+	"name":       "actors.name",
+	"gender":     "actors.gender",
+	"birth_date": "actors.birth_date",
+	"film_count": "(SELECT count(*) FROM moviecast mc WHERE mc.actorid = actors.id)",
+}
+
+// ParseSort parses a list like "-rating,release_date,name" where a leading
+// "-" means descending order. Every key must be present in columns.
+func ParseSort(s string, columns map[string]string) ([]SortKey, error) {
+	var keys []SortKey
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		key := SortKey{Name: part}
+		if strings.HasPrefix(part, "-") {
+			key = SortKey{Name: part[1:], Desc: true}
+		} else if strings.HasPrefix(part, "+") {
+			key.Name = part[1:]
+		}
+		if _, ok := columns[key.Name]; !ok {
+			return nil, errors.New("invalid sort key " + `"` + part + `"`)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// orderBy renders keys as an ORDER BY clause, adding the id of the entity as
+// a final tiebreaker so that equal values always come back in the same order.
+func orderBy(keys []SortKey, columns map[string]string) string {
+	var parts []string
+	hasID := false
+	for _, key := range keys {
+		parts = append(parts, columns[key.Name]+direction(key.Desc)+" NULLS LAST")
+		hasID = hasID || key.Name == "id"
+	}
+	if !hasID {
+		parts = append(parts, columns["id"]+" ASC")
+	}
+	return " ORDER BY " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,143 @@
+package db
+
+import (
+	"FilmCollection/structs"
+	"time"
+)
+
+// StatsFilter limits catalogue statistics to films released in a date range.
+// Actors are then only counted when they appear in one of those films.
+type StatsFilter struct {
+	ReleasedFrom *time.Time
+	ReleasedTo   *time.Time
+}
+
+// statsSQL selects the films (f) and actors (a) matching the filter
+// ($1, $2) for the statistics queries. Films stored with the 0001-01-01
+// release date have an unknown one: it is NULL in f, so they are counted as
+// such, left out of the years and decades and never in a date range.
+const statsSQL = `WITH f AS (
+		SELECT id, rating, release_date FROM (
+			SELECT id, rating, NULLIF(release_date, '0001-01-01') AS release_date FROM films
+		) films
+		WHERE ($1::date IS NULL OR release_date >= $1) AND ($2::date IS NULL OR release_date <= $2)
+	), a AS (
+		SELECT id, name, gender FROM actors
+		WHERE ($1::date IS NULL AND $2::date IS NULL) OR id IN (SELECT actorid FROM moviecast WHERE filmid IN (SELECT id FROM f))
+	) `
+
+// GetCatalogueStats computes the catalogue statistics in the database.
+// TopActors holds at most topActors actors with the most films.
+func GetCatalogueStats(filter StatsFilter, topActors int) (structs.CatalogueStats, error) {
+	var stats structs.CatalogueStats
+	args := []interface{}{filter.ReleasedFrom, filter.ReleasedTo}
+	err := Conn.QueryRow(statsSQL+`SELECT (SELECT count(*) FROM f), (SELECT count(*) FROM a),
+			(SELECT COALESCE(avg(rating), 0)::float8 FROM f),
+			(SELECT count(*) FROM f WHERE release_date IS NULL)`, args...).
+		Scan(&stats.Films, &stats.Actors, &stats.AverageRating, &stats.UnknownReleaseDate)
+	if err != nil {
+		return stats, err
+	}
+
+	ratings := make(map[int]int)
+	rows, err := Conn.Query(statsSQL+"SELECT rating, count(*) FROM f WHERE rating IS NOT NULL GROUP BY rating", args...)
+	if err != nil {
+		return stats, err
+	}
+	err = scanCounts(rows, ratings)
+	if err != nil {
+		return stats, err
+	}
+	for rating := 0; rating <= 10; rating++ {
+		stats.RatingHistogram = append(stats.RatingHistogram, structs.RatingCount{Rating: rating, Count: ratings[rating]})
+	}
+
+	stats.FilmsPerYear, err = yearCounts(statsSQL+`SELECT extract(year FROM release_date)::int AS year, count(*)
+		FROM f WHERE release_date IS NOT NULL GROUP BY year ORDER BY year`, args)
+	if err != nil {
+		return stats, err
+	}
+	stats.FilmsPerDecade, err = yearCounts(statsSQL+`SELECT extract(year FROM release_date)::int / 10 * 10 AS decade, count(*)
+		FROM f WHERE release_date IS NOT NULL GROUP BY decade ORDER BY decade`, args)
+	if err != nil {
+		return stats, err
+	}
+
+	rows, err = Conn.Query(statsSQL+`SELECT cast_size, count(*) FROM (
+			SELECT f.id, count(DISTINCT mc.actorid)::int AS cast_size FROM f LEFT JOIN moviecast mc ON mc.filmid = f.id GROUP BY f.id
+		) s GROUP BY cast_size ORDER BY cast_size`, args...)
+	if err != nil {
+		return stats, err
+	}
+	stats.CastSizes = []structs.CastSizeCount{}
+	for rows.Next() {
+		var size structs.CastSizeCount
+		err = rows.Scan(&size.CastSize, &size.Films)
+		if err != nil {
+			rows.Close()
+			return stats, err
+		}
+		stats.CastSizes = append(stats.CastSizes, size)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return stats, err
+	}
+
+	rows, err = Conn.Query(statsSQL+`SELECT COALESCE(NULLIF(gender, ''), 'unknown') AS g, count(*)
+		FROM a GROUP BY g ORDER BY count(*) DESC, g`, args...)
+	if err != nil {
+		return stats, err
+	}
+	stats.Genders = []structs.GenderCount{}
+	for rows.Next() {
+		var gender structs.GenderCount
+		err = rows.Scan(&gender.Gender, &gender.Count)
+		if err != nil {
+			rows.Close()
+			return stats, err
+		}
+		stats.Genders = append(stats.Genders, gender)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return stats, err
+	}
+
+	rows, err = Conn.Query(statsSQL+`SELECT a.id, a.name, count(DISTINCT mc.filmid) FROM a
+		JOIN moviecast mc ON mc.actorid = a.id JOIN f ON f.id = mc.filmid
+		GROUP BY a.id, a.name ORDER BY count(DISTINCT mc.filmid) DESC, a.name, a.id LIMIT $3`, append(args, topActors)...)
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
+	stats.TopActors = []structs.ActorCount{}
+	for rows.Next() {
+		var actor structs.ActorCount
+		err = rows.Scan(&actor.ActorID, &actor.Name, &actor.Count)
+		if err != nil {
+			return stats, err
+		}
+		stats.TopActors = append(stats.TopActors, actor)
+	}
+	return stats, rows.Err()
+}
+
+// yearCounts runs a query selecting (year, count) rows in order.
+func yearCounts(sql string, args []interface{}) ([]structs.YearCount, error) {
+	rows, err := Conn.Query(sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := []structs.YearCount{}
+	for rows.Next() {
+		var count structs.YearCount
+		err = rows.Scan(&count.Year, &count.Count)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
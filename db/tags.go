@@ -0,0 +1,150 @@
+package db
+
+import (
+	"FilmCollection/structs"
+	"errors"
+	"strings"
+)
+
+// ErrTagExists is returned by RenameTag when another tag already has the new
+// name; such tags should be merged instead.
+var ErrTagExists = errors.New("tag with this name already exists")
+
+// NormalizeTag lowercases a tag name and collapses its whitespace, so that
+// "Heist", " heist " and "HEIST" end up as the same tag.
+func NormalizeTag(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// normalizeTags normalizes names and drops duplicates and empty names.
+func normalizeTags(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	var tags []string
+	for _, name := range names {
+		name = NormalizeTag(name)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			tags = append(tags, name)
+		}
+	}
+	return tags
+}
+
+// TagsByFilm returns the tag names of every film in filmIDs with a single query.
+func TagsByFilm(filmIDs []int) (map[int][]string, error) {
+	result := make(map[int][]string, len(filmIDs))
+	if len(filmIDs) == 0 {
+		return result, nil
+	}
+	rows, err := Conn.Query(`SELECT ft.filmid, t.name FROM filmtags ft JOIN tags t ON t.id = ft.tagid
+		WHERE ft.filmid = ANY($1) ORDER BY ft.filmid, t.name`, filmIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var filmID int
+		var name string
+		err = rows.Scan(&filmID, &name)
+		if err != nil {
+			return nil, err
+		}
+		result[filmID] = append(result[filmID], name)
+	}
+	return result, rows.Err()
+}
+
+// fillTags sets Tags of every film in place with a single query.
+func fillTags(films []structs.Film) error {
+	ids := make([]int, len(films))
+	for i, film := range films {
+		ids[i] = film.Id
+	}
+	tags, err := TagsByFilm(ids)
+	if err != nil {
+		return err
+	}
+	for i := range films {
+		films[i].Tags = tags[films[i].Id]
+	}
+	return nil
+}
+
+// SetFilmTags replaces the tags of a film, creating tags that don't exist yet.
+func SetFilmTags(filmID int, names []string) error {
+	_, err := Conn.Exec("DELETE FROM filmtags WHERE filmid = $1", filmID)
+	if err != nil {
+		return err
+	}
+	for _, name := range normalizeTags(names) {
+		_, err = Conn.Exec(`WITH tag AS (
+				INSERT INTO tags (name) VALUES ($2) ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id
+			)
+			INSERT INTO filmtags (filmid, tagid) SELECT $1, id FROM tag ON CONFLICT DO NOTHING`, filmID, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchTags returns tags starting with prefix together with their film
+// counts, most used first. It backs tag autocomplete.
+func SearchTags(prefix string, limit int) ([]structs.Tag, error) {
+	var q query
+	q.and("t.name LIKE ?", escapeLike(NormalizeTag(prefix))+"%")
+	rows, err := Conn.Query(`SELECT t.id, t.name, count(ft.filmid) FROM tags t LEFT JOIN filmtags ft ON ft.tagid = t.id`+
+		q.whereSQL()+` GROUP BY t.id, t.name ORDER BY count(ft.filmid) DESC, t.name LIMIT `+q.arg(limit), q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tags := []structs.Tag{}
+	for rows.Next() {
+		var tag structs.Tag
+		err = rows.Scan(&tag.Id, &tag.Name, &tag.Films)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// RenameTag changes the name of a tag.
+func RenameTag(id int, name string) error {
+	var taken bool
+	err := Conn.QueryRow("SELECT EXISTS (SELECT 1 FROM tags WHERE name = $1 AND id <> $2)", name, id).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrTagExists
+	}
+	_, err = Conn.Exec("UPDATE tags SET name = $1 WHERE id = $2", name, id)
+	return err
+}
+
+// MergeTags moves every film tagged with one of from to into and deletes the
+// merged tags. It runs in a transaction.
+func MergeTags(from []int, into int) error {
+	tx, err := Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`INSERT INTO filmtags (filmid, tagid)
+		SELECT filmid, $2 FROM filmtags WHERE tagid = ANY($1) ON CONFLICT DO NOTHING`, from, into)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM tags WHERE id = ANY($1) AND id <> $2", from, into)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -4,12 +4,9 @@ import (
 	"FilmCollection/db"
 	"FilmCollection/structs"
 	"encoding/json"
-	"fmt"
-	"github.com/jackc/pgx"
 	"log/slog"
 	"net/http"
 	"strconv"
-	"strings"
 )
 
 // @Summary AddActor
@@ -58,9 +55,13 @@ func AddActor(w http.ResponseWriter, r *http.Request) {
 // @Description Get actor by id
 // @ID get-actor
 // @Param id query int true "Actor id"
+// @Param expand query string false "Relations to embed as objects instead of ids (films)"
+// @Param fields query string false "Comma separated list of fields to return"
 // @Param Authorization header string true "Basic auth for user"
 // @Success 200 {object} structs.Actor
 // @Failure 400 "invalid id format"
+// @Failure 400 "invalid expand format: <reason>"
+// @Failure 400 "invalid fields format: <reason>"
 // @Failure 500 "error reading actor"
 // @Router /get_actor [get]
 func GetActor(w http.ResponseWriter, r *http.Request) {
@@ -71,14 +72,26 @@ func GetActor(w http.ResponseWriter, r *http.Request) {
 		slog.Error("ID format error: ", "error", err, "status", http.StatusBadRequest)
 		return
 	}
+	opts, err := responseOptionsFromQuery(r.URL.Query(), actorExpansions, structs.Actor{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("Invalid response options: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
 	actor, err := db.GetActorByID(id)
 	if err != nil {
 		http.Error(w, "error reading actor", http.StatusInternalServerError)
 		slog.Error("Error reading actor: ", "error", err, "status", http.StatusInternalServerError)
 		return
 	}
+	response, err := actorResponses([]structs.Actor{actor}, opts)
+	if err != nil {
+		http.Error(w, "error reading actor", http.StatusInternalServerError)
+		slog.Error("Error reading actor: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(actor)
+	err = json.NewEncoder(w).Encode(response[0])
 	if err != nil {
 		http.Error(w, "error writing response", http.StatusInternalServerError)
 		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
@@ -94,17 +107,27 @@ func GetActor(w http.ResponseWriter, r *http.Request) {
 // @Param limit query int false "Limit of actors to return" default(10)
 // @Param reverse query bool false "Reverse order" default(true)
 // @Param sort_parameter query string false "Parameter to sort by" default("name")
+// @Param sort query string false "Comma separated sort keys (id, name, gender, birth_date, film_count), prefix a key with - for descending order. Overrides sort_parameter and reverse"
+// @Param gender query string false "Gender"
+// @Param born_from query int false "Earliest birth year"
+// @Param born_to query int false "Latest birth year"
+// @Param film query int false "Id of a film the actor appeared in"
+// @Param expand query string false "Relations to embed as objects instead of ids (films)"
+// @Param fields query string false "Comma separated list of fields to return"
 // @Param Authorization header string true "Basic auth for user"
 // @Success 200 {array} structs.Actor
 // @Failure 400 "invalid limit format"
 // @Failure 400 "invalid reverse format"
 // @Failure 400 "invalid sort_parameter format"
+// @Failure 400 "invalid sort format: <reason>"
+// @Failure 400 "invalid <filter> format"
+// @Failure 400 "invalid expand format: <reason>"
+// @Failure 400 "invalid fields format: <reason>"
 // @Failure 500 "error reading actors"
 // @Router /get_actors [get]
 
 func GetActors(w http.ResponseWriter, r *http.Request) {
 	limitString := r.URL.Query().Get("limit")
-	keyword := r.URL.Query().Get("keyword")
 	limit := 10
 	var err error
 	if limitString != "" {
@@ -115,58 +138,38 @@ func GetActors(w http.ResponseWriter, r *http.Request) {
 		slog.Error("Invalid limit format: ", "error", err, "status", http.StatusBadRequest)
 		return
 	}
-	sortString := strings.ToLower(r.URL.Query().Get("reverse"))
-	if sortString == "" {
-		sortString = "true"
-	}
-	if sortString != "true" && sortString != "false" {
-		http.Error(w, "invalid reverse format", http.StatusBadRequest)
-		slog.Error("Invalid reverse format: ", "error", err, "status", http.StatusBadRequest)
+	sort, err := sortFromQuery(r.URL.Query(), db.ActorSortColumns, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("Invalid sort: ", "error", err, "status", http.StatusBadRequest)
 		return
 	}
-	sortParameter := r.URL.Query().Get("sort_parameter")
-	if sortParameter == "" {
-		sortParameter = "id"
-	}
-	if sortParameter != "id" && sortParameter != "name" && sortParameter != "gender" && sortParameter != "birth_date" {
-		http.Error(w, "invalid sort_parameter format", http.StatusBadRequest)
-		slog.Error("Invalid sort_parameter format: ", "error", err, "status", http.StatusBadRequest)
+	filter, err := actorFilterFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("Invalid filter: ", "error", err, "status", http.StatusBadRequest)
 		return
 	}
-	var rows *pgx.Rows
-	order := "ASC"
-	if sortString == "true" {
-		order = "DESC"
+	opts, err := responseOptionsFromQuery(r.URL.Query(), actorExpansions, structs.Actor{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("Invalid response options: ", "error", err, "status", http.StatusBadRequest)
+		return
 	}
-	sqlQuery := fmt.Sprintf(`SELECT id FROM actors WHERE name ILIKE $1 ORDER BY %s %s LIMIT %d`, sortParameter, order, limit)
-	rows, err = db.Conn.Query(sqlQuery, "%"+keyword+"%")
+	actors, err := db.SearchActors(filter, sort, limit)
 	if err != nil {
 		http.Error(w, "error reading actors", http.StatusInternalServerError)
 		slog.Error("Error reading actors: ", "error", err, "status", http.StatusInternalServerError)
 		return
 	}
-	var actors []structs.Actor
-	defer rows.Close()
-	for rows.Next() {
-		var actor structs.Actor
-		err = rows.Scan(&actor.Id)
-		if err != nil {
-			http.Error(w, "error reading actor", http.StatusInternalServerError)
-			slog.Error("GetActors", "error reading actor", err)
-			return
-		}
-		actors = append(actors, actor)
-	}
-	for i := range actors {
-		actors[i], err = db.GetActorByID(actors[i].Id)
-		if err != nil {
-			http.Error(w, "error reading actor", http.StatusInternalServerError)
-			slog.Error("GetActors", "error reading actor", err)
-			return
-		}
+	response, err := actorResponses(actors, opts)
+	if err != nil {
+		http.Error(w, "error reading actors", http.StatusInternalServerError)
+		slog.Error("Error reading actors: ", "error", err, "status", http.StatusInternalServerError)
+		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(actors)
+	err = json.NewEncoder(w).Encode(response)
 	if err != nil {
 		http.Error(w, "error writing response", http.StatusInternalServerError)
 		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
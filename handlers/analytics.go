@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"FilmCollection/db"
+	"encoding/json"
+	"errors"
+	"github.com/jackc/pgx"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// @Summary GetActorAnalytics
+// @Description Get the career of an actor: career span, films per year, average rating of their films, their age at each release and their most frequent co-stars
+// @ID get-actor-analytics
+// @Param id path int true "Actor id"
+// @Param top_costars query int false "Number of most frequent co-stars to return" default(10)
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {object} structs.ActorAnalytics
+// @Failure 400 "invalid id format"
+// @Failure 400 "invalid top_costars format"
+// @Failure 404 "actor not found"
+// @Failure 500 "error reading actor analytics"
+// @Router /actors/{id}/analytics [get]
+func GetActorAnalytics(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id format", http.StatusBadRequest)
+		slog.Error("ID format error: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	topCostars := 10
+	if s := r.URL.Query().Get("top_costars"); s != "" {
+		topCostars, err = strconv.Atoi(s)
+	}
+	if err != nil || topCostars < 0 {
+		http.Error(w, "invalid top_costars format", http.StatusBadRequest)
+		slog.Error("Invalid top_costars format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	analytics, err := db.GetActorAnalytics(id, topCostars)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "actor not found", http.StatusNotFound)
+		slog.Error("GetActorAnalytics", "status", http.StatusNotFound, "error", "actor not found")
+		return
+	}
+	if err != nil {
+		http.Error(w, "error reading actor analytics", http.StatusInternalServerError)
+		slog.Error("Error reading actor analytics: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(analytics)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("GetActorAnalytics Actor analytics retrieved", "status", http.StatusOK)
+}
+
+// @Summary GetActorLeaderboard
+// @Description Rank actors by the average rating of their films. Only actors with at least min_films rated films are ranked
+// @ID get-actor-leaderboard
+// @Param min_films query int false "Minimum number of rated films" default(3)
+// @Param limit query int false "Limit of actors to return" default(50)
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {array} structs.ActorRating
+// @Failure 400 "invalid min_films format"
+// @Failure 400 "invalid limit format"
+// @Failure 500 "error reading leaderboard"
+// @Router /actors/leaderboard [get]
+func GetActorLeaderboard(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	minFilms := 3
+	var err error
+	if s := values.Get("min_films"); s != "" {
+		minFilms, err = strconv.Atoi(s)
+	}
+	if err != nil || minFilms < 1 {
+		http.Error(w, "invalid min_films format", http.StatusBadRequest)
+		slog.Error("Invalid min_films format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	limit := 50
+	if s := values.Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+	}
+	if err != nil || limit < 0 {
+		http.Error(w, "invalid limit format", http.StatusBadRequest)
+		slog.Error("Invalid limit format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	leaderboard, err := db.GetActorLeaderboard(minFilms, limit)
+	if err != nil {
+		http.Error(w, "error reading leaderboard", http.StatusInternalServerError)
+		slog.Error("Error reading leaderboard: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(leaderboard)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("GetActorLeaderboard Leaderboard retrieved", "status", http.StatusOK)
+}
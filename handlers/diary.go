@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"FilmCollection/db"
+	"FilmCollection/structs"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// readDiaryEntry decodes and validates a diary entry from the request body.
+// A missing watched_on defaults to today.
+func readDiaryEntry(r *http.Request) (structs.DiaryEntry, error) {
+	var entry structs.DiaryEntry
+	if r.Body == nil {
+		return entry, errors.New("no request body")
+	}
+	err := json.NewDecoder(r.Body).Decode(&entry)
+	if err != nil {
+		return entry, errors.New("error reading request body")
+	}
+	if entry.Rating < 0 || entry.Rating > 10 {
+		return entry, errors.New("rating must be between 1 and 10")
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if entry.WatchedOn.IsZero() {
+		entry.WatchedOn.Time = today
+	}
+	if entry.WatchedOn.After(today) {
+		return entry, errors.New("watched_on cannot be in the future")
+	}
+	entry.Notes = strings.TrimSpace(entry.Notes)
+	entry.UserID = userID(r)
+	return entry, nil
+}
+
+// @Summary LogWatch
+// @Description Log that the authenticated user watched a film. The rating is optional and does not change the film's rating
+// @ID log-watch
+// @Accept  json
+// @Param entry body structs.DiaryEntry true "film_id, watched_on (defaults to today), optional rating, notes and rewatch"
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {object} structs.DiaryEntry
+// @Failure 400 "film id not specified"
+// @Failure 400 "rating must be between 1 and 10"
+// @Failure 400 "watched_on cannot be in the future"
+// @Failure 404 "film not found"
+// @Failure 500 "error logging watch"
+// @Router /log_watch [post]
+func LogWatch(w http.ResponseWriter, r *http.Request) {
+	entry, err := readDiaryEntry(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("LogWatch", "status", http.StatusBadRequest, "error", err)
+		return
+	}
+	if entry.FilmID == 0 {
+		http.Error(w, "film id not specified", http.StatusBadRequest)
+		slog.Error("LogWatch", "status", http.StatusBadRequest, "error", "film id not specified")
+		return
+	}
+	entry.Id, err = db.AddDiaryEntry(entry)
+	if errors.Is(err, db.ErrFilmNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		slog.Error("LogWatch", "status", http.StatusNotFound, "error", err)
+		return
+	}
+	if err != nil {
+		http.Error(w, "error logging watch", http.StatusInternalServerError)
+		slog.Error("Error logging watch: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(entry)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("LogWatch Watch logged", "status", http.StatusOK)
+}
+
+// @Summary UpdateDiaryEntry
+// @Description Replace the date, rating, notes and rewatch flag of an own diary entry
+// @ID update-diary-entry
+// @Accept  json
+// @Param entry body structs.DiaryEntry true "id and the new values of the entry"
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 "diary entry updated"
+// @Failure 400 "diary entry id not specified"
+// @Failure 400 "rating must be between 1 and 10"
+// @Failure 400 "watched_on cannot be in the future"
+// @Failure 404 "diary entry not found"
+// @Failure 500 "error updating diary entry"
+// @Router /update_diary_entry [post]
+func UpdateDiaryEntry(w http.ResponseWriter, r *http.Request) {
+	entry, err := readDiaryEntry(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("UpdateDiaryEntry", "status", http.StatusBadRequest, "error", err)
+		return
+	}
+	if entry.Id == 0 {
+		http.Error(w, "diary entry id not specified", http.StatusBadRequest)
+		slog.Error("UpdateDiaryEntry", "status", http.StatusBadRequest, "error", "diary entry id not specified")
+		return
+	}
+	err = db.UpdateDiaryEntry(entry)
+	if errors.Is(err, db.ErrDiaryEntryNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		slog.Error("UpdateDiaryEntry", "status", http.StatusNotFound, "error", err)
+		return
+	}
+	if err != nil {
+		http.Error(w, "error updating diary entry", http.StatusInternalServerError)
+		slog.Error("Error updating diary entry: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	slog.Info("UpdateDiaryEntry Diary entry updated", "status", http.StatusOK)
+}
+
+// @Summary DeleteDiaryEntry
+// @Description Delete an own diary entry
+// @ID delete-diary-entry
+// @Param id query int true "Diary entry id"
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 "diary entry deleted"
+// @Failure 400 "invalid id format"
+// @Failure 404 "diary entry not found"
+// @Failure 500 "error deleting diary entry"
+// @Router /delete_diary_entry [post]
+func DeleteDiaryEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "invalid id format", http.StatusBadRequest)
+		slog.Error("ID format error: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	err = db.DeleteDiaryEntry(id, userID(r))
+	if errors.Is(err, db.ErrDiaryEntryNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		slog.Error("DeleteDiaryEntry", "status", http.StatusNotFound, "error", err)
+		return
+	}
+	if err != nil {
+		http.Error(w, "error deleting diary entry", http.StatusInternalServerError)
+		slog.Error("Error deleting diary entry: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	slog.Info("DeleteDiaryEntry Diary entry deleted", "status", http.StatusOK)
+}
+
+// @Summary GetDiary
+// @Description Get the authenticated user's diary, most recent watch first
+// @ID get-diary
+// @Param from query string false "Earliest watch date, e.g. 2024-01-01"
+// @Param to query string false "Latest watch date, e.g. 2024-12-31"
+// @Param film_id query int false "Only entries of this film"
+// @Param limit query int false "Limit of entries to return" default(100)
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {array} structs.DiaryEntry
+// @Failure 400 "invalid from format"
+// @Failure 400 "invalid to format"
+// @Failure 400 "invalid film_id format"
+// @Failure 400 "invalid limit format"
+// @Failure 500 "error reading diary"
+// @Router /get_diary [get]
+func GetDiary(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	filter := db.DiaryFilter{UserID: userID(r)}
+	var err error
+	if filter.From, err = optionalDate(values, "from"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("Invalid filter: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = optionalDate(values, "to"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("Invalid filter: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	if filter.FilmID, err = optionalInt(values, "film_id"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("Invalid filter: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	limit := 100
+	if s := values.Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+	}
+	if err != nil {
+		http.Error(w, "invalid limit format", http.StatusBadRequest)
+		slog.Error("Invalid limit format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	entries, err := db.GetDiary(filter, limit)
+	if err != nil {
+		http.Error(w, "error reading diary", http.StatusInternalServerError)
+		slog.Error("Error reading diary: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(entries)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("GetDiary Diary retrieved", "status", http.StatusOK)
+}
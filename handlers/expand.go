@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"FilmCollection/db"
+	"FilmCollection/structs"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// responseOptions holds the expand= and fields= parameters of the read
+// endpoints. Expanded relations replace id arrays with nested objects and
+// fields limits the top-level keys of every returned object.
+type responseOptions struct {
+	expand map[string]bool
+	fields map[string]bool
+}
+
+func (o responseOptions) plain() bool {
+	return len(o.expand) == 0 && len(o.fields) == 0
+}
+
+// responseOptionsFromQuery validates expand against expandable and fields
+// against the JSON keys of example.
+func responseOptionsFromQuery(values url.Values, expandable []string, example interface{}) (responseOptions, error) {
+	var opts responseOptions
+	if s := values.Get("expand"); s != "" {
+		opts.expand = make(map[string]bool)
+		for _, name := range strings.Split(s, ",") {
+			name = strings.TrimSpace(name)
+			if !contains(expandable, name) {
+				return opts, errors.New("invalid expand format: cannot expand " + `"` + name + `"`)
+			}
+			opts.expand[name] = true
+		}
+	}
+	if s := values.Get("fields"); s != "" {
+		known, err := toMap(example)
+		if err != nil {
+			return opts, err
+		}
+		opts.fields = make(map[string]bool)
+		for _, name := range strings.Split(s, ",") {
+			name = strings.TrimSpace(name)
+			if _, ok := known[name]; !ok {
+				return opts, errors.New("invalid fields format: unknown field " + `"` + name + `"`)
+			}
+			opts.fields[name] = true
+		}
+	}
+	return opts, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// toMap turns a struct into its JSON object representation.
+func toMap(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
+
+// shape applies expansions and the field selection to a single object.
+func (o responseOptions) shape(v interface{}, expanded map[string]interface{}) (map[string]interface{}, error) {
+	m, err := toMap(v)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range expanded {
+		m[key] = value
+	}
+	if len(o.fields) > 0 {
+		for key := range m {
+			if !o.fields[key] {
+				delete(m, key)
+			}
+		}
+	}
+	return m, nil
+}
+
+var filmExpansions = []string{"actors"}
+var actorExpansions = []string{"films"}
+
+// filmResponses prepares films for encoding according to opts. Nested
+// actors of all films are loaded with one batched call.
+func filmResponses(films []structs.Film, opts responseOptions) ([]interface{}, error) {
+	result := make([]interface{}, len(films))
+	if opts.plain() {
+		for i, film := range films {
+			result[i] = film
+		}
+		return result, nil
+	}
+	var actors map[int]structs.Actor
+	if opts.expand["actors"] {
+		var ids []int
+		for _, film := range films {
+			ids = append(ids, film.Actors...)
+		}
+		var err error
+		actors, err = db.GetActorsByIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, film := range films {
+		expanded := make(map[string]interface{})
+		if actors != nil {
+			cast := []structs.Actor{}
+			for _, id := range film.Actors {
+				if actor, ok := actors[id]; ok {
+					cast = append(cast, actor)
+				}
+			}
+			expanded["actors"] = cast
+		}
+		m, err := opts.shape(film, expanded)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = m
+	}
+	return result, nil
+}
+
+// actorResponses prepares actors for encoding according to opts. Nested
+// films of all actors are loaded with one batched call.
+func actorResponses(actors []structs.Actor, opts responseOptions) ([]interface{}, error) {
+	result := make([]interface{}, len(actors))
+	if opts.plain() {
+		for i, actor := range actors {
+			result[i] = actor
+		}
+		return result, nil
+	}
+	var films map[int]structs.Film
+	if opts.expand["films"] {
+		var ids []int
+		for _, actor := range actors {
+			ids = append(ids, actor.Films...)
+		}
+		var err error
+		films, err = db.GetFilmsByIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, actor := range actors {
+		expanded := make(map[string]interface{})
+		if films != nil {
+			filmography := []structs.Film{}
+			for _, id := range actor.Films {
+				if film, ok := films[id]; ok {
+					filmography = append(filmography, film)
+				}
+			}
+			expanded["films"] = filmography
+		}
+		m, err := opts.shape(actor, expanded)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = m
+	}
+	return result, nil
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"FilmCollection/db"
+	"FilmCollection/structs"
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// exportFormats maps the format parameter of Export to the content type and
+// file extension of the export.
+var exportFormats = map[string][2]string{
+	"ndjson": {"application/x-ndjson", "ndjson"},
+	"json":   {"application/json", "json"},
+	"zip":    {"application/zip", "zip"},
+}
+
+// exportSections names the parts of an export in the order db.Export
+// produces them: the keys of the JSON object and the CSV files of the zip.
+var exportSections = []string{"films", "actors", "cast"}
+
+// @Summary Export
+// @Description Stream every film, actor and cast link from one consistent snapshot. ndjson writes one {"type", "data"} object per line with type film, actor or cast; json writes one object with films, actors and cast arrays; zip holds films.csv, actors.csv and cast.csv. The export is written while it is read, so a failure midway ends the response early
+// @ID export
+// @Param format query string false "ndjson, json or zip" default(ndjson)
+// @Param Authorization header string true "Basic auth for admin"
+// @Success 200 {string} string "catalogue in the requested format"
+// @Failure 400 "invalid format format"
+// @Router /export [get]
+func Export(w http.ResponseWriter, r *http.Request) {
+	if r.Context().Value("admin") != true {
+		http.Error(w, "authorization error", http.StatusUnauthorized)
+		slog.Error("Authorization error: ", "error", "not admin", "status", http.StatusUnauthorized)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "ndjson"
+	}
+	formatInfo, ok := exportFormats[format]
+	if !ok {
+		http.Error(w, "invalid format format", http.StatusBadRequest)
+		slog.Error("Export", "status", http.StatusBadRequest, "error", "invalid format format")
+		return
+	}
+	var sink interface {
+		db.ExportSink
+		Close() error
+	}
+	switch format {
+	case "ndjson":
+		sink = &ndjsonExport{out: json.NewEncoder(w)}
+	case "json":
+		sink = &jsonExport{w: w, section: -1}
+	default:
+		sink = &zipExport{out: zip.NewWriter(w), section: -1}
+	}
+	w.Header().Set("Content-Type", formatInfo[0])
+	w.Header().Set("Content-Disposition", `attachment; filename="catalogue.`+formatInfo[1]+`"`)
+	err := db.Export(sink)
+	if err == nil {
+		err = sink.Close()
+	}
+	if err != nil {
+		// The status line is gone already; the client sees a cut off body.
+		slog.Error("Error exporting catalogue: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Export Catalogue exported", "status", http.StatusOK, "format", format)
+}
+
+// ndjsonExport writes every record as a line of its own.
+type ndjsonExport struct {
+	out *json.Encoder
+}
+
+type ndjsonLine struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+func (e *ndjsonExport) Film(film structs.FilmRecord) error {
+	return e.out.Encode(ndjsonLine{Type: "film", Data: film})
+}
+
+func (e *ndjsonExport) Actor(actor structs.Person) error {
+	return e.out.Encode(ndjsonLine{Type: "actor", Data: actor})
+}
+
+func (e *ndjsonExport) Credit(credit structs.Credit) error {
+	return e.out.Encode(ndjsonLine{Type: "cast", Data: credit})
+}
+
+func (e *ndjsonExport) Close() error {
+	return nil
+}
+
+// jsonExport writes one object with an array per section, element by
+// element.
+type jsonExport struct {
+	w io.Writer
+	// section is the index in exportSections of the open array, -1 before
+	// the first one.
+	section int
+	empty   bool
+}
+
+// next closes the open array and opens the arrays up to section.
+func (e *jsonExport) next(section int) error {
+	for e.section < section {
+		prefix := "],"
+		if e.section < 0 {
+			prefix = "{"
+		}
+		e.section++
+		e.empty = true
+		_, err := io.WriteString(e.w, prefix+`"`+exportSections[e.section]+`":[`)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *jsonExport) item(section int, v interface{}) error {
+	err := e.next(section)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if !e.empty {
+		b = append([]byte{','}, b...)
+	}
+	e.empty = false
+	_, err = e.w.Write(b)
+	return err
+}
+
+func (e *jsonExport) Film(film structs.FilmRecord) error {
+	return e.item(0, film)
+}
+
+func (e *jsonExport) Actor(actor structs.Person) error {
+	return e.item(1, actor)
+}
+
+func (e *jsonExport) Credit(credit structs.Credit) error {
+	return e.item(2, credit)
+}
+
+func (e *jsonExport) Close() error {
+	err := e.next(len(exportSections) - 1)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(e.w, "]}\n")
+	return err
+}
+
+// zipExport writes a CSV file per section into a zip archive.
+type zipExport struct {
+	out     *zip.Writer
+	csv     *csv.Writer
+	section int
+}
+
+// exportHeaders are the CSV columns of the sections, named like the fields
+// of the bulk import.
+var exportHeaders = [][]string{
+	{"id", "name", "description", "release_date", "rating", "runtime"},
+	{"id", "name", "gender", "birth_date"},
+	{"film_id", "actor_id", "character", "billing", "credit_type"},
+}
+
+// next finishes the open file and starts the files up to section.
+func (e *zipExport) next(section int) error {
+	for e.section < section {
+		if e.csv != nil {
+			e.csv.Flush()
+			if err := e.csv.Error(); err != nil {
+				return err
+			}
+		}
+		e.section++
+		file, err := e.out.Create(exportSections[e.section] + ".csv")
+		if err != nil {
+			return err
+		}
+		e.csv = csv.NewWriter(file)
+		err = e.csv.Write(exportHeaders[e.section])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *zipExport) record(section int, record ...string) error {
+	err := e.next(section)
+	if err != nil {
+		return err
+	}
+	return e.csv.Write(record)
+}
+
+func (e *zipExport) Film(film structs.FilmRecord) error {
+	return e.record(0, strconv.Itoa(film.Id), film.Name, film.Description, csvDate(film.ReleaseDate),
+		strconv.Itoa(film.Rating), csvInt(film.Runtime))
+}
+
+func (e *zipExport) Actor(actor structs.Person) error {
+	return e.record(1, strconv.Itoa(actor.Id), actor.Name, actor.Gender, csvDate(actor.BirthDate))
+}
+
+func (e *zipExport) Credit(credit structs.Credit) error {
+	return e.record(2, strconv.Itoa(credit.FilmID), strconv.Itoa(credit.ActorID), credit.Character,
+		csvInt(credit.Billing), credit.CreditType)
+}
+
+func (e *zipExport) Close() error {
+	err := e.next(len(exportSections) - 1)
+	if err != nil {
+		return err
+	}
+	e.csv.Flush()
+	if err = e.csv.Error(); err != nil {
+		return err
+	}
+	return e.out.Close()
+}
+
+// csvDate formats a date as 2006-01-02, or empty when it is unknown.
+func csvDate(d structs.Date) string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Format("2006-01-02")
+}
+
+// csvInt formats a number that is unknown when zero.
+func csvInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
@@ -4,13 +4,11 @@ import (
 	"FilmCollection/db"
 	"FilmCollection/structs"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"github.com/jackc/pgx"
 	"log/slog"
 	"net/http"
 	"strconv"
-	"strings"
-	"time"
 )
 
 // @Summary AddFilm
@@ -21,6 +19,9 @@ import (
 // @Param Authorization header string true "Basic auth for admin"
 // @Success 200 "film added"
 // @Failure 400 "no request body"
+// @Failure 400 "invalid credit_type format"
+// @Failure 400 "invalid department format"
+// @Failure 400 "invalid runtime format"
 // @Router /add_film [post]
 func AddFilm(w http.ResponseWriter, r *http.Request) {
 	if r.Context().Value("admin") != true {
@@ -40,22 +41,48 @@ func AddFilm(w http.ResponseWriter, r *http.Request) {
 		slog.Error("Error reading request body: ", "error", err, "status", http.StatusBadRequest)
 		return
 	}
+	err = validateFilmLinks(film)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("AddFilm", "status", http.StatusBadRequest, "error", err)
+		return
+	}
 
-	err = db.Conn.QueryRow("INSERT INTO films (name, description, release_date, rating) VALUES ($1, $2, $3, $4) RETURNING id", film.Name, film.Description, film.ReleaseDate.Format("2006-01-02"), film.Rating).Scan(&film.Id)
+	err = db.Conn.QueryRow("INSERT INTO films (name, description, release_date, rating, runtime) VALUES ($1, $2, $3, $4, NULLIF($5, 0)) RETURNING id", film.Name, film.Description, film.ReleaseDate.Format("2006-01-02"), film.Rating, film.Runtime).Scan(&film.Id)
 	if err != nil {
 		http.Error(w, "error adding film", http.StatusInternalServerError)
 		slog.Error("Error adding film: ", "error", err, "status", http.StatusInternalServerError)
 		return
 	}
 
-	for _, actor := range film.Actors {
-		_, err = db.Conn.Exec("INSERT INTO moviecast (filmid, actorid) VALUES ($1, $2)", film.Id, actor)
+	for _, credit := range filmCredits(film) {
+		err = db.AddCredit(film.Id, credit)
 		if err != nil {
 			http.Error(w, "error adding actor to movie_cast", http.StatusInternalServerError)
 			slog.Error("Error adding actor to movie_cast: ", "error", err, "status", http.StatusInternalServerError)
 			return
 		}
 	}
+	for _, credit := range film.Crew {
+		err = db.AddCrewCredit(film.Id, credit)
+		if err != nil {
+			http.Error(w, "error adding person to crew", http.StatusInternalServerError)
+			slog.Error("Error adding person to crew: ", "error", err, "status", http.StatusInternalServerError)
+			return
+		}
+	}
+	err = db.SetFilmGenres(film.Id, film.Genres)
+	if err != nil {
+		http.Error(w, "error adding film genres", http.StatusInternalServerError)
+		slog.Error("Error adding film genres: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	err = db.SetFilmTags(film.Id, film.Tags)
+	if err != nil {
+		http.Error(w, "error adding film tags", http.StatusInternalServerError)
+		slog.Error("Error adding film tags: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 	slog.Info("AddFilm Film added", "status", http.StatusOK)
 }
@@ -64,9 +91,13 @@ func AddFilm(w http.ResponseWriter, r *http.Request) {
 // @Description Get film by id
 // @ID get-film
 // @Param id query int true "Film id"
+// @Param expand query string false "Relations to embed as objects instead of ids (actors)"
+// @Param fields query string false "Comma separated list of fields to return"
 // @Param Authorization header string true "Basic auth for user"
 // @Success 200 {object} structs.Film
 // @Failure 400 "invalid id format"
+// @Failure 400 "invalid expand format: <reason>"
+// @Failure 400 "invalid fields format: <reason>"
 // @Failure 500 "error reading film"
 // @Router /get_film [get]
 func GetFilm(w http.ResponseWriter, r *http.Request) {
@@ -77,14 +108,33 @@ func GetFilm(w http.ResponseWriter, r *http.Request) {
 		slog.Error("ID format error: ", "error", err, "status", http.StatusBadRequest)
 		return
 	}
+	opts, err := responseOptionsFromQuery(r.URL.Query(), filmExpansions, structs.Film{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("Invalid response options: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
 	film, err := db.GetFilmByID(id)
 	if err != nil {
 		http.Error(w, "error reading film", http.StatusInternalServerError)
 		slog.Error("Error reading film: ", "error", err, "status", http.StatusInternalServerError)
 		return
 	}
+	films := []structs.Film{film}
+	err = db.MarkWatchlisted(films, userID(r))
+	if err != nil {
+		http.Error(w, "error reading film", http.StatusInternalServerError)
+		slog.Error("Error reading film: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	response, err := filmResponses(films, opts)
+	if err != nil {
+		http.Error(w, "error reading film", http.StatusInternalServerError)
+		slog.Error("Error reading film: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(film)
+	err = json.NewEncoder(w).Encode(response[0])
 	if err != nil {
 		http.Error(w, "error writing response", http.StatusInternalServerError)
 		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
@@ -96,20 +146,38 @@ func GetFilm(w http.ResponseWriter, r *http.Request) {
 // @Summary GetFilms
 // @Description Get films by keyword
 // @ID get-films
-// @Param keyword query string false "Keyword to search for"
+// @Param keyword query string false "Keyword or search query, e.g. rating>=8 year:1990..1999 actor:\"Sergei Bodrov\" -horror"
 // @Param limit query int false "Limit of films to return" default(10)
 // @Param reverse query bool false "Reverse order" default(true)
 // @Param sort_parameter query string false "Parameter to sort by" default("rating")
+// @Param sort query string false "Comma separated sort keys (id, name, description, rating, release_date, runtime, cast_size, community_rating), prefix a key with - for descending order. Overrides sort_parameter and reverse"
+// @Param min_rating query int false "Minimum rating"
+// @Param max_rating query int false "Maximum rating"
+// @Param released_from query string false "Earliest release date"
+// @Param released_to query string false "Latest release date"
+// @Param actors query string false "Comma separated actor ids the film must have"
+// @Param actors_match query string false "Whether any or all of the actors must be in the cast" default("any")
+// @Param genres query string false "Comma separated genre ids, subgenres included"
+// @Param tags query string false "Comma separated tags the film must all have"
+// @Param exclude_tags query string false "Comma separated tags the film must not have"
+// @Param min_cast query int false "Minimum cast size"
+// @Param max_cast query int false "Maximum cast size"
+// @Param expand query string false "Relations to embed as objects instead of ids (actors)"
+// @Param fields query string false "Comma separated list of fields to return"
 // @Param Authorization header string true "Basic auth for user"
 // @Success 200 {array} structs.Film
 // @Failure 400 "invalid limit format"
 // @Failure 400 "invalid reverse format"
 // @Failure 400 "invalid sort_parameter format"
+// @Failure 400 "invalid sort format: <reason>"
+// @Failure 400 "invalid <filter> format"
+// @Failure 400 "invalid keyword query at position <n>: <reason>"
+// @Failure 400 "invalid expand format: <reason>"
+// @Failure 400 "invalid fields format: <reason>"
 // @Failure 500 "error reading films"
 // @Router /get_films [get]
 func GetFilms(w http.ResponseWriter, r *http.Request) {
 	limitString := r.URL.Query().Get("limit")
-	keyword := r.URL.Query().Get("keyword")
 	limit := 10
 	var err error
 	if limitString != "" {
@@ -120,53 +188,45 @@ func GetFilms(w http.ResponseWriter, r *http.Request) {
 		slog.Error("Invalid limit format: ", "error", err, "status", http.StatusBadRequest)
 		return
 	}
-	sortString := strings.ToLower(r.URL.Query().Get("reverse"))
-	if sortString == "" {
-		sortString = "true"
-	}
-	if sortString != "true" && sortString != "false" {
-		http.Error(w, "invalid reverse format", http.StatusBadRequest)
-		slog.Error("Invalid reverse format: ", "error", err, "status", http.StatusBadRequest)
+	sort, err := sortFromQuery(r.URL.Query(), db.FilmSortColumns, "rating")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("Invalid sort: ", "error", err, "status", http.StatusBadRequest)
 		return
 	}
-	sortParameter := r.URL.Query().Get("sort_parameter")
-	if sortParameter == "" {
-		sortParameter = "rating"
+	filter, err := filmFilterFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("Invalid filter: ", "error", err, "status", http.StatusBadRequest)
+		return
 	}
-	if sortParameter != "id" && sortParameter != "name" && sortParameter != "description" && sortParameter != "rating" && sortParameter != "release_date" {
-		http.Error(w, "invalid sort_parameter format", http.StatusBadRequest)
-		slog.Error("Invalid sort_parameter format: ", "error", err, "status", http.StatusBadRequest)
+	opts, err := responseOptionsFromQuery(r.URL.Query(), filmExpansions, structs.Film{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("Invalid response options: ", "error", err, "status", http.StatusBadRequest)
 		return
 	}
-	var rows *pgx.Rows
-	order := "ASC"
-	if sortString == "true" {
-		order = "DESC"
+	films, err := db.SearchFilms(filter, sort, limit)
+	if err != nil {
+		http.Error(w, "error reading films", http.StatusInternalServerError)
+		slog.Error("Error reading films: ", "error", err, "status", http.StatusInternalServerError)
+		return
 	}
-	sqlQuery := fmt.Sprintf(`SELECT * FROM films WHERE name ILIKE $1 ORDER BY %s %s LIMIT %d`, sortParameter, order, limit)
-	rows, err = db.Conn.Query(sqlQuery, "%"+keyword+"%")
+	err = db.MarkWatchlisted(films, userID(r))
 	if err != nil {
 		http.Error(w, "error reading films", http.StatusInternalServerError)
 		slog.Error("Error reading films: ", "error", err, "status", http.StatusInternalServerError)
 		return
 	}
-	var films []structs.Film
-	defer rows.Close()
-	for rows.Next() {
-		var film structs.Film
-		var releaseDate time.Time
-		err = rows.Scan(&film.Id, &film.Name, &film.Description, &film.Rating, &releaseDate)
-		if err != nil {
-			http.Error(w, "error reading film", http.StatusInternalServerError)
-			slog.Error("Error reading film: ", "error", err, "status", http.StatusInternalServerError)
-			return
-		}
-		film.ReleaseDate = structs.Date{releaseDate}
-		films = append(films, film)
+	response, err := filmResponses(films, opts)
+	if err != nil {
+		http.Error(w, "error reading films", http.StatusInternalServerError)
+		slog.Error("Error reading films: ", "error", err, "status", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(films)
+	err = json.NewEncoder(w).Encode(response)
 	if err != nil {
 		http.Error(w, "error writing response", http.StatusInternalServerError)
 		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
@@ -177,7 +237,7 @@ func GetFilms(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary UpdateFilm
-// @Description Update film by id
+// @Description Update film by id. cast replaces the whole cast; a plain actors list replaces the cast too but keeps the roles of actors who stay, and a single actor id is added to it
 // @ID update-film
 // @Accept  json
 // @Param film body structs.Film true "Film object that needs to be updated"
@@ -185,6 +245,9 @@ func GetFilms(w http.ResponseWriter, r *http.Request) {
 // @Success 200 "film updated"
 // @Failure 400 "error reading request body"
 // @Failure 400 "film id not specified"
+// @Failure 400 "invalid credit_type format"
+// @Failure 400 "invalid department format"
+// @Failure 400 "invalid runtime format"
 // @Failure 500 "error adding film"
 // @Router /update_film [post]
 func UpdateFilm(w http.ResponseWriter, r *http.Request) {
@@ -206,6 +269,12 @@ func UpdateFilm(w http.ResponseWriter, r *http.Request) {
 		slog.Error("UpdateFilm", "status", http.StatusBadRequest, "error", "film id not specified")
 		return
 	}
+	err = validateFilmLinks(film)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("UpdateFilm", "status", http.StatusBadRequest, "error", err)
+		return
+	}
 	oldFilm, err := db.GetFilmByID(film.Id)
 	if err != nil {
 		http.Error(w, "error reading film", http.StatusInternalServerError)
@@ -224,23 +293,65 @@ func UpdateFilm(w http.ResponseWriter, r *http.Request) {
 	if film.ReleaseDate.IsZero() {
 		film.ReleaseDate = oldFilm.ReleaseDate
 	}
-	err = db.Conn.QueryRow("UPDATE films SET name = ($1), description = ($2), release_date = ($3), rating = ($4) WHERE id = ($5) RETURNING id", film.Name, film.Description, film.ReleaseDate.Format("2006-01-02"), film.Rating, film.Id).Scan(&film.Id)
+	if film.Runtime == 0 {
+		film.Runtime = oldFilm.Runtime
+	}
+	err = db.Conn.QueryRow("UPDATE films SET name = ($1), description = ($2), release_date = ($3), rating = ($4), runtime = NULLIF($6, 0) WHERE id = ($5) RETURNING id", film.Name, film.Description, film.ReleaseDate.Format("2006-01-02"), film.Rating, film.Id, film.Runtime).Scan(&film.Id)
 	if err != nil {
 		http.Error(w, "error adding film", http.StatusInternalServerError)
 		slog.Error("Error adding film: ", "error", err, "status", http.StatusInternalServerError)
 		return
 	}
-	if len(film.Actors) != 1 || film.Actors[0] == 0 {
+	if film.Cast != nil {
 		_, err = db.Conn.Exec("DELETE FROM moviecast WHERE filmid = $1", film.Id)
+		for _, credit := range film.Cast {
+			if err != nil {
+				break
+			}
+			if credit.ActorID != 0 {
+				err = db.AddCredit(film.Id, credit)
+			}
+		}
+	} else if len(film.Actors) == 1 && film.Actors[0] != 0 {
+		err = db.AddCredit(film.Id, structs.Credit{ActorID: film.Actors[0]})
+	} else {
+		// A plain actors list keeps the roles of the actors that stay.
+		err = db.SetFilmActors(film.Id, film.Actors)
+	}
+	if err != nil {
+		http.Error(w, "error adding actor to movie_cast", http.StatusInternalServerError)
+		slog.Error("Error adding actor to movie_cast: ", "error", err, "status", http.StatusInternalServerError)
+		return
 	}
-	for _, actor := range film.Actors {
-		if actor == 0 {
-			continue
+	if film.Crew != nil {
+		_, err = db.Conn.Exec("DELETE FROM crew WHERE filmid = $1", film.Id)
+		if err != nil {
+			http.Error(w, "error updating crew", http.StatusInternalServerError)
+			slog.Error("Error updating crew: ", "error", err, "status", http.StatusInternalServerError)
+			return
 		}
-		_, err = db.Conn.Exec("INSERT INTO moviecast (filmid, actorid) VALUES ($1, $2)", film.Id, actor)
+	}
+	for _, credit := range film.Crew {
+		err = db.AddCrewCredit(film.Id, credit)
 		if err != nil {
-			http.Error(w, "error adding actor to movie_cast", http.StatusInternalServerError)
-			slog.Error("Error adding actor to movie_cast: ", "error", err, "status", http.StatusInternalServerError)
+			http.Error(w, "error adding person to crew", http.StatusInternalServerError)
+			slog.Error("Error adding person to crew: ", "error", err, "status", http.StatusInternalServerError)
+			return
+		}
+	}
+	if film.Genres != nil {
+		err = db.SetFilmGenres(film.Id, film.Genres)
+		if err != nil {
+			http.Error(w, "error updating film genres", http.StatusInternalServerError)
+			slog.Error("Error updating film genres: ", "error", err, "status", http.StatusInternalServerError)
+			return
+		}
+	}
+	if film.Tags != nil {
+		err = db.SetFilmTags(film.Id, film.Tags)
+		if err != nil {
+			http.Error(w, "error updating film tags", http.StatusInternalServerError)
+			slog.Error("Error updating film tags: ", "error", err, "status", http.StatusInternalServerError)
 			return
 		}
 	}
@@ -280,3 +391,59 @@ func DeleteFilm(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	slog.Info("DeleteFilm Film deleted", "status", http.StatusOK)
 }
+
+// filmExists tells whether there is a film with the id, for requests that
+// link to one and would otherwise fail on its foreign key.
+func filmExists(id int) (bool, error) {
+	_, err := db.GetFilmByID(id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// validateFilmLinks checks the cast, crew and genres sent with a film, and
+// its runtime.
+func validateFilmLinks(film structs.Film) error {
+	if film.Runtime < 0 {
+		return errors.New("invalid runtime format")
+	}
+	for _, credit := range film.Cast {
+		if credit.ActorID == 0 {
+			return errors.New("cast actor_id not specified")
+		}
+		if credit.CreditType != "" && !contains(structs.CreditTypes, credit.CreditType) {
+			return errors.New("invalid credit_type format")
+		}
+		if credit.Billing < 0 {
+			return errors.New("invalid billing format")
+		}
+	}
+	for _, credit := range film.Crew {
+		if credit.PersonID == 0 {
+			return errors.New("crew person_id not specified")
+		}
+		if !contains(structs.Departments, credit.Department) {
+			return errors.New("invalid department format")
+		}
+	}
+	for _, genre := range film.Genres {
+		if genre.Id == 0 {
+			return errors.New("genre id not specified")
+		}
+	}
+	return nil
+}
+
+// filmCredits returns the moviecast rows described by film: the detailed
+// cast when it is given, the plain actor ids otherwise.
+func filmCredits(film structs.Film) []structs.Credit {
+	if film.Cast != nil {
+		return film.Cast
+	}
+	credits := make([]structs.Credit, len(film.Actors))
+	for i, actor := range film.Actors {
+		credits[i] = structs.Credit{ActorID: actor}
+	}
+	return credits
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"FilmCollection/db"
+	"FilmCollection/structs"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// @Summary AddGenre
+// @Description Add genre to the taxonomy
+// @ID add-genre
+// @Accept  json
+// @Param genre body structs.Genre true "Genre object that needs to be added, parent_id is optional"
+// @Param Authorization header string true "Basic auth for admin"
+// @Success 200 {object} structs.Genre
+// @Failure 400 "no request body"
+// @Failure 400 "genre name not specified"
+// @Failure 500 "error adding genre"
+// @Router /add_genre [post]
+func AddGenre(w http.ResponseWriter, r *http.Request) {
+	if r.Context().Value("admin") != true {
+		http.Error(w, "authorization error", http.StatusUnauthorized)
+		slog.Error("Authorization error: ", "error", "not admin", "status", http.StatusUnauthorized)
+		return
+	}
+	if r.Body == nil {
+		http.Error(w, "no request body", http.StatusBadRequest)
+		slog.Error("No request body: ", "status", http.StatusBadRequest)
+		return
+	}
+	var genre structs.Genre
+	err := json.NewDecoder(r.Body).Decode(&genre)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusBadRequest)
+		slog.Error("Error reading request body: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	if genre.Id != 0 {
+		http.Error(w, "id field must be empty", http.StatusBadRequest)
+		slog.Error("AddGenre", "status", http.StatusBadRequest, "error", "id field must be empty")
+		return
+	}
+	if genre.Name == "" {
+		http.Error(w, "genre name not specified", http.StatusBadRequest)
+		slog.Error("AddGenre", "status", http.StatusBadRequest, "error", "genre name not specified")
+		return
+	}
+	genre.Id, err = db.AddGenre(genre)
+	if err != nil {
+		http.Error(w, "error adding genre", http.StatusInternalServerError)
+		slog.Error("Error adding genre: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(genre)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("AddGenre Genre added", "status", http.StatusOK)
+}
+
+// @Summary GetGenres
+// @Description Get the whole genre taxonomy
+// @ID get-genres
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {array} structs.Genre
+// @Failure 500 "error reading genres"
+// @Router /get_genres [get]
+func GetGenres(w http.ResponseWriter, r *http.Request) {
+	genres, err := db.GetGenres()
+	if err != nil {
+		http.Error(w, "error reading genres", http.StatusInternalServerError)
+		slog.Error("Error reading genres: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(genres)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("GetGenres Genres retrieved", "status", http.StatusOK)
+}
+
+// @Summary UpdateGenre
+// @Description Rename a genre or move it in the hierarchy
+// @ID update-genre
+// @Accept  json
+// @Param genre body structs.Genre true "Genre object that needs to be updated"
+// @Param Authorization header string true "Basic auth for admin"
+// @Success 200 "genre updated"
+// @Failure 400 "genre id not specified"
+// @Failure 400 "genre cannot be its own ancestor"
+// @Failure 500 "error updating genre"
+// @Router /update_genre [post]
+func UpdateGenre(w http.ResponseWriter, r *http.Request) {
+	if r.Context().Value("admin") != true {
+		http.Error(w, "authorization error", http.StatusUnauthorized)
+		slog.Error("Authorization error: ", "error", "not admin", "status", http.StatusUnauthorized)
+		return
+	}
+	if r.Body == nil {
+		http.Error(w, "no request body", http.StatusBadRequest)
+		slog.Error("No request body: ", "status", http.StatusBadRequest)
+		return
+	}
+	var genre structs.Genre
+	err := json.NewDecoder(r.Body).Decode(&genre)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusBadRequest)
+		slog.Error("Error reading request body: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	if genre.Id == 0 {
+		http.Error(w, "genre id not specified", http.StatusBadRequest)
+		slog.Error("UpdateGenre", "status", http.StatusBadRequest, "error", "genre id not specified")
+		return
+	}
+	if genre.Name == "" {
+		http.Error(w, "genre name not specified", http.StatusBadRequest)
+		slog.Error("UpdateGenre", "status", http.StatusBadRequest, "error", "genre name not specified")
+		return
+	}
+	err = db.UpdateGenre(genre)
+	if errors.Is(err, db.ErrGenreCycle) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("UpdateGenre", "status", http.StatusBadRequest, "error", err)
+		return
+	}
+	if err != nil {
+		http.Error(w, "error updating genre", http.StatusInternalServerError)
+		slog.Error("Error updating genre: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	slog.Info("UpdateGenre Genre updated", "status", http.StatusOK)
+}
+
+// @Summary DeleteGenre
+// @Description Delete genre by id. Genres linked to films or having subgenres can't be deleted
+// @ID delete-genre
+// @Param id query int true "Genre id"
+// @Param Authorization header string true "Basic auth for admin"
+// @Success 200 "genre deleted"
+// @Failure 400 "invalid id format"
+// @Failure 409 "genre is in use"
+// @Failure 500 "error deleting genre"
+// @Router /delete_genre [post]
+func DeleteGenre(w http.ResponseWriter, r *http.Request) {
+	if r.Context().Value("admin") != true {
+		http.Error(w, "authorization error", http.StatusUnauthorized)
+		slog.Error("Authorization error: ", "error", "not admin", "status", http.StatusUnauthorized)
+		return
+	}
+
+	idString := r.URL.Query().Get("id")
+	id, err := strconv.Atoi(idString)
+	if err != nil {
+		http.Error(w, "invalid id format", http.StatusBadRequest)
+		slog.Error("ID format error: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	err = db.DeleteGenre(id)
+	if errors.Is(err, db.ErrGenreInUse) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		slog.Error("DeleteGenre", "status", http.StatusConflict, "error", err)
+		return
+	}
+	if err != nil {
+		http.Error(w, "error deleting genre", http.StatusInternalServerError)
+		slog.Error("Error deleting genre: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	slog.Info("DeleteGenre Genre deleted", "status", http.StatusOK)
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"FilmCollection/db"
+	"FilmCollection/structs"
+	"encoding/json"
+	"errors"
+	"github.com/jackc/pgx"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// maxPathDepth caps the max_depth parameter of GetActorPath.
+const maxPathDepth = 10
+
+// @Summary GetActorPath
+// @Description Get a shortest chain of actor - film - actor links between two actors. The search is breadth-first from both ends and gives up after max_depth links
+// @ID get-actor-path
+// @Param a path int true "Actor id to start from"
+// @Param b path int true "Actor id to reach"
+// @Param max_depth query int false "Maximum number of links, at most 10" default(6)
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {object} structs.ActorPath
+// @Failure 400 "invalid id format"
+// @Failure 400 "invalid max_depth format"
+// @Failure 404 "actor not found"
+// @Failure 404 "no path found"
+// @Failure 404 "search limit reached before a path was found"
+// @Failure 500 "error searching path"
+// @Router /actors/{a}/path/{b} [get]
+func GetActorPath(w http.ResponseWriter, r *http.Request) {
+	from, err := strconv.Atoi(r.PathValue("a"))
+	if err != nil {
+		http.Error(w, "invalid id format", http.StatusBadRequest)
+		slog.Error("ID format error: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	to, err := strconv.Atoi(r.PathValue("b"))
+	if err != nil {
+		http.Error(w, "invalid id format", http.StatusBadRequest)
+		slog.Error("ID format error: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	maxDepth := 6
+	if s := r.URL.Query().Get("max_depth"); s != "" {
+		maxDepth, err = strconv.Atoi(s)
+	}
+	if err != nil || maxDepth < 1 || maxDepth > maxPathDepth {
+		http.Error(w, "invalid max_depth format", http.StatusBadRequest)
+		slog.Error("Invalid max_depth format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	for _, id := range []int{from, to} {
+		_, err = db.GetActorByID(id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "actor not found", http.StatusNotFound)
+			slog.Error("GetActorPath", "status", http.StatusNotFound, "error", "actor not found")
+			return
+		}
+		if err != nil {
+			http.Error(w, "error reading actor", http.StatusInternalServerError)
+			slog.Error("Error reading actor: ", "error", err, "status", http.StatusInternalServerError)
+			return
+		}
+	}
+	path, err := db.ShortestActorPath(from, to, maxDepth)
+	if errors.Is(err, db.ErrNoPath) || errors.Is(err, db.ErrPathSearchLimit) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		slog.Error("GetActorPath", "status", http.StatusNotFound, "error", err)
+		return
+	}
+	if err != nil {
+		http.Error(w, "error searching path", http.StatusInternalServerError)
+		slog.Error("Error searching path: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(path)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("GetActorPath Path retrieved", "status", http.StatusOK)
+}
+
+// @Summary GetCostars
+// @Description Get the actors who appeared in films together with an actor, most shared films first
+// @ID get-costars
+// @Param id path int true "Actor id"
+// @Param limit query int false "Limit of co-stars to return" default(50)
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {array} structs.Costar
+// @Failure 400 "invalid id format"
+// @Failure 400 "invalid limit format"
+// @Failure 404 "actor not found"
+// @Failure 500 "error reading co-stars"
+// @Router /actors/{id}/costars [get]
+func GetCostars(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id format", http.StatusBadRequest)
+		slog.Error("ID format error: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	limit := 50
+	if s := r.URL.Query().Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+	}
+	if err != nil || limit < 0 {
+		http.Error(w, "invalid limit format", http.StatusBadRequest)
+		slog.Error("Invalid limit format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	_, err = db.GetActorByID(id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "actor not found", http.StatusNotFound)
+		slog.Error("GetCostars", "status", http.StatusNotFound, "error", "actor not found")
+		return
+	}
+	if err != nil {
+		http.Error(w, "error reading actor", http.StatusInternalServerError)
+		slog.Error("Error reading actor: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	costars, err := db.GetCostars(id, limit)
+	if err != nil {
+		http.Error(w, "error reading co-stars", http.StatusInternalServerError)
+		slog.Error("Error reading co-stars: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(costars)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("GetCostars Co-stars retrieved", "status", http.StatusOK)
+}
+
+// maxGraphDepth caps the depth parameter of ExportGraph.
+const maxGraphDepth = 6
+
+// @Summary ExportGraph
+// @Description Export the actor-film network built from the cast for tools such as Gephi and Graphviz. Nodes carry rating, release year and runtime of films and gender and birth date of actors. With actor or film only the nodes at most depth links away are exported
+// @ID export-graph
+// @Param format query string false "graphml, dot or json (JSON Graph Format)" default(json)
+// @Param actor query int false "Actor id to export the neighbourhood of"
+// @Param film query int false "Film id to export the neighbourhood of"
+// @Param depth query int false "Number of actor-film links around actor or film, at most 6" default(2)
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {string} string "graph in the requested format"
+// @Failure 400 "invalid format format"
+// @Failure 400 "invalid actor format"
+// @Failure 400 "invalid film format"
+// @Failure 400 "invalid depth format"
+// @Failure 400 "actor and film cannot be combined"
+// @Failure 404 "actor not found"
+// @Failure 404 "film not found"
+// @Failure 500 "error reading graph"
+// @Router /export_graph [get]
+func ExportGraph(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	format := values.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	formatInfo, ok := graphFormats[format]
+	if !ok {
+		http.Error(w, "invalid format format", http.StatusBadRequest)
+		slog.Error("ExportGraph", "status", http.StatusBadRequest, "error", "invalid format format")
+		return
+	}
+	center := &db.GraphCenter{Depth: 2}
+	var err error
+	if center.ActorID, err = optionalInt(values, "actor"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("ExportGraph", "status", http.StatusBadRequest, "error", err)
+		return
+	}
+	if center.FilmID, err = optionalInt(values, "film"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("ExportGraph", "status", http.StatusBadRequest, "error", err)
+		return
+	}
+	if s := values.Get("depth"); s != "" {
+		center.Depth, err = strconv.Atoi(s)
+	}
+	if err != nil || center.Depth < 0 || center.Depth > maxGraphDepth {
+		http.Error(w, "invalid depth format", http.StatusBadRequest)
+		slog.Error("Invalid depth format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case center.ActorID != nil && center.FilmID != nil:
+		http.Error(w, "actor and film cannot be combined", http.StatusBadRequest)
+		slog.Error("ExportGraph", "status", http.StatusBadRequest, "error", "actor and film cannot be combined")
+		return
+	case center.ActorID != nil:
+		_, err = db.GetActorByID(*center.ActorID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "actor not found", http.StatusNotFound)
+			slog.Error("ExportGraph", "status", http.StatusNotFound, "error", "actor not found")
+			return
+		}
+	case center.FilmID != nil:
+		_, err = db.GetFilmByID(*center.FilmID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "film not found", http.StatusNotFound)
+			slog.Error("ExportGraph", "status", http.StatusNotFound, "error", "film not found")
+			return
+		}
+	default:
+		center = nil
+	}
+	var graph structs.Graph
+	if err == nil {
+		graph, err = db.GetGraph(center)
+	}
+	if err != nil {
+		http.Error(w, "error reading graph", http.StatusInternalServerError)
+		slog.Error("Error reading graph: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", formatInfo[0])
+	w.Header().Set("Content-Disposition", `attachment; filename="films.`+formatInfo[1]+`"`)
+	switch format {
+	case "graphml":
+		err = writeGraphML(w, graph)
+	case "dot":
+		err = writeDOT(w, graph)
+	default:
+		err = writeJGF(w, graph)
+	}
+	if err != nil {
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("ExportGraph Graph exported", "status", http.StatusOK)
+}
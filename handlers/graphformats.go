@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"FilmCollection/structs"
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// graphFormats maps the format parameter of ExportGraph to the content type
+// and file extension of the export.
+var graphFormats = map[string][2]string{
+	"graphml": {"application/graphml+xml", "graphml"},
+	"dot":     {"text/vnd.graphviz", "dot"},
+	"json":    {"application/json", "json"},
+}
+
+// graphAttribute is a node or edge attribute of an exported graph.
+type graphAttribute struct {
+	name  string
+	value string
+	// numeric attributes are written unquoted and typed as int in GraphML
+	numeric bool
+}
+
+func actorNodeID(id int) string { return "a" + strconv.Itoa(id) }
+func filmNodeID(id int) string  { return "f" + strconv.Itoa(id) }
+
+func actorAttributes(actor structs.Person) []graphAttribute {
+	attributes := []graphAttribute{{name: "kind", value: "actor"}, {name: "label", value: actor.Name}}
+	if actor.Gender != "" {
+		attributes = append(attributes, graphAttribute{name: "gender", value: actor.Gender})
+	}
+	if !actor.BirthDate.IsZero() {
+		attributes = append(attributes, graphAttribute{name: "birth_date", value: actor.BirthDate.Format("2006-01-02")})
+	}
+	return attributes
+}
+
+func filmAttributes(film structs.Film) []graphAttribute {
+	attributes := []graphAttribute{
+		{name: "kind", value: "film"},
+		{name: "label", value: film.Name},
+		{name: "rating", value: strconv.Itoa(film.Rating), numeric: true},
+	}
+	if !film.ReleaseDate.IsZero() {
+		attributes = append(attributes, graphAttribute{name: "release_year", value: strconv.Itoa(film.ReleaseDate.Year()), numeric: true})
+	}
+	if film.Runtime > 0 {
+		attributes = append(attributes, graphAttribute{name: "runtime", value: strconv.Itoa(film.Runtime), numeric: true})
+	}
+	return attributes
+}
+
+func edgeAttributes(edge structs.GraphEdge) []graphAttribute {
+	var attributes []graphAttribute
+	if edge.Character != "" {
+		attributes = append(attributes, graphAttribute{name: "character", value: edge.Character})
+	}
+	if edge.CreditType != "" {
+		attributes = append(attributes, graphAttribute{name: "credit_type", value: edge.CreditType})
+	}
+	return attributes
+}
+
+// writeGraphML writes the graph as GraphML for Gephi, yEd and friends.
+func writeGraphML(w io.Writer, graph structs.Graph) error {
+	b := bufio.NewWriter(w)
+	b.WriteString(xml.Header)
+	b.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	for _, key := range []struct{ id, target, typ string }{
+		{"kind", "node", "string"}, {"label", "node", "string"},
+		{"rating", "node", "int"}, {"release_year", "node", "int"}, {"runtime", "node", "int"},
+		{"gender", "node", "string"}, {"birth_date", "node", "string"},
+		{"character", "edge", "string"}, {"credit_type", "edge", "string"},
+	} {
+		b.WriteString(`  <key id="` + key.id + `" for="` + key.target + `" attr.name="` + key.id + `" attr.type="` + key.typ + `"/>` + "\n")
+	}
+	b.WriteString(`  <graph id="films" edgedefault="undirected">` + "\n")
+	writeData := func(attributes []graphAttribute) {
+		for _, a := range attributes {
+			b.WriteString(`      <data key="` + a.name + `">`)
+			xml.EscapeText(b, []byte(a.value))
+			b.WriteString("</data>\n")
+		}
+	}
+	for _, actor := range graph.Actors {
+		b.WriteString(`    <node id="` + actorNodeID(actor.Id) + `">` + "\n")
+		writeData(actorAttributes(actor))
+		b.WriteString("    </node>\n")
+	}
+	for _, film := range graph.Films {
+		b.WriteString(`    <node id="` + filmNodeID(film.Id) + `">` + "\n")
+		writeData(filmAttributes(film))
+		b.WriteString("    </node>\n")
+	}
+	for _, edge := range graph.Edges {
+		b.WriteString(`    <edge source="` + actorNodeID(edge.ActorID) + `" target="` + filmNodeID(edge.FilmID) + `">` + "\n")
+		writeData(edgeAttributes(edge))
+		b.WriteString("    </edge>\n")
+	}
+	b.WriteString("  </graph>\n</graphml>\n")
+	return b.Flush()
+}
+
+// dotQuote quotes s as a DOT string.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// writeDOT writes the graph in the Graphviz DOT language, actors as
+// ellipses and films as boxes.
+func writeDOT(w io.Writer, graph structs.Graph) error {
+	b := bufio.NewWriter(w)
+	b.WriteString("graph films {\n")
+	writeAttributes := func(attributes []graphAttribute, extra string) {
+		var list []string
+		for _, a := range attributes {
+			value := dotQuote(a.value)
+			if a.numeric {
+				value = a.value
+			}
+			list = append(list, a.name+"="+value)
+		}
+		if extra != "" {
+			list = append(list, extra)
+		}
+		if len(list) > 0 {
+			b.WriteString(" [" + strings.Join(list, ", ") + "]")
+		}
+		b.WriteString(";\n")
+	}
+	for _, actor := range graph.Actors {
+		b.WriteString("  " + dotQuote(actorNodeID(actor.Id)))
+		writeAttributes(actorAttributes(actor), "shape=ellipse")
+	}
+	for _, film := range graph.Films {
+		b.WriteString("  " + dotQuote(filmNodeID(film.Id)))
+		writeAttributes(filmAttributes(film), "shape=box")
+	}
+	for _, edge := range graph.Edges {
+		b.WriteString("  " + dotQuote(actorNodeID(edge.ActorID)) + " -- " + dotQuote(filmNodeID(edge.FilmID)))
+		writeAttributes(edgeAttributes(edge), "")
+	}
+	b.WriteString("}\n")
+	return b.Flush()
+}
+
+// writeJGF writes the graph in JSON Graph Format version 2.
+func writeJGF(w io.Writer, graph structs.Graph) error {
+	type node struct {
+		Label    string                 `json:"label"`
+		Metadata map[string]interface{} `json:"metadata"`
+	}
+	type edge struct {
+		Source   string                 `json:"source"`
+		Target   string                 `json:"target"`
+		Relation string                 `json:"relation"`
+		Metadata map[string]interface{} `json:"metadata,omitempty"`
+	}
+	metadata := func(attributes []graphAttribute) map[string]interface{} {
+		m := make(map[string]interface{})
+		for _, a := range attributes {
+			if a.name == "label" {
+				continue
+			}
+			if a.numeric {
+				n, _ := strconv.Atoi(a.value)
+				m[a.name] = n
+			} else {
+				m[a.name] = a.value
+			}
+		}
+		return m
+	}
+	nodes := make(map[string]node, len(graph.Actors)+len(graph.Films))
+	for _, actor := range graph.Actors {
+		nodes[actorNodeID(actor.Id)] = node{Label: actor.Name, Metadata: metadata(actorAttributes(actor))}
+	}
+	for _, film := range graph.Films {
+		nodes[filmNodeID(film.Id)] = node{Label: film.Name, Metadata: metadata(filmAttributes(film))}
+	}
+	edges := make([]edge, 0, len(graph.Edges))
+	for _, e := range graph.Edges {
+		var m map[string]interface{}
+		if attributes := edgeAttributes(e); len(attributes) > 0 {
+			m = metadata(attributes)
+		}
+		edges = append(edges, edge{Source: actorNodeID(e.ActorID), Target: filmNodeID(e.FilmID), Relation: "cast", Metadata: m})
+	}
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"graph": map[string]interface{}{
+			"id":       "films",
+			"type":     "actor-film",
+			"directed": false,
+			"nodes":    nodes,
+			"edges":    edges,
+		},
+	})
+}
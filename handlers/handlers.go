@@ -15,4 +15,53 @@ func InitHandlers(mux *http.ServeMux) {
 	mux.HandleFunc("GET /get_films", Wrap(GetFilms))
 	mux.HandleFunc("POST /delete_actor", Wrap(DeleteActor))
 	mux.HandleFunc("POST /delete_film", Wrap(DeleteFilm))
+	mux.HandleFunc("POST /add_genre", Wrap(AddGenre))
+	mux.HandleFunc("POST /update_genre", Wrap(UpdateGenre))
+	mux.HandleFunc("GET /get_genres", Wrap(GetGenres))
+	mux.HandleFunc("POST /delete_genre", Wrap(DeleteGenre))
+	mux.HandleFunc("GET /get_tags", Wrap(GetTags))
+	mux.HandleFunc("POST /rename_tag", Wrap(RenameTag))
+	mux.HandleFunc("POST /merge_tags", Wrap(MergeTags))
+	mux.HandleFunc("POST /rate_film", Wrap(RateFilm))
+	mux.HandleFunc("POST /delete_rating", Wrap(DeleteRating))
+	mux.HandleFunc("GET /get_my_ratings", Wrap(GetMyRatings))
+	mux.HandleFunc("POST /review_film", Wrap(ReviewFilm))
+	mux.HandleFunc("POST /delete_review", Wrap(DeleteReview))
+	mux.HandleFunc("GET /get_reviews", Wrap(GetReviews))
+	mux.HandleFunc("GET /get_my_reviews", Wrap(GetMyReviews))
+	mux.HandleFunc("POST /report_review", Wrap(ReportReview))
+	mux.HandleFunc("GET /get_moderation_queue", Wrap(GetModerationQueue))
+	mux.HandleFunc("POST /moderate_review", Wrap(ModerateReview))
+	mux.HandleFunc("GET /get_review_reports", Wrap(GetReviewReports))
+	mux.HandleFunc("POST /add_list", Wrap(AddList))
+	mux.HandleFunc("GET /get_list", Wrap(GetList))
+	mux.HandleFunc("GET /get_lists", Wrap(GetLists))
+	mux.HandleFunc("GET /get_watchlist", Wrap(GetWatchlist))
+	mux.HandleFunc("POST /update_list", Wrap(UpdateList))
+	mux.HandleFunc("POST /share_list", Wrap(ShareList))
+	mux.HandleFunc("POST /delete_list", Wrap(DeleteList))
+	mux.HandleFunc("POST /add_list_item", Wrap(AddListItem))
+	mux.HandleFunc("POST /update_list_item", Wrap(UpdateListItem))
+	mux.HandleFunc("POST /remove_list_item", Wrap(RemoveListItem))
+	mux.HandleFunc("POST /reorder_list", Wrap(ReorderList))
+	mux.HandleFunc("POST /log_watch", Wrap(LogWatch))
+	mux.HandleFunc("POST /update_diary_entry", Wrap(UpdateDiaryEntry))
+	mux.HandleFunc("POST /delete_diary_entry", Wrap(DeleteDiaryEntry))
+	mux.HandleFunc("GET /get_diary", Wrap(GetDiary))
+	mux.HandleFunc("GET /get_year_review", Wrap(GetYearReview))
+	mux.HandleFunc("GET /films/{id}/similar", Wrap(GetSimilarFilms))
+	mux.HandleFunc("GET /me/recommendations", Wrap(GetRecommendations))
+	mux.HandleFunc("GET /actors/{a}/path/{b}", Wrap(GetActorPath))
+	mux.HandleFunc("GET /actors/{id}/costars", Wrap(GetCostars))
+	mux.HandleFunc("GET /actors/{id}/analytics", Wrap(GetActorAnalytics))
+	mux.HandleFunc("GET /actors/leaderboard", Wrap(GetActorLeaderboard))
+	mux.HandleFunc("GET /export_graph", Wrap(ExportGraph))
+	mux.HandleFunc("GET /stats", Wrap(GetStats))
+	mux.HandleFunc("POST /import", Wrap(Import))
+	mux.HandleFunc("GET /export", Wrap(Export))
+	mux.HandleFunc("POST /scan_library", Wrap(ScanLibrary))
+	mux.HandleFunc("POST /import_letterboxd", Wrap(ImportLetterboxd))
+	mux.HandleFunc("GET /export_letterboxd", Wrap(ExportLetterboxd))
+	mux.HandleFunc("GET /get_person", Wrap(GetPerson))
+	mux.HandleFunc("GET /get_person_films", Wrap(GetPersonFilms))
 }
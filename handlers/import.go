@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"FilmCollection/db"
+	"FilmCollection/importer"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxImportSize limits the request body of Import.
+const maxImportSize = 32 << 20
+
+// @Summary Import
+// @Description Create or update films, actors and cast links in bulk from a CSV file or a JSON array of rows. Films and actors without id are matched by name and release or birth date; cast rows find their film and actor the same way or by film_id and actor_id. Updates keep the stored values of fields left empty or zero. By default nothing is committed unless every row succeeds; with batch_size every batch is committed on its own. A dry run reports per-row errors and planned changes without committing anything
+// @ID import
+// @Accept  json
+// @Accept  text/csv
+// @Param rows body []structs.ImportRow true "Rows to import; CSV files name the columns with the same keys in a header line"
+// @Param format query string false "csv or json, defaults to csv for a text/csv Content-Type and json otherwise"
+// @Param dry_run query bool false "Only report what would change" default(false)
+// @Param batch_size query int false "Rows per transaction, 0 for one transaction for all rows" default(0)
+// @Param Authorization header string true "Basic auth for admin"
+// @Success 200 {object} structs.ImportReport
+// @Failure 400 "no request body"
+// @Failure 400 "invalid format format"
+// @Failure 400 "invalid dry_run format"
+// @Failure 400 "invalid batch_size format"
+// @Failure 400 "error reading CSV: <reason>"
+// @Failure 400 "error reading JSON: <reason>"
+// @Failure 500 "error importing rows"
+// @Router /import [post]
+func Import(w http.ResponseWriter, r *http.Request) {
+	if r.Context().Value("admin") != true {
+		http.Error(w, "authorization error", http.StatusUnauthorized)
+		slog.Error("Authorization error: ", "error", "not admin", "status", http.StatusUnauthorized)
+		return
+	}
+	if r.Body == nil {
+		http.Error(w, "no request body", http.StatusBadRequest)
+		slog.Error("No request body: ", "status", http.StatusBadRequest)
+		return
+	}
+	values := r.URL.Query()
+	format := values.Get("format")
+	if format == "" {
+		format = "json"
+		if strings.Contains(r.Header.Get("Content-Type"), "csv") {
+			format = "csv"
+		}
+	}
+	var options db.ImportOptions
+	var err error
+	if s := values.Get("dry_run"); s != "" {
+		options.DryRun, err = strconv.ParseBool(s)
+	}
+	if err != nil {
+		http.Error(w, "invalid dry_run format", http.StatusBadRequest)
+		slog.Error("Invalid dry_run format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	if s := values.Get("batch_size"); s != "" {
+		options.BatchSize, err = strconv.Atoi(s)
+	}
+	if err != nil || options.BatchSize < 0 {
+		http.Error(w, "invalid batch_size format", http.StatusBadRequest)
+		slog.Error("Invalid batch_size format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	rows, err := importer.Parse(http.MaxBytesReader(w, r.Body, maxImportSize), format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("Import", "status", http.StatusBadRequest, "error", err)
+		return
+	}
+	report, err := db.Import(rows, options)
+	if err != nil {
+		http.Error(w, "error importing rows", http.StatusInternalServerError)
+		slog.Error("Error importing rows: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(report)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Import Rows imported", "status", http.StatusOK, "rows", report.Rows, "failed", report.Failed, "dry_run", report.DryRun)
+}
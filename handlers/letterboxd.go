@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"FilmCollection/db"
+	"FilmCollection/letterboxd"
+	"FilmCollection/structs"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// @Summary ImportLetterboxd
+// @Description Add the ratings, diary and watchlist of a Letterboxd export to the authenticated user's. The body is the export zip, or with file a single ratings.csv, diary.csv or watchlist.csv. Films are matched by title, ignoring case, and release year; lines that match no film or several are listed as unmatched. Ratings replace the user's ratings, diary entries on a day already logged and films already on the watchlist are skipped
+// @ID import-letterboxd
+// @Accept  application/zip
+// @Accept  text/csv
+// @Param export body string true "Letterboxd export zip or CSV file"
+// @Param file query string false "ratings, diary or watchlist when the body is a single CSV file"
+// @Param dry_run query bool false "Only report what would be imported" default(false)
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {object} structs.LetterboxdReport
+// @Failure 400 "no request body"
+// @Failure 400 "invalid file format"
+// @Failure 400 "invalid dry_run format"
+// @Failure 400 "error reading zip: <reason>"
+// @Failure 400 "error reading <file>.csv: <reason>"
+// @Failure 500 "error importing Letterboxd export"
+// @Router /import_letterboxd [post]
+func ImportLetterboxd(w http.ResponseWriter, r *http.Request) {
+	if r.Body == nil {
+		http.Error(w, "no request body", http.StatusBadRequest)
+		slog.Error("No request body: ", "status", http.StatusBadRequest)
+		return
+	}
+	values := r.URL.Query()
+	file := values.Get("file")
+	if file != "" && !slices.Contains(structs.LetterboxdFiles, file) {
+		http.Error(w, "invalid file format", http.StatusBadRequest)
+		slog.Error("ImportLetterboxd", "status", http.StatusBadRequest, "error", "invalid file format")
+		return
+	}
+	var dryRun bool
+	var err error
+	if s := values.Get("dry_run"); s != "" {
+		dryRun, err = strconv.ParseBool(s)
+	}
+	if err != nil {
+		http.Error(w, "invalid dry_run format", http.StatusBadRequest)
+		slog.Error("Invalid dry_run format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	body := http.MaxBytesReader(w, r.Body, maxImportSize)
+	var rows []structs.LetterboxdRow
+	if file != "" {
+		rows, err = letterboxd.ReadCSV(body, file)
+	} else {
+		var data []byte
+		data, err = io.ReadAll(body)
+		if err == nil {
+			rows, err = letterboxd.ReadExport(bytes.NewReader(data), int64(len(data)))
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("ImportLetterboxd", "status", http.StatusBadRequest, "error", err)
+		return
+	}
+	report, err := db.ImportLetterboxd(userID(r), rows, dryRun)
+	if err != nil {
+		http.Error(w, "error importing Letterboxd export", http.StatusInternalServerError)
+		slog.Error("Error importing Letterboxd export: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(report)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("ImportLetterboxd Letterboxd export imported", "status", http.StatusOK, "rows", report.Rows, "unmatched", len(report.Unmatched), "dry_run", report.DryRun)
+}
+
+// @Summary ExportLetterboxd
+// @Description Export the authenticated user's ratings, diary and watchlist in the CSV format of Letterboxd exports: a zip with ratings.csv, diary.csv and watchlist.csv, or with file one of them. Ratings are written as Letterboxd's half stars; the Letterboxd URI column is left empty
+// @ID export-letterboxd
+// @Param file query string false "ratings, diary or watchlist to get a single CSV file"
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {string} string "zip or CSV file"
+// @Failure 400 "invalid file format"
+// @Failure 500 "error getting Letterboxd export"
+// @Router /export_letterboxd [get]
+func ExportLetterboxd(w http.ResponseWriter, r *http.Request) {
+	file := r.URL.Query().Get("file")
+	if file != "" && !slices.Contains(structs.LetterboxdFiles, file) {
+		http.Error(w, "invalid file format", http.StatusBadRequest)
+		slog.Error("ExportLetterboxd", "status", http.StatusBadRequest, "error", "invalid file format")
+		return
+	}
+	files, err := db.GetLetterboxdExport(userID(r))
+	if err != nil {
+		http.Error(w, "error getting Letterboxd export", http.StatusInternalServerError)
+		slog.Error("Error getting Letterboxd export: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	var b bytes.Buffer
+	contentType, name := "application/zip", "letterboxd.zip"
+	if file != "" {
+		contentType, name = "text/csv", file+".csv"
+		err = letterboxd.WriteCSV(&b, file, files[file])
+	} else {
+		err = letterboxd.WriteExport(&b, files)
+	}
+	if err != nil {
+		http.Error(w, "error getting Letterboxd export", http.StatusInternalServerError)
+		slog.Error("Error writing Letterboxd export: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	_, err = w.Write(b.Bytes())
+	if err != nil {
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("ExportLetterboxd Letterboxd export written", "status", http.StatusOK, "file", file)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"FilmCollection/library"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// @Summary ScanLibrary
+// @Description Scan the media directories set with MEDIA_DIRS now. Every video file is recorded with its film, which is read from the .nfo file next to it or from the file name and matched by IMDb id or by title and year; unknown films are created with their cast
+// @ID scan-library
+// @Param Authorization header string true "Basic auth for admin"
+// @Success 200 {object} structs.ScanReport
+// @Failure 400 "no media directories configured"
+// @Failure 500 "error scanning media library"
+// @Router /scan_library [post]
+func ScanLibrary(w http.ResponseWriter, r *http.Request) {
+	if r.Context().Value("admin") != true {
+		http.Error(w, "authorization error", http.StatusUnauthorized)
+		slog.Error("Authorization error: ", "error", "not admin", "status", http.StatusUnauthorized)
+		return
+	}
+	if len(library.Dirs) == 0 {
+		http.Error(w, "no media directories configured", http.StatusBadRequest)
+		slog.Error("ScanLibrary", "status", http.StatusBadRequest, "error", "no media directories configured")
+		return
+	}
+	report, err := library.Scan(append([]string(nil), library.Dirs...))
+	if err != nil {
+		http.Error(w, "error scanning media library", http.StatusInternalServerError)
+		slog.Error("Error scanning media library: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(report)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("ScanLibrary Media library scanned", "status", http.StatusOK)
+}
@@ -0,0 +1,560 @@
+package handlers
+
+import (
+	"FilmCollection/db"
+	"FilmCollection/structs"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Column sizes of Lists and ListItems.
+const (
+	maxListName        = 150
+	maxListDescription = 1000
+	maxListNote        = 1000
+)
+
+// validateList checks the lengths of the name and description of a list.
+func validateList(name, description string) error {
+	if utf8.RuneCountInString(name) > maxListName {
+		return errors.New("list name too long")
+	}
+	if utf8.RuneCountInString(description) > maxListDescription {
+		return errors.New("list description too long")
+	}
+	return nil
+}
+
+// @Summary AddList
+// @Description Create a list of films owned by the authenticated user. Lists are private unless public is set
+// @ID add-list
+// @Accept  json
+// @Param list body structs.List true "name, description and public of the list"
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {object} structs.List
+// @Failure 400 "list name not specified"
+// @Failure 400 "list name too long"
+// @Failure 400 "list description too long"
+// @Failure 500 "error adding list"
+// @Router /add_list [post]
+func AddList(w http.ResponseWriter, r *http.Request) {
+	if r.Body == nil {
+		http.Error(w, "no request body", http.StatusBadRequest)
+		slog.Error("No request body: ", "status", http.StatusBadRequest)
+		return
+	}
+	var list structs.List
+	err := json.NewDecoder(r.Body).Decode(&list)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusBadRequest)
+		slog.Error("Error reading request body: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	list.Name = strings.TrimSpace(list.Name)
+	if list.Name == "" {
+		http.Error(w, "list name not specified", http.StatusBadRequest)
+		slog.Error("AddList", "status", http.StatusBadRequest, "error", "list name not specified")
+		return
+	}
+	err = validateList(list.Name, list.Description)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("AddList", "status", http.StatusBadRequest, "error", err)
+		return
+	}
+	list.UserID = userID(r)
+	id, err := db.AddList(list)
+	if err != nil {
+		http.Error(w, "error adding list", http.StatusInternalServerError)
+		slog.Error("Error adding list: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	list, err = db.GetList(id, list.UserID)
+	if err != nil {
+		http.Error(w, "error reading list", http.StatusInternalServerError)
+		slog.Error("Error reading list: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(list)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("AddList List added", "status", http.StatusOK)
+}
+
+// @Summary GetList
+// @Description Get a list with its films in order. Private lists are visible to their owner or through their share token
+// @ID get-list
+// @Param id query int false "List id, for own or public lists"
+// @Param token query string false "Share token of the list"
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {object} structs.List
+// @Failure 400 "invalid id format"
+// @Failure 404 "list not found"
+// @Failure 500 "error reading list"
+// @Router /get_list [get]
+func GetList(w http.ResponseWriter, r *http.Request) {
+	var list structs.List
+	var err error
+	if token := r.URL.Query().Get("token"); token != "" {
+		list, err = db.GetListByToken(token, userID(r))
+	} else {
+		var id int
+		id, err = strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			http.Error(w, "invalid id format", http.StatusBadRequest)
+			slog.Error("ID format error: ", "error", err, "status", http.StatusBadRequest)
+			return
+		}
+		list, err = db.GetList(id, userID(r))
+	}
+	if errors.Is(err, db.ErrListNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		slog.Error("GetList", "status", http.StatusNotFound, "error", err)
+		return
+	}
+	if err != nil {
+		http.Error(w, "error reading list", http.StatusInternalServerError)
+		slog.Error("Error reading list: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(list)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("GetList List retrieved", "status", http.StatusOK)
+}
+
+// @Summary GetLists
+// @Description Get every list of the authenticated user, watchlist first
+// @ID get-lists
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {array} structs.List
+// @Failure 500 "error reading lists"
+// @Router /get_lists [get]
+func GetLists(w http.ResponseWriter, r *http.Request) {
+	lists, err := db.GetUserLists(userID(r))
+	if err != nil {
+		http.Error(w, "error reading lists", http.StatusInternalServerError)
+		slog.Error("Error reading lists: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(lists)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("GetLists Lists retrieved", "status", http.StatusOK)
+}
+
+// @Summary GetWatchlist
+// @Description Get the watchlist of the authenticated user. Its id can be used with the list item endpoints
+// @ID get-watchlist
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {object} structs.List
+// @Failure 500 "error reading watchlist"
+// @Router /get_watchlist [get]
+func GetWatchlist(w http.ResponseWriter, r *http.Request) {
+	list, err := db.GetWatchlist(userID(r))
+	if err != nil {
+		http.Error(w, "error reading watchlist", http.StatusInternalServerError)
+		slog.Error("Error reading watchlist: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(list)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("GetWatchlist Watchlist retrieved", "status", http.StatusOK)
+}
+
+// @Summary UpdateList
+// @Description Update name, description or visibility of an own list. Fields left out keep their values
+// @ID update-list
+// @Accept  json
+// @Param list body structs.ListUpdate true "id and the fields to change"
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 "list updated"
+// @Failure 400 "list id not specified"
+// @Failure 400 "list name not specified"
+// @Failure 400 "list name too long"
+// @Failure 400 "list description too long"
+// @Failure 404 "list not found"
+// @Failure 500 "error updating list"
+// @Router /update_list [post]
+func UpdateList(w http.ResponseWriter, r *http.Request) {
+	if r.Body == nil {
+		http.Error(w, "no request body", http.StatusBadRequest)
+		slog.Error("No request body: ", "status", http.StatusBadRequest)
+		return
+	}
+	var update structs.ListUpdate
+	err := json.NewDecoder(r.Body).Decode(&update)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusBadRequest)
+		slog.Error("Error reading request body: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	if update.Id == 0 {
+		http.Error(w, "list id not specified", http.StatusBadRequest)
+		slog.Error("UpdateList", "status", http.StatusBadRequest, "error", "list id not specified")
+		return
+	}
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		if name == "" {
+			http.Error(w, "list name not specified", http.StatusBadRequest)
+			slog.Error("UpdateList", "status", http.StatusBadRequest, "error", "list name not specified")
+			return
+		}
+		update.Name = &name
+	}
+	var name, description string
+	if update.Name != nil {
+		name = *update.Name
+	}
+	if update.Description != nil {
+		description = *update.Description
+	}
+	err = validateList(name, description)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("UpdateList", "status", http.StatusBadRequest, "error", err)
+		return
+	}
+	err = db.UpdateList(userID(r), update)
+	if errors.Is(err, db.ErrListNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		slog.Error("UpdateList", "status", http.StatusNotFound, "error", err)
+		return
+	}
+	if err != nil {
+		http.Error(w, "error updating list", http.StatusInternalServerError)
+		slog.Error("Error updating list: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	slog.Info("UpdateList List updated", "status", http.StatusOK)
+}
+
+// @Summary ShareList
+// @Description Create a new share link for an own list, invalidating the previous one, or stop sharing it
+// @ID share-list
+// @Param id query int true "List id"
+// @Param enabled query bool false "Whether the list is shared" default(true)
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {object} structs.List
+// @Failure 400 "invalid id format"
+// @Failure 400 "invalid enabled format"
+// @Failure 404 "list not found"
+// @Failure 500 "error sharing list"
+// @Router /share_list [post]
+func ShareList(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "invalid id format", http.StatusBadRequest)
+		slog.Error("ID format error: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	enabled := true
+	if s := r.URL.Query().Get("enabled"); s != "" {
+		enabled, err = strconv.ParseBool(s)
+	}
+	if err != nil {
+		http.Error(w, "invalid enabled format", http.StatusBadRequest)
+		slog.Error("Invalid enabled format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	err = db.ShareList(id, userID(r), enabled)
+	if errors.Is(err, db.ErrListNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		slog.Error("ShareList", "status", http.StatusNotFound, "error", err)
+		return
+	}
+	if err != nil {
+		http.Error(w, "error sharing list", http.StatusInternalServerError)
+		slog.Error("Error sharing list: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	list, err := db.GetList(id, userID(r))
+	if err != nil {
+		http.Error(w, "error reading list", http.StatusInternalServerError)
+		slog.Error("Error reading list: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(list)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("ShareList List sharing changed", "status", http.StatusOK)
+}
+
+// @Summary DeleteList
+// @Description Delete an own list with its items
+// @ID delete-list
+// @Param id query int true "List id"
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 "list deleted"
+// @Failure 400 "invalid id format"
+// @Failure 404 "list not found"
+// @Failure 500 "error deleting list"
+// @Router /delete_list [post]
+func DeleteList(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "invalid id format", http.StatusBadRequest)
+		slog.Error("ID format error: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	err = db.DeleteList(id, userID(r))
+	if errors.Is(err, db.ErrListNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		slog.Error("DeleteList", "status", http.StatusNotFound, "error", err)
+		return
+	}
+	if err != nil {
+		http.Error(w, "error deleting list", http.StatusInternalServerError)
+		slog.Error("Error deleting list: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	slog.Info("DeleteList List deleted", "status", http.StatusOK)
+}
+
+// @Summary AddListItem
+// @Description Put a film on an own list at the given position, or at the end. Adding a film already on the list replaces its note
+// @ID add-list-item
+// @Accept  json
+// @Param item body structs.ListItem true "list_id, film_id, optional note and position"
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 "film added to list"
+// @Failure 400 "list id not specified"
+// @Failure 400 "film id not specified"
+// @Failure 400 "note too long"
+// @Failure 400 "film not found"
+// @Failure 404 "list not found"
+// @Failure 500 "error adding film to list"
+// @Router /add_list_item [post]
+func AddListItem(w http.ResponseWriter, r *http.Request) {
+	if r.Body == nil {
+		http.Error(w, "no request body", http.StatusBadRequest)
+		slog.Error("No request body: ", "status", http.StatusBadRequest)
+		return
+	}
+	var item structs.ListItem
+	err := json.NewDecoder(r.Body).Decode(&item)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusBadRequest)
+		slog.Error("Error reading request body: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	if item.ListID == 0 {
+		http.Error(w, "list id not specified", http.StatusBadRequest)
+		slog.Error("AddListItem", "status", http.StatusBadRequest, "error", "list id not specified")
+		return
+	}
+	if item.FilmID == 0 {
+		http.Error(w, "film id not specified", http.StatusBadRequest)
+		slog.Error("AddListItem", "status", http.StatusBadRequest, "error", "film id not specified")
+		return
+	}
+	if utf8.RuneCountInString(item.Note) > maxListNote {
+		http.Error(w, "note too long", http.StatusBadRequest)
+		slog.Error("AddListItem", "status", http.StatusBadRequest, "error", "note too long")
+		return
+	}
+	exists, err := filmExists(item.FilmID)
+	if err != nil {
+		http.Error(w, "error adding film to list", http.StatusInternalServerError)
+		slog.Error("Error getting film: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "film not found", http.StatusBadRequest)
+		slog.Error("AddListItem", "status", http.StatusBadRequest, "error", "film not found")
+		return
+	}
+	err = db.AddListItem(userID(r), item)
+	if errors.Is(err, db.ErrFilmNotFound) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("AddListItem", "status", http.StatusBadRequest, "error", err)
+		return
+	}
+	if errors.Is(err, db.ErrListNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		slog.Error("AddListItem", "status", http.StatusNotFound, "error", err)
+		return
+	}
+	if err != nil {
+		http.Error(w, "error adding film to list", http.StatusInternalServerError)
+		slog.Error("Error adding film to list: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	slog.Info("AddListItem Film added to list", "status", http.StatusOK)
+}
+
+// @Summary UpdateListItem
+// @Description Replace the note of a film on an own list
+// @ID update-list-item
+// @Accept  json
+// @Param item body structs.ListItem true "list_id, film_id and note"
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 "note updated"
+// @Failure 400 "list id not specified"
+// @Failure 400 "film id not specified"
+// @Failure 400 "note too long"
+// @Failure 404 "film is not on the list"
+// @Failure 500 "error updating note"
+// @Router /update_list_item [post]
+func UpdateListItem(w http.ResponseWriter, r *http.Request) {
+	if r.Body == nil {
+		http.Error(w, "no request body", http.StatusBadRequest)
+		slog.Error("No request body: ", "status", http.StatusBadRequest)
+		return
+	}
+	var item structs.ListItem
+	err := json.NewDecoder(r.Body).Decode(&item)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusBadRequest)
+		slog.Error("Error reading request body: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	if item.ListID == 0 {
+		http.Error(w, "list id not specified", http.StatusBadRequest)
+		slog.Error("UpdateListItem", "status", http.StatusBadRequest, "error", "list id not specified")
+		return
+	}
+	if item.FilmID == 0 {
+		http.Error(w, "film id not specified", http.StatusBadRequest)
+		slog.Error("UpdateListItem", "status", http.StatusBadRequest, "error", "film id not specified")
+		return
+	}
+	if utf8.RuneCountInString(item.Note) > maxListNote {
+		http.Error(w, "note too long", http.StatusBadRequest)
+		slog.Error("UpdateListItem", "status", http.StatusBadRequest, "error", "note too long")
+		return
+	}
+	err = db.UpdateListNote(userID(r), item)
+	if errors.Is(err, db.ErrListItemNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		slog.Error("UpdateListItem", "status", http.StatusNotFound, "error", err)
+		return
+	}
+	if err != nil {
+		http.Error(w, "error updating note", http.StatusInternalServerError)
+		slog.Error("Error updating note: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	slog.Info("UpdateListItem Note updated", "status", http.StatusOK)
+}
+
+// @Summary RemoveListItem
+// @Description Take a film off an own list
+// @ID remove-list-item
+// @Param list_id query int true "List id"
+// @Param film_id query int true "Film id"
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 "film removed from list"
+// @Failure 400 "invalid list_id format"
+// @Failure 400 "invalid film_id format"
+// @Failure 404 "list not found"
+// @Failure 500 "error removing film from list"
+// @Router /remove_list_item [post]
+func RemoveListItem(w http.ResponseWriter, r *http.Request) {
+	listID, err := strconv.Atoi(r.URL.Query().Get("list_id"))
+	if err != nil {
+		http.Error(w, "invalid list_id format", http.StatusBadRequest)
+		slog.Error("ID format error: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	filmID, err := strconv.Atoi(r.URL.Query().Get("film_id"))
+	if err != nil {
+		http.Error(w, "invalid film_id format", http.StatusBadRequest)
+		slog.Error("ID format error: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	err = db.RemoveListItem(userID(r), listID, filmID)
+	if errors.Is(err, db.ErrListNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		slog.Error("RemoveListItem", "status", http.StatusNotFound, "error", err)
+		return
+	}
+	if err != nil {
+		http.Error(w, "error removing film from list", http.StatusInternalServerError)
+		slog.Error("Error removing film from list: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	slog.Info("RemoveListItem Film removed from list", "status", http.StatusOK)
+}
+
+// @Summary ReorderList
+// @Description Reorder an own list. film_ids must contain every film of the list exactly once
+// @ID reorder-list
+// @Accept  json
+// @Param order body structs.ListOrder true "list_id and film_ids in the new order"
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 "list reordered"
+// @Failure 400 "list id not specified"
+// @Failure 400 "new order must list every film of the list exactly once"
+// @Failure 404 "list not found"
+// @Failure 500 "error reordering list"
+// @Router /reorder_list [post]
+func ReorderList(w http.ResponseWriter, r *http.Request) {
+	if r.Body == nil {
+		http.Error(w, "no request body", http.StatusBadRequest)
+		slog.Error("No request body: ", "status", http.StatusBadRequest)
+		return
+	}
+	var order structs.ListOrder
+	err := json.NewDecoder(r.Body).Decode(&order)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusBadRequest)
+		slog.Error("Error reading request body: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	if order.ListID == 0 {
+		http.Error(w, "list id not specified", http.StatusBadRequest)
+		slog.Error("ReorderList", "status", http.StatusBadRequest, "error", "list id not specified")
+		return
+	}
+	err = db.ReorderList(userID(r), order)
+	if errors.Is(err, db.ErrListOrder) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("ReorderList", "status", http.StatusBadRequest, "error", err)
+		return
+	}
+	if errors.Is(err, db.ErrListNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		slog.Error("ReorderList", "status", http.StatusNotFound, "error", err)
+		return
+	}
+	if err != nil {
+		http.Error(w, "error reordering list", http.StatusInternalServerError)
+		slog.Error("Error reordering list: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	slog.Info("ReorderList List reordered", "status", http.StatusOK)
+}
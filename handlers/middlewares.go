@@ -46,3 +46,10 @@ func authMiddleware(f http.HandlerFunc) http.HandlerFunc {
 		f(w, r)
 	}
 }
+
+// userID returns the id of the authenticated user that authMiddleware put
+// into the request context.
+func userID(r *http.Request) int {
+	id, _ := r.Context().Value("user").(int)
+	return id
+}
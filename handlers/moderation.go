@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"FilmCollection/db"
+	"FilmCollection/moderation"
+	"FilmCollection/structs"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// moderationStatuses maps moderate_review actions to the status they set.
+var moderationStatuses = map[string]string{
+	"approve": moderation.Published,
+	"reject":  moderation.Rejected,
+	"hide":    moderation.Hidden,
+}
+
+// @Summary GetMyReviews
+// @Description Get every review of the authenticated user with its moderation status, newest first
+// @ID get-my-reviews
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {array} structs.Review
+// @Failure 500 "error reading reviews"
+// @Router /get_my_reviews [get]
+func GetMyReviews(w http.ResponseWriter, r *http.Request) {
+	reviews, err := db.GetUserReviews(userID(r))
+	if err != nil {
+		http.Error(w, "error reading reviews", http.StatusInternalServerError)
+		slog.Error("Error reading reviews: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(reviews)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("GetMyReviews Reviews retrieved", "status", http.StatusOK)
+}
+
+// @Summary ReportReview
+// @Description Report a published review to the admins
+// @ID report-review
+// @Accept  json
+// @Param report body structs.ReviewReport true "review_id and reason of the report"
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 "review reported"
+// @Failure 400 "review id not specified"
+// @Failure 400 "reason not specified"
+// @Failure 404 "review not found"
+// @Failure 500 "error reporting review"
+// @Router /report_review [post]
+func ReportReview(w http.ResponseWriter, r *http.Request) {
+	if r.Body == nil {
+		http.Error(w, "no request body", http.StatusBadRequest)
+		slog.Error("No request body: ", "status", http.StatusBadRequest)
+		return
+	}
+	var report structs.ReviewReport
+	err := json.NewDecoder(r.Body).Decode(&report)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusBadRequest)
+		slog.Error("Error reading request body: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	if report.ReviewID == 0 {
+		http.Error(w, "review id not specified", http.StatusBadRequest)
+		slog.Error("ReportReview", "status", http.StatusBadRequest, "error", "review id not specified")
+		return
+	}
+	report.Reason = strings.TrimSpace(report.Reason)
+	if report.Reason == "" {
+		http.Error(w, "reason not specified", http.StatusBadRequest)
+		slog.Error("ReportReview", "status", http.StatusBadRequest, "error", "reason not specified")
+		return
+	}
+	report.UserID = userID(r)
+	err = db.ReportReview(report)
+	if errors.Is(err, db.ErrReviewNotFound) {
+		http.Error(w, "review not found", http.StatusNotFound)
+		slog.Error("ReportReview", "status", http.StatusNotFound, "error", "review not found")
+		return
+	}
+	if err != nil {
+		http.Error(w, "error reporting review", http.StatusInternalServerError)
+		slog.Error("Error reporting review: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	slog.Info("ReportReview Review reported", "status", http.StatusOK)
+}
+
+// @Summary GetModerationQueue
+// @Description Get reviews with the given status, oldest first
+// @ID get-moderation-queue
+// @Param status query string false "pending, published, rejected or hidden" default(pending)
+// @Param limit query int false "Limit of reviews to return" default(50)
+// @Param Authorization header string true "Basic auth for admin"
+// @Success 200 {array} structs.Review
+// @Failure 400 "invalid status"
+// @Failure 400 "invalid limit format"
+// @Failure 401 "authorization error"
+// @Failure 500 "error reading reviews"
+// @Router /get_moderation_queue [get]
+func GetModerationQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Context().Value("admin") != true {
+		http.Error(w, "authorization error", http.StatusUnauthorized)
+		slog.Error("Authorization error: ", "error", "not admin", "status", http.StatusUnauthorized)
+		return
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = moderation.Pending
+	case moderation.Pending, moderation.Published, moderation.Rejected, moderation.Hidden:
+	default:
+		http.Error(w, "invalid status", http.StatusBadRequest)
+		slog.Error("GetModerationQueue", "status", http.StatusBadRequest, "error", "invalid status")
+		return
+	}
+	limitString := r.URL.Query().Get("limit")
+	limit := 50
+	var err error
+	if limitString != "" {
+		limit, err = strconv.Atoi(limitString)
+	}
+	if err != nil {
+		http.Error(w, "invalid limit format", http.StatusBadRequest)
+		slog.Error("Invalid limit format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	reviews, err := db.GetReviewsByStatus(status, limit)
+	if err != nil {
+		http.Error(w, "error reading reviews", http.StatusInternalServerError)
+		slog.Error("Error reading reviews: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(reviews)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("GetModerationQueue Reviews retrieved", "status", http.StatusOK)
+}
+
+// @Summary ModerateReview
+// @Description Approve, reject or hide a review. Open reports about the review are resolved
+// @ID moderate-review
+// @Accept  json
+// @Param action body structs.ModerationAction true "review_id, action (approve, reject or hide) and an optional note for the author"
+// @Param Authorization header string true "Basic auth for admin"
+// @Success 200 "review moderated"
+// @Failure 400 "review id not specified"
+// @Failure 400 "invalid action"
+// @Failure 401 "authorization error"
+// @Failure 404 "review not found"
+// @Failure 500 "error moderating review"
+// @Router /moderate_review [post]
+func ModerateReview(w http.ResponseWriter, r *http.Request) {
+	if r.Context().Value("admin") != true {
+		http.Error(w, "authorization error", http.StatusUnauthorized)
+		slog.Error("Authorization error: ", "error", "not admin", "status", http.StatusUnauthorized)
+		return
+	}
+	if r.Body == nil {
+		http.Error(w, "no request body", http.StatusBadRequest)
+		slog.Error("No request body: ", "status", http.StatusBadRequest)
+		return
+	}
+	var action structs.ModerationAction
+	err := json.NewDecoder(r.Body).Decode(&action)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusBadRequest)
+		slog.Error("Error reading request body: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	if action.ReviewID == 0 {
+		http.Error(w, "review id not specified", http.StatusBadRequest)
+		slog.Error("ModerateReview", "status", http.StatusBadRequest, "error", "review id not specified")
+		return
+	}
+	status, ok := moderationStatuses[action.Action]
+	if !ok {
+		http.Error(w, "invalid action", http.StatusBadRequest)
+		slog.Error("ModerateReview", "status", http.StatusBadRequest, "error", "invalid action")
+		return
+	}
+	err = db.ModerateReview(action.ReviewID, status, strings.TrimSpace(action.Note))
+	if errors.Is(err, db.ErrReviewNotFound) {
+		http.Error(w, "review not found", http.StatusNotFound)
+		slog.Error("ModerateReview", "status", http.StatusNotFound, "error", "review not found")
+		return
+	}
+	if err != nil {
+		http.Error(w, "error moderating review", http.StatusInternalServerError)
+		slog.Error("Error moderating review: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	slog.Info("ModerateReview Review moderated", "status", http.StatusOK)
+}
+
+// @Summary GetReviewReports
+// @Description Get review reports from all users, newest first
+// @ID get-review-reports
+// @Param resolved query bool false "Include resolved reports" default(false)
+// @Param limit query int false "Limit of reports to return" default(50)
+// @Param Authorization header string true "Basic auth for admin"
+// @Success 200 {array} structs.ReviewReport
+// @Failure 400 "invalid resolved format"
+// @Failure 400 "invalid limit format"
+// @Failure 401 "authorization error"
+// @Failure 500 "error reading reports"
+// @Router /get_review_reports [get]
+func GetReviewReports(w http.ResponseWriter, r *http.Request) {
+	if r.Context().Value("admin") != true {
+		http.Error(w, "authorization error", http.StatusUnauthorized)
+		slog.Error("Authorization error: ", "error", "not admin", "status", http.StatusUnauthorized)
+		return
+	}
+	var resolved bool
+	var err error
+	if s := r.URL.Query().Get("resolved"); s != "" {
+		resolved, err = strconv.ParseBool(s)
+	}
+	if err != nil {
+		http.Error(w, "invalid resolved format", http.StatusBadRequest)
+		slog.Error("Invalid resolved format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	limitString := r.URL.Query().Get("limit")
+	limit := 50
+	if limitString != "" {
+		limit, err = strconv.Atoi(limitString)
+	}
+	if err != nil {
+		http.Error(w, "invalid limit format", http.StatusBadRequest)
+		slog.Error("Invalid limit format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	reports, err := db.GetReviewReports(resolved, limit)
+	if err != nil {
+		http.Error(w, "error reading reports", http.StatusInternalServerError)
+		slog.Error("Error reading reports: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(reports)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("GetReviewReports Reports retrieved", "status", http.StatusOK)
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"FilmCollection/db"
+	"FilmCollection/structs"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func formatError(name string) error {
+	return errors.New("invalid " + name + " format")
+}
+
+// optionalInt reads an integer query parameter, returning nil if it is absent.
+func optionalInt(values url.Values, name string) (*int, error) {
+	s := values.Get(name)
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, formatError(name)
+	}
+	return &v, nil
+}
+
+// optionalDate reads a date query parameter in any layout accepted by structs.ParseDate.
+func optionalDate(values url.Values, name string) (*time.Time, error) {
+	s := values.Get(name)
+	if s == "" {
+		return nil, nil
+	}
+	d, err := structs.ParseDate(s)
+	if err != nil {
+		return nil, formatError(name)
+	}
+	return &d.Time, nil
+}
+
+// intList reads a comma separated list of integers, e.g. "actors=1,2,3".
+func intList(values url.Values, name string) ([]int, error) {
+	s := values.Get(name)
+	if s == "" {
+		return nil, nil
+	}
+	var list []int
+	for _, part := range strings.Split(s, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, formatError(name)
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+// stringList reads a comma separated list of strings, e.g. "tags=heist,christmas".
+func stringList(values url.Values, name string) []string {
+	s := values.Get(name)
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func filmFilterFromQuery(values url.Values) (db.FilmFilter, error) {
+	var filter db.FilmFilter
+	var err error
+	if filter.Query, err = db.ParseFilmQuery(values.Get("keyword")); err != nil {
+		return filter, err
+	}
+	if filter.MinRating, err = optionalInt(values, "min_rating"); err != nil {
+		return filter, err
+	}
+	if filter.MaxRating, err = optionalInt(values, "max_rating"); err != nil {
+		return filter, err
+	}
+	if filter.ReleasedFrom, err = optionalDate(values, "released_from"); err != nil {
+		return filter, err
+	}
+	if filter.ReleasedTo, err = optionalDate(values, "released_to"); err != nil {
+		return filter, err
+	}
+	if filter.ActorIDs, err = intList(values, "actors"); err != nil {
+		return filter, err
+	}
+	switch strings.ToLower(values.Get("actors_match")) {
+	case "", "any":
+	case "all":
+		filter.AllActors = true
+	default:
+		return filter, formatError("actors_match")
+	}
+	if filter.GenreIDs, err = intList(values, "genres"); err != nil {
+		return filter, err
+	}
+	filter.Tags = stringList(values, "tags")
+	filter.ExcludeTags = stringList(values, "exclude_tags")
+	if filter.MinCast, err = optionalInt(values, "min_cast"); err != nil {
+		return filter, err
+	}
+	if filter.MaxCast, err = optionalInt(values, "max_cast"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+func actorFilterFromQuery(values url.Values) (db.ActorFilter, error) {
+	var filter db.ActorFilter
+	var err error
+	filter.Keyword = values.Get("keyword")
+	filter.Gender = values.Get("gender")
+	if filter.BornFromYear, err = optionalInt(values, "born_from"); err != nil {
+		return filter, err
+	}
+	if filter.BornToYear, err = optionalInt(values, "born_to"); err != nil {
+		return filter, err
+	}
+	if filter.FilmID, err = optionalInt(values, "film"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// sortFromQuery reads the "sort" parameter, e.g. "sort=-rating,name". When it
+// is absent the older sort_parameter/reverse pair is used instead.
+func sortFromQuery(values url.Values, columns map[string]string, defaultKey string) ([]db.SortKey, error) {
+	if s := values.Get("sort"); s != "" {
+		keys, err := db.ParseSort(s, columns)
+		if err != nil {
+			return nil, errors.New("invalid sort format: " + err.Error())
+		}
+		return keys, nil
+	}
+	reverse := strings.ToLower(values.Get("reverse"))
+	if reverse == "" {
+		reverse = "true"
+	}
+	if reverse != "true" && reverse != "false" {
+		return nil, formatError("reverse")
+	}
+	sortParameter := values.Get("sort_parameter")
+	if sortParameter == "" {
+		sortParameter = defaultKey
+	}
+	if _, ok := columns[sortParameter]; !ok {
+		return nil, formatError("sort_parameter")
+	}
+	return []db.SortKey{{Name: sortParameter, Desc: reverse == "true"}}, nil
+}
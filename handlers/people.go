@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"FilmCollection/db"
+	"FilmCollection/structs"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// @Summary GetPerson
+// @Description Get a person with all of their credits grouped by department
+// @ID get-person
+// @Param id query int true "Person id"
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {object} structs.PersonPage
+// @Failure 400 "invalid id format"
+// @Failure 500 "error reading person"
+// @Router /get_person [get]
+func GetPerson(w http.ResponseWriter, r *http.Request) {
+	idString := r.URL.Query().Get("id")
+	id, err := strconv.Atoi(idString)
+	if err != nil {
+		http.Error(w, "invalid id format", http.StatusBadRequest)
+		slog.Error("ID format error: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	page, err := db.GetPersonPage(id)
+	if err != nil {
+		http.Error(w, "error reading person", http.StatusInternalServerError)
+		slog.Error("Error reading person: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(page)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("GetPerson Person retrieved", "status", http.StatusOK)
+}
+
+// @Summary GetPersonFilms
+// @Description Get films a person worked on in a department, e.g. films directed by them
+// @ID get-person-films
+// @Param id query int true "Person id"
+// @Param department query string false "Crew department" default("directing")
+// @Param limit query int false "Limit of films to return" default(100)
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {array} structs.Film
+// @Failure 400 "invalid id format"
+// @Failure 400 "invalid department format"
+// @Failure 500 "error reading films"
+// @Router /get_person_films [get]
+func GetPersonFilms(w http.ResponseWriter, r *http.Request) {
+	idString := r.URL.Query().Get("id")
+	id, err := strconv.Atoi(idString)
+	if err != nil {
+		http.Error(w, "invalid id format", http.StatusBadRequest)
+		slog.Error("ID format error: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	department := r.URL.Query().Get("department")
+	if department == "" {
+		department = "directing"
+	}
+	if !contains(structs.Departments, department) {
+		http.Error(w, "invalid department format", http.StatusBadRequest)
+		slog.Error("Invalid department format: ", "department", department, "status", http.StatusBadRequest)
+		return
+	}
+	limitString := r.URL.Query().Get("limit")
+	limit := 100
+	if limitString != "" {
+		limit, err = strconv.Atoi(limitString)
+	}
+	if err != nil {
+		http.Error(w, "invalid limit format", http.StatusBadRequest)
+		slog.Error("Invalid limit format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	filter := db.FilmFilter{CrewPersonID: &id, Department: department}
+	sort := []db.SortKey{{Name: "release_date", Desc: true}}
+	films, err := db.SearchFilms(filter, sort, limit)
+	if err != nil {
+		http.Error(w, "error reading films", http.StatusInternalServerError)
+		slog.Error("Error reading films: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	if films == nil {
+		films = []structs.Film{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(films)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("GetPersonFilms Films retrieved", "status", http.StatusOK)
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"FilmCollection/db"
+	"FilmCollection/moderation"
+	"FilmCollection/structs"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxReviewLength is the length of the body column of Reviews.
+const maxReviewLength = 10000
+
+// @Summary RateFilm
+// @Description Rate a film as the authenticated user. Rating again replaces the previous score
+// @ID rate-film
+// @Accept  json
+// @Param rating body structs.UserRating true "film_id and rating from 1 to 10"
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 "film rated"
+// @Failure 400 "film id not specified"
+// @Failure 400 "rating must be between 1 and 10"
+// @Failure 400 "film not found"
+// @Failure 500 "error rating film"
+// @Router /rate_film [post]
+func RateFilm(w http.ResponseWriter, r *http.Request) {
+	if r.Body == nil {
+		http.Error(w, "no request body", http.StatusBadRequest)
+		slog.Error("No request body: ", "status", http.StatusBadRequest)
+		return
+	}
+	var rating structs.UserRating
+	err := json.NewDecoder(r.Body).Decode(&rating)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusBadRequest)
+		slog.Error("Error reading request body: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	if rating.FilmID == 0 {
+		http.Error(w, "film id not specified", http.StatusBadRequest)
+		slog.Error("RateFilm", "status", http.StatusBadRequest, "error", "film id not specified")
+		return
+	}
+	if rating.Rating < 1 || rating.Rating > 10 {
+		http.Error(w, "rating must be between 1 and 10", http.StatusBadRequest)
+		slog.Error("RateFilm", "status", http.StatusBadRequest, "error", "rating out of range")
+		return
+	}
+	exists, err := filmExists(rating.FilmID)
+	if err != nil {
+		http.Error(w, "error rating film", http.StatusInternalServerError)
+		slog.Error("Error getting film: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "film not found", http.StatusBadRequest)
+		slog.Error("RateFilm", "status", http.StatusBadRequest, "error", "film not found")
+		return
+	}
+	rating.UserID = userID(r)
+	err = db.RateFilm(rating)
+	if err != nil {
+		http.Error(w, "error rating film", http.StatusInternalServerError)
+		slog.Error("Error rating film: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	slog.Info("RateFilm Film rated", "status", http.StatusOK)
+}
+
+// @Summary DeleteRating
+// @Description Delete the authenticated user's rating of a film
+// @ID delete-rating
+// @Param film_id query int true "Film id"
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 "rating deleted"
+// @Failure 400 "invalid film_id format"
+// @Failure 500 "error deleting rating"
+// @Router /delete_rating [post]
+func DeleteRating(w http.ResponseWriter, r *http.Request) {
+	filmID, err := strconv.Atoi(r.URL.Query().Get("film_id"))
+	if err != nil {
+		http.Error(w, "invalid film_id format", http.StatusBadRequest)
+		slog.Error("ID format error: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	err = db.DeleteRating(userID(r), filmID)
+	if err != nil {
+		http.Error(w, "error deleting rating", http.StatusInternalServerError)
+		slog.Error("Error deleting rating: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	slog.Info("DeleteRating Rating deleted", "status", http.StatusOK)
+}
+
+// @Summary GetMyRatings
+// @Description Get every rating of the authenticated user, most recent first
+// @ID get-my-ratings
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {array} structs.UserRating
+// @Failure 500 "error reading ratings"
+// @Router /get_my_ratings [get]
+func GetMyRatings(w http.ResponseWriter, r *http.Request) {
+	ratings, err := db.GetUserRatings(userID(r))
+	if err != nil {
+		http.Error(w, "error reading ratings", http.StatusInternalServerError)
+		slog.Error("Error reading ratings: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(ratings)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("GetMyRatings Ratings retrieved", "status", http.StatusOK)
+}
+
+// @Summary ReviewFilm
+// @Description Write or edit the authenticated user's review of a film. Depending on the moderation mode the review is published right away or held for approval. An edited review that a moderator rejected or hid is held for approval again
+// @ID review-film
+// @Accept  json
+// @Param review body structs.Review true "film_id and body of the review"
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {object} structs.Review
+// @Failure 400 "film id not specified"
+// @Failure 400 "review body not specified"
+// @Failure 400 "review body too long"
+// @Failure 400 "film not found"
+// @Failure 500 "error saving review"
+// @Router /review_film [post]
+func ReviewFilm(w http.ResponseWriter, r *http.Request) {
+	if r.Body == nil {
+		http.Error(w, "no request body", http.StatusBadRequest)
+		slog.Error("No request body: ", "status", http.StatusBadRequest)
+		return
+	}
+	var review structs.Review
+	err := json.NewDecoder(r.Body).Decode(&review)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusBadRequest)
+		slog.Error("Error reading request body: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	if review.FilmID == 0 {
+		http.Error(w, "film id not specified", http.StatusBadRequest)
+		slog.Error("ReviewFilm", "status", http.StatusBadRequest, "error", "film id not specified")
+		return
+	}
+	review.Body = strings.TrimSpace(review.Body)
+	if review.Body == "" {
+		http.Error(w, "review body not specified", http.StatusBadRequest)
+		slog.Error("ReviewFilm", "status", http.StatusBadRequest, "error", "review body not specified")
+		return
+	}
+	if utf8.RuneCountInString(review.Body) > maxReviewLength {
+		http.Error(w, "review body too long", http.StatusBadRequest)
+		slog.Error("ReviewFilm", "status", http.StatusBadRequest, "error", "review body too long")
+		return
+	}
+	exists, err := filmExists(review.FilmID)
+	if err != nil {
+		http.Error(w, "error saving review", http.StatusInternalServerError)
+		slog.Error("Error getting film: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "film not found", http.StatusBadRequest)
+		slog.Error("ReviewFilm", "status", http.StatusBadRequest, "error", "film not found")
+		return
+	}
+	review.UserID = userID(r)
+	review.Status, review.ModerationNote = moderation.Current.Status(review)
+	id, err := db.SaveReview(review)
+	if err != nil {
+		http.Error(w, "error saving review", http.StatusInternalServerError)
+		slog.Error("Error saving review: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	review, err = db.GetReviewByID(id)
+	if err != nil {
+		http.Error(w, "error reading review", http.StatusInternalServerError)
+		slog.Error("Error reading review: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(review)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("ReviewFilm Review saved", "status", http.StatusOK)
+}
+
+// @Summary DeleteReview
+// @Description Delete the authenticated user's review of a film
+// @ID delete-review
+// @Param film_id query int true "Film id"
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 "review deleted"
+// @Failure 400 "invalid film_id format"
+// @Failure 500 "error deleting review"
+// @Router /delete_review [post]
+func DeleteReview(w http.ResponseWriter, r *http.Request) {
+	filmID, err := strconv.Atoi(r.URL.Query().Get("film_id"))
+	if err != nil {
+		http.Error(w, "invalid film_id format", http.StatusBadRequest)
+		slog.Error("ID format error: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	err = db.DeleteReview(userID(r), filmID)
+	if err != nil {
+		http.Error(w, "error deleting review", http.StatusInternalServerError)
+		slog.Error("Error deleting review: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	slog.Info("DeleteReview Review deleted", "status", http.StatusOK)
+}
+
+// @Summary GetReviews
+// @Description Get published reviews of a film, newest first
+// @ID get-reviews
+// @Param film_id query int true "Film id"
+// @Param limit query int false "Limit of reviews to return" default(10)
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {array} structs.Review
+// @Failure 400 "invalid film_id format"
+// @Failure 400 "invalid limit format"
+// @Failure 500 "error reading reviews"
+// @Router /get_reviews [get]
+func GetReviews(w http.ResponseWriter, r *http.Request) {
+	filmID, err := strconv.Atoi(r.URL.Query().Get("film_id"))
+	if err != nil {
+		http.Error(w, "invalid film_id format", http.StatusBadRequest)
+		slog.Error("ID format error: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	limitString := r.URL.Query().Get("limit")
+	limit := 10
+	if limitString != "" {
+		limit, err = strconv.Atoi(limitString)
+	}
+	if err != nil {
+		http.Error(w, "invalid limit format", http.StatusBadRequest)
+		slog.Error("Invalid limit format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	reviews, err := db.GetFilmReviews(filmID, limit)
+	if err != nil {
+		http.Error(w, "error reading reviews", http.StatusInternalServerError)
+		slog.Error("Error reading reviews: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(reviews)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("GetReviews Reviews retrieved", "status", http.StatusOK)
+}
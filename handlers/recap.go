@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"FilmCollection/db"
+	"FilmCollection/structs"
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// @Summary GetYearReview
+// @Description Get viewing statistics of the authenticated user for a year, computed from the diary. With format=csv the statistics are returned as section,key,value rows
+// @ID get-year-review
+// @Param year query int false "Year of the review, defaults to the current year"
+// @Param top_actors query int false "Number of most watched actors to return" default(10)
+// @Param format query string false "json or csv" default(json)
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {object} structs.YearReview
+// @Failure 400 "invalid year format"
+// @Failure 400 "invalid top_actors format"
+// @Failure 400 "invalid format format"
+// @Failure 500 "error reading year review"
+// @Router /get_year_review [get]
+func GetYearReview(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	year := time.Now().Year()
+	var err error
+	if s := values.Get("year"); s != "" {
+		year, err = strconv.Atoi(s)
+	}
+	if err != nil || year < 1 || year > 9999 {
+		http.Error(w, "invalid year format", http.StatusBadRequest)
+		slog.Error("Invalid year format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	topActors := 10
+	if s := values.Get("top_actors"); s != "" {
+		topActors, err = strconv.Atoi(s)
+	}
+	if err != nil {
+		http.Error(w, "invalid top_actors format", http.StatusBadRequest)
+		slog.Error("Invalid top_actors format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	format := values.Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		http.Error(w, "invalid format format", http.StatusBadRequest)
+		slog.Error("GetYearReview", "status", http.StatusBadRequest, "error", "invalid format format")
+		return
+	}
+	review, err := db.GetYearReview(userID(r), year, topActors)
+	if err != nil {
+		http.Error(w, "error reading year review", http.StatusInternalServerError)
+		slog.Error("Error reading year review: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="year-review-`+strconv.Itoa(year)+`.csv"`)
+		err = writeYearReviewCSV(w, review)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(review)
+	}
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("GetYearReview Year review retrieved", "status", http.StatusOK)
+}
+
+// writeYearReviewCSV flattens the review into section,key,value rows.
+func writeYearReviewCSV(w http.ResponseWriter, review structs.YearReview) error {
+	out := csv.NewWriter(w)
+	itoa := strconv.Itoa
+	records := [][]string{
+		{"section", "key", "value"},
+		{"summary", "year", itoa(review.Year)},
+		{"summary", "films_watched", itoa(review.FilmsWatched)},
+		{"summary", "unique_films", itoa(review.UniqueFilms)},
+		{"summary", "first_watches", itoa(review.FirstWatches)},
+		{"summary", "rewatches", itoa(review.Rewatches)},
+		{"summary", "total_runtime", itoa(review.TotalRuntime)},
+		{"summary", "unknown_runtime", itoa(review.UnknownRuntime)},
+		{"summary", "average_rating", strconv.FormatFloat(review.AverageRating, 'f', 2, 64)},
+		{"summary", "busiest_month", itoa(review.BusiestMonth)},
+	}
+	for _, rating := range review.RatingDistribution {
+		records = append(records, []string{"rating", itoa(rating.Rating), itoa(rating.Count)})
+	}
+	for _, month := range review.Months {
+		records = append(records, []string{"month", itoa(month.Month), itoa(month.Count)})
+	}
+	for _, actor := range review.TopActors {
+		records = append(records, []string{"actor", actor.Name, itoa(actor.Count)})
+	}
+	err := out.WriteAll(records)
+	if err != nil {
+		return err
+	}
+	return out.Error()
+}
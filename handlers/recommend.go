@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"FilmCollection/db"
+	"FilmCollection/structs"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// @Summary GetRecommendations
+// @Description Get films the authenticated user has not rated or watched yet, ranked by how much the user liked their actors, genres and tags. Every result lists the reasons it was recommended
+// @ID get-recommendations
+// @Param genres query string false "Comma separated genre ids, subgenres included"
+// @Param max_runtime query int false "Maximum runtime in minutes, films of unknown length are excluded"
+// @Param limit query int false "Limit of films to return" default(10)
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {array} structs.Recommendation
+// @Failure 400 "invalid genres format"
+// @Failure 400 "invalid max_runtime format"
+// @Failure 400 "invalid limit format"
+// @Failure 500 "error reading recommendations"
+// @Router /me/recommendations [get]
+func GetRecommendations(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	var filter db.RecommendationFilter
+	var err error
+	if filter.GenreIDs, err = intList(values, "genres"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("Invalid filter: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	if filter.MaxRuntime, err = optionalInt(values, "max_runtime"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("Invalid filter: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	limit := 10
+	if s := values.Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+	}
+	if err != nil || limit < 0 {
+		http.Error(w, "invalid limit format", http.StatusBadRequest)
+		slog.Error("Invalid limit format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	recommendations, err := db.GetRecommendations(userID(r), filter, limit)
+	if err == nil {
+		nested := make([]*structs.Film, len(recommendations))
+		for i := range recommendations {
+			nested[i] = &recommendations[i].Film
+		}
+		err = markWatchlisted(userID(r), nested...)
+	}
+	if err != nil {
+		http.Error(w, "error reading recommendations", http.StatusInternalServerError)
+		slog.Error("Error reading recommendations: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(recommendations)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("GetRecommendations Recommendations retrieved", "status", http.StatusOK)
+}
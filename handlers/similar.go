@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"FilmCollection/db"
+	"FilmCollection/structs"
+	"encoding/json"
+	"errors"
+	"github.com/jackc/pgx"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// @Summary GetSimilarFilms
+// @Description Get films ranked by similarity to a film: shared actors, genres and tags, release year proximity and rating closeness. Every result lists the reasons it was recommended
+// @ID get-similar-films
+// @Param id path int true "Film id"
+// @Param limit query int false "Limit of films to return" default(10)
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {array} structs.SimilarFilm
+// @Failure 400 "invalid id format"
+// @Failure 400 "invalid limit format"
+// @Failure 404 "film not found"
+// @Failure 500 "error reading similar films"
+// @Router /films/{id}/similar [get]
+func GetSimilarFilms(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id format", http.StatusBadRequest)
+		slog.Error("ID format error: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	limit := 10
+	if s := r.URL.Query().Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+	}
+	if err != nil || limit < 0 {
+		http.Error(w, "invalid limit format", http.StatusBadRequest)
+		slog.Error("Invalid limit format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	_, err = db.GetFilmByID(id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "film not found", http.StatusNotFound)
+		slog.Error("GetSimilarFilms", "status", http.StatusNotFound, "error", "film not found")
+		return
+	}
+	if err != nil {
+		http.Error(w, "error reading film", http.StatusInternalServerError)
+		slog.Error("Error reading film: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	similar, err := db.GetSimilarFilms(id, limit)
+	if err == nil {
+		nested := make([]*structs.Film, len(similar))
+		for i := range similar {
+			nested[i] = &similar[i].Film
+		}
+		err = markWatchlisted(userID(r), nested...)
+	}
+	if err != nil {
+		http.Error(w, "error reading similar films", http.StatusInternalServerError)
+		slog.Error("Error reading similar films: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(similar)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("GetSimilarFilms Similar films retrieved", "status", http.StatusOK)
+}
+
+// markWatchlisted sets OnWatchlist of films nested in other responses.
+func markWatchlisted(user int, nested ...*structs.Film) error {
+	films := make([]structs.Film, len(nested))
+	for i, film := range nested {
+		films[i] = *film
+	}
+	err := db.MarkWatchlisted(films, user)
+	if err != nil {
+		return err
+	}
+	for i, film := range nested {
+		*film = films[i]
+	}
+	return nil
+}
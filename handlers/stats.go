@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"FilmCollection/db"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// @Summary GetStats
+// @Description Get statistics of the catalogue: film and actor counts, rating histogram, films per release year and decade, cast sizes, gender breakdown of actors and the actors with the most films. With a release date range only films released in it and their cast are counted
+// @ID get-stats
+// @Param released_from query string false "Earliest release date, e.g. 1990-01-01"
+// @Param released_to query string false "Latest release date, e.g. 1999-12-31"
+// @Param top_actors query int false "Number of actors with the most films to return" default(10)
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {object} structs.CatalogueStats
+// @Failure 400 "invalid released_from format"
+// @Failure 400 "invalid released_to format"
+// @Failure 400 "invalid top_actors format"
+// @Failure 500 "error reading statistics"
+// @Router /stats [get]
+func GetStats(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	var filter db.StatsFilter
+	var err error
+	if filter.ReleasedFrom, err = optionalDate(values, "released_from"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("Invalid filter: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	if filter.ReleasedTo, err = optionalDate(values, "released_to"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("Invalid filter: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	topActors := 10
+	if s := values.Get("top_actors"); s != "" {
+		topActors, err = strconv.Atoi(s)
+	}
+	if err != nil || topActors < 0 {
+		http.Error(w, "invalid top_actors format", http.StatusBadRequest)
+		slog.Error("Invalid top_actors format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	stats, err := db.GetCatalogueStats(filter, topActors)
+	if err != nil {
+		http.Error(w, "error reading statistics", http.StatusInternalServerError)
+		slog.Error("Error reading statistics: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(stats)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("GetStats Statistics retrieved", "status", http.StatusOK)
+}
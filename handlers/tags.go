@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"FilmCollection/db"
+	"FilmCollection/structs"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// @Summary GetTags
+// @Description Autocomplete tags by prefix, most used first, with film counts
+// @ID get-tags
+// @Param prefix query string false "Beginning of the tag name" default("")
+// @Param limit query int false "Limit of tags to return" default(10)
+// @Param Authorization header string true "Basic auth for user"
+// @Success 200 {array} structs.Tag
+// @Failure 400 "invalid limit format"
+// @Failure 500 "error reading tags"
+// @Router /get_tags [get]
+func GetTags(w http.ResponseWriter, r *http.Request) {
+	limitString := r.URL.Query().Get("limit")
+	limit := 10
+	var err error
+	if limitString != "" {
+		limit, err = strconv.Atoi(limitString)
+	}
+	if err != nil {
+		http.Error(w, "invalid limit format", http.StatusBadRequest)
+		slog.Error("Invalid limit format: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	tags, err := db.SearchTags(r.URL.Query().Get("prefix"), limit)
+	if err != nil {
+		http.Error(w, "error reading tags", http.StatusInternalServerError)
+		slog.Error("Error reading tags: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(tags)
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		slog.Error("Error writing response: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("GetTags Tags retrieved", "status", http.StatusOK)
+}
+
+// @Summary RenameTag
+// @Description Rename a tag. The name is normalized like every tag name
+// @ID rename-tag
+// @Accept  json
+// @Param tag body structs.Tag true "Tag id and new name"
+// @Param Authorization header string true "Basic auth for admin"
+// @Success 200 "tag renamed"
+// @Failure 400 "tag id not specified"
+// @Failure 400 "tag name not specified"
+// @Failure 409 "tag with this name already exists"
+// @Failure 500 "error renaming tag"
+// @Router /rename_tag [post]
+func RenameTag(w http.ResponseWriter, r *http.Request) {
+	if r.Context().Value("admin") != true {
+		http.Error(w, "authorization error", http.StatusUnauthorized)
+		slog.Error("Authorization error: ", "error", "not admin", "status", http.StatusUnauthorized)
+		return
+	}
+	if r.Body == nil {
+		http.Error(w, "no request body", http.StatusBadRequest)
+		slog.Error("No request body: ", "status", http.StatusBadRequest)
+		return
+	}
+	var tag structs.Tag
+	err := json.NewDecoder(r.Body).Decode(&tag)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusBadRequest)
+		slog.Error("Error reading request body: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	if tag.Id == 0 {
+		http.Error(w, "tag id not specified", http.StatusBadRequest)
+		slog.Error("RenameTag", "status", http.StatusBadRequest, "error", "tag id not specified")
+		return
+	}
+	name := db.NormalizeTag(tag.Name)
+	if name == "" {
+		http.Error(w, "tag name not specified", http.StatusBadRequest)
+		slog.Error("RenameTag", "status", http.StatusBadRequest, "error", "tag name not specified")
+		return
+	}
+	err = db.RenameTag(tag.Id, name)
+	if errors.Is(err, db.ErrTagExists) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		slog.Error("RenameTag", "status", http.StatusConflict, "error", err)
+		return
+	}
+	if err != nil {
+		http.Error(w, "error renaming tag", http.StatusInternalServerError)
+		slog.Error("Error renaming tag: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	slog.Info("RenameTag Tag renamed", "status", http.StatusOK)
+}
+
+// @Summary MergeTags
+// @Description Fold near-duplicate tags into one. Films keep a single link to the target tag
+// @ID merge-tags
+// @Accept  json
+// @Param merge body structs.TagMerge true "Ids of the tags to merge and of the tag to keep"
+// @Param Authorization header string true "Basic auth for admin"
+// @Success 200 "tags merged"
+// @Failure 400 "tags to merge not specified"
+// @Failure 500 "error merging tags"
+// @Router /merge_tags [post]
+func MergeTags(w http.ResponseWriter, r *http.Request) {
+	if r.Context().Value("admin") != true {
+		http.Error(w, "authorization error", http.StatusUnauthorized)
+		slog.Error("Authorization error: ", "error", "not admin", "status", http.StatusUnauthorized)
+		return
+	}
+	if r.Body == nil {
+		http.Error(w, "no request body", http.StatusBadRequest)
+		slog.Error("No request body: ", "status", http.StatusBadRequest)
+		return
+	}
+	var merge structs.TagMerge
+	err := json.NewDecoder(r.Body).Decode(&merge)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusBadRequest)
+		slog.Error("Error reading request body: ", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+	if len(merge.From) == 0 || merge.Into == 0 {
+		http.Error(w, "tags to merge not specified", http.StatusBadRequest)
+		slog.Error("MergeTags", "status", http.StatusBadRequest, "error", "tags to merge not specified")
+		return
+	}
+	err = db.MergeTags(merge.From, merge.Into)
+	if err != nil {
+		http.Error(w, "error merging tags", http.StatusInternalServerError)
+		slog.Error("Error merging tags: ", "error", err, "status", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	slog.Info("MergeTags Tags merged", "status", http.StatusOK)
+}
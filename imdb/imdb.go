@@ -0,0 +1,313 @@
+// Package imdb loads local copies of the IMDb non-commercial datasets into
+// the catalogue: title.basics becomes films, name.basics actors and the
+// actor rows of title.principals cast links, with ratings from
+// title.ratings. Records keep their IMDb ids, so importing again updates
+// them instead of adding duplicates.
+package imdb
+
+import (
+	"FilmCollection/db"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Files of the dataset. Uncompressed copies without the .gz suffix are
+// read as well.
+const (
+	TitlesFile     = "title.basics.tsv.gz"
+	NamesFile      = "name.basics.tsv.gz"
+	PrincipalsFile = "title.principals.tsv.gz"
+	RatingsFile    = "title.ratings.tsv.gz"
+)
+
+// Stages of an import in the order they run. Each commits its progress with
+// every batch, so an interrupted import resumes after the last batch.
+var Stages = []string{"titles", "principals", "names", "cast"}
+
+// Options selects the titles to import. Zero years and MinVotes do not
+// filter; titles without a year are skipped when a year range is set.
+type Options struct {
+	Dir        string
+	TitleTypes []string
+	MinVotes   int
+	FromYear   int
+	ToYear     int
+	BatchSize  int
+}
+
+// key identifies the filters, progress is only resumed with the same ones.
+func (o Options) key() string {
+	return fmt.Sprintf("types=%s min_votes=%d years=%d-%d", strings.Join(o.TitleTypes, ","), o.MinVotes, o.FromYear, o.ToYear)
+}
+
+// maxName is the length of the name columns of Films and Actors.
+const maxName = 30
+
+// Run imports the dataset, resuming the progress of an earlier run with
+// the same filters. logf reports each finished stage.
+func Run(o Options, logf func(format string, args ...interface{})) error {
+	if o.BatchSize <= 0 {
+		o.BatchSize = 5000
+	}
+	progress, err := db.GetImportProgress("imdb", o.key())
+	if err != nil {
+		return err
+	}
+	for _, stage := range Stages {
+		if progress[stage].Done {
+			logf("%s: done already", stage)
+			continue
+		}
+		var count int
+		switch stage {
+		case "titles":
+			count, err = importTitles(o, progress[stage].Line)
+		case "principals":
+			count, err = stagePrincipals(o, progress[stage].Line)
+		case "names":
+			count, err = importNames(o, progress[stage].Line)
+		default:
+			var links int64
+			links, err = db.LinkImdbCast(o.key())
+			count = int(links)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", stage, err)
+		}
+		logf("%s: %d records", stage, count)
+	}
+	return nil
+}
+
+type rating struct {
+	average float64
+	votes   int
+}
+
+// readRatings loads title.ratings, a few million short lines.
+func readRatings(dir string) (map[string]rating, error) {
+	ratings := make(map[string]rating)
+	err := readTSV(filepath.Join(dir, RatingsFile), 0, func(line int64, fields []string) error {
+		if len(fields) < 3 {
+			return fmt.Errorf("%s line %d: expected 3 fields", RatingsFile, line)
+		}
+		average, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return fmt.Errorf("%s line %d: %w", RatingsFile, line, err)
+		}
+		votes, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return fmt.Errorf("%s line %d: %w", RatingsFile, line, err)
+		}
+		ratings[fields[0]] = rating{average: average, votes: votes}
+		return nil
+	})
+	return ratings, err
+}
+
+func importTitles(o Options, skip int64) (int, error) {
+	ratings, err := readRatings(o.Dir)
+	if err != nil {
+		return 0, err
+	}
+	types := make(map[string]bool)
+	for _, t := range o.TitleTypes {
+		types[t] = true
+	}
+	var batch []db.ImdbTitle
+	count := 0
+	var last int64
+	err = readTSV(filepath.Join(o.Dir, TitlesFile), skip, func(line int64, fields []string) error {
+		last = line
+		// tconst titleType primaryTitle originalTitle isAdult startYear endYear runtimeMinutes genres
+		if len(fields) < 8 {
+			return fmt.Errorf("%s line %d: expected 9 fields", TitlesFile, line)
+		}
+		if len(types) > 0 && !types[fields[1]] {
+			return nil
+		}
+		year := number(fields[5])
+		if (o.FromYear != 0 || o.ToYear != 0) && year == 0 ||
+			o.FromYear != 0 && year < o.FromYear || o.ToYear != 0 && year > o.ToYear {
+			return nil
+		}
+		r := ratings[fields[0]]
+		if r.votes < o.MinVotes {
+			return nil
+		}
+		batch = append(batch, db.ImdbTitle{
+			Tconst:  fields[0],
+			Name:    truncate(fields[2], maxName),
+			Year:    year,
+			Runtime: number(fields[7]),
+			Rating:  int(math.Round(r.average)),
+		})
+		if len(batch) < o.BatchSize {
+			return nil
+		}
+		count += len(batch)
+		err := db.SaveImdbTitles(batch, o.key(), db.ImportProgress{Stage: "titles", Line: line})
+		batch = batch[:0]
+		return err
+	})
+	if err != nil {
+		return count, err
+	}
+	count += len(batch)
+	return count, db.SaveImdbTitles(batch, o.key(), db.ImportProgress{Stage: "titles", Line: last, Done: true})
+}
+
+func stagePrincipals(o Options, skip int64) (int, error) {
+	films, err := db.ImdbFilms()
+	if err != nil {
+		return 0, err
+	}
+	var batch []db.ImdbPrincipal
+	count := 0
+	var last int64
+	err = readTSV(filepath.Join(o.Dir, PrincipalsFile), skip, func(line int64, fields []string) error {
+		last = line
+		// tconst ordering nconst category job characters
+		if len(fields) < 6 {
+			return fmt.Errorf("%s line %d: expected 6 fields", PrincipalsFile, line)
+		}
+		if fields[3] != "actor" && fields[3] != "actress" || !films[fields[0]] {
+			return nil
+		}
+		batch = append(batch, db.ImdbPrincipal{
+			Tconst:    fields[0],
+			Ordering:  number(fields[1]),
+			Nconst:    fields[2],
+			Category:  fields[3],
+			Character: truncate(characters(fields[5]), 100),
+		})
+		if len(batch) < o.BatchSize {
+			return nil
+		}
+		count += len(batch)
+		err := db.StageImdbPrincipals(batch, o.key(), db.ImportProgress{Stage: "principals", Line: line})
+		batch = batch[:0]
+		return err
+	})
+	if err != nil {
+		return count, err
+	}
+	count += len(batch)
+	return count, db.StageImdbPrincipals(batch, o.key(), db.ImportProgress{Stage: "principals", Line: last, Done: true})
+}
+
+func importNames(o Options, skip int64) (int, error) {
+	genders, err := db.StagedImdbNames()
+	if err != nil {
+		return 0, err
+	}
+	var batch []db.ImdbName
+	count := 0
+	var last int64
+	err = readTSV(filepath.Join(o.Dir, NamesFile), skip, func(line int64, fields []string) error {
+		last = line
+		// nconst primaryName birthYear deathYear primaryProfession knownForTitles
+		if len(fields) < 3 {
+			return fmt.Errorf("%s line %d: expected 6 fields", NamesFile, line)
+		}
+		gender, ok := genders[fields[0]]
+		if !ok {
+			return nil
+		}
+		batch = append(batch, db.ImdbName{
+			Nconst:    fields[0],
+			Name:      truncate(fields[1], maxName),
+			Gender:    gender,
+			BirthYear: number(fields[2]),
+		})
+		if len(batch) < o.BatchSize {
+			return nil
+		}
+		count += len(batch)
+		err := db.SaveImdbNames(batch, o.key(), db.ImportProgress{Stage: "names", Line: line})
+		batch = batch[:0]
+		return err
+	})
+	if err != nil {
+		return count, err
+	}
+	count += len(batch)
+	return count, db.SaveImdbNames(batch, o.key(), db.ImportProgress{Stage: "names", Line: last, Done: true})
+}
+
+// readTSV calls fn with the fields of every line after the header, skipping
+// the first skip lines. Lines are numbered from 1 after the header. IMDb
+// files do not quote fields, so encoding/csv cannot read them.
+func readTSV(path string, skip int64, fn func(line int64, fields []string) error) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) && strings.HasSuffix(path, ".gz") {
+		path = strings.TrimSuffix(path, ".gz")
+		file, err = os.Open(path)
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	if !scanner.Scan() {
+		return scanner.Err()
+	}
+	var line int64
+	for scanner.Scan() {
+		line++
+		if line <= skip {
+			continue
+		}
+		err = fn(line, strings.Split(scanner.Text(), "\t"))
+		if err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// number parses a numeric field, 0 for \N.
+func number(field string) int {
+	n, err := strconv.Atoi(field)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// characters joins the JSON array of the characters field of
+// title.principals, e.g. ["Bruce Wayne","Batman"].
+func characters(field string) string {
+	var names []string
+	if json.Unmarshal([]byte(field), &names) != nil {
+		return ""
+	}
+	return strings.Join(names, " / ")
+}
+
+// truncate cuts s to at most n runes.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
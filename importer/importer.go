@@ -0,0 +1,137 @@
+// Package importer reads the CSV and JSON files of the bulk import into
+// rows for db.Import.
+package importer
+
+import (
+	"FilmCollection/structs"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Formats lists the accepted file formats.
+var Formats = []string{"csv", "json"}
+
+// FormatOf guesses the format of a file from its extension, "json" unless
+// it ends in .csv.
+func FormatOf(name string) string {
+	if strings.EqualFold(filepath.Ext(name), ".csv") {
+		return "csv"
+	}
+	return "json"
+}
+
+// Parse reads rows in the given format. Errors in single CSV fields are
+// reported through ImportRow.ParseError; the returned error means the file
+// as a whole could not be read.
+func Parse(r io.Reader, format string) ([]structs.ImportRow, error) {
+	switch format {
+	case "csv":
+		return ParseCSV(r)
+	case "json":
+		return ParseJSON(r)
+	}
+	return nil, errors.New("invalid format format")
+}
+
+// ParseJSON reads an array of rows.
+func ParseJSON(r io.Reader) ([]structs.ImportRow, error) {
+	var rows []structs.ImportRow
+	err := json.NewDecoder(r).Decode(&rows)
+	if err != nil {
+		return nil, errors.New("error reading JSON: " + err.Error())
+	}
+	for i := range rows {
+		rows[i].Line = i + 1
+	}
+	return rows, nil
+}
+
+// ParseCSV reads a CSV file whose header names the columns with the JSON
+// field names of structs.ImportRow, e.g. "type,name,release_date,rating".
+// Columns may come in any order and missing ones are left empty.
+func ParseCSV(r io.Reader) ([]structs.ImportRow, error) {
+	in := csv.NewReader(r)
+	in.FieldsPerRecord = -1
+	header, err := in.Read()
+	if err == io.EOF {
+		return []structs.ImportRow{}, nil
+	}
+	if err != nil {
+		return nil, errors.New("error reading CSV: " + err.Error())
+	}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := csvFields[name]; !ok {
+			return nil, errors.New("unknown CSV column " + strconv.Quote(name))
+		}
+		header[i] = name
+	}
+	rows := []structs.ImportRow{}
+	for {
+		record, err := in.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, errors.New("error reading CSV: " + err.Error())
+		}
+		line, _ := in.FieldPos(0)
+		row := structs.ImportRow{Line: line}
+		if len(record) > len(header) {
+			row.ParseError = "more fields than columns"
+		}
+		for i, value := range record {
+			if i >= len(header) || row.ParseError != "" {
+				break
+			}
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			err = csvFields[header[i]](&row, value)
+			if err != nil {
+				row.ParseError = "invalid " + header[i] + " format"
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// csvFields sets the field of a row named by a CSV column.
+var csvFields = map[string]func(row *structs.ImportRow, value string) error{
+	"type":              func(row *structs.ImportRow, v string) error { row.Type = strings.ToLower(v); return nil },
+	"id":                func(row *structs.ImportRow, v string) error { return parseInt(&row.Id, v) },
+	"name":              func(row *structs.ImportRow, v string) error { row.Name = v; return nil },
+	"description":       func(row *structs.ImportRow, v string) error { row.Description = v; return nil },
+	"release_date":      func(row *structs.ImportRow, v string) error { return parseDate(&row.ReleaseDate, v) },
+	"rating":            func(row *structs.ImportRow, v string) error { return parseInt(&row.Rating, v) },
+	"runtime":           func(row *structs.ImportRow, v string) error { return parseInt(&row.Runtime, v) },
+	"gender":            func(row *structs.ImportRow, v string) error { row.Gender = v; return nil },
+	"birth_date":        func(row *structs.ImportRow, v string) error { return parseDate(&row.BirthDate, v) },
+	"film_id":           func(row *structs.ImportRow, v string) error { return parseInt(&row.FilmID, v) },
+	"film_name":         func(row *structs.ImportRow, v string) error { row.FilmName = v; return nil },
+	"film_release_date": func(row *structs.ImportRow, v string) error { return parseDate(&row.FilmReleaseDate, v) },
+	"actor_id":          func(row *structs.ImportRow, v string) error { return parseInt(&row.ActorID, v) },
+	"actor_name":        func(row *structs.ImportRow, v string) error { row.ActorName = v; return nil },
+	"actor_birth_date":  func(row *structs.ImportRow, v string) error { return parseDate(&row.ActorBirthDate, v) },
+	"character":         func(row *structs.ImportRow, v string) error { row.Character = v; return nil },
+	"billing":           func(row *structs.ImportRow, v string) error { return parseInt(&row.Billing, v) },
+	"credit_type":       func(row *structs.ImportRow, v string) error { row.CreditType = v; return nil },
+}
+
+func parseInt(field *int, value string) error {
+	v, err := strconv.Atoi(value)
+	*field = v
+	return err
+}
+
+func parseDate(field *structs.Date, value string) error {
+	d, err := structs.ParseDate(value)
+	*field = d
+	return err
+}
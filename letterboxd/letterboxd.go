@@ -0,0 +1,212 @@
+// Package letterboxd reads and writes the ratings.csv, diary.csv and
+// watchlist.csv files of Letterboxd exports.
+package letterboxd
+
+import (
+	"FilmCollection/structs"
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"io"
+	"io/fs"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateFormat is the format of the Date and Watched Date columns.
+const dateFormat = "2006-01-02"
+
+// columns are the header lines of the files, as Letterboxd writes them.
+var columns = map[string][]string{
+	"ratings":   {"Date", "Name", "Year", "Letterboxd URI", "Rating"},
+	"diary":     {"Date", "Name", "Year", "Letterboxd URI", "Rating", "Rewatch", "Tags", "Watched Date"},
+	"watchlist": {"Date", "Name", "Year", "Letterboxd URI"},
+}
+
+// ReadExport reads the files of a Letterboxd export zip in the order of
+// structs.LetterboxdFiles. Files missing from the zip are skipped, so are
+// the copies Letterboxd keeps in folders such as deleted/.
+func ReadExport(r io.ReaderAt, size int64) ([]structs.LetterboxdRow, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.New("error reading zip: " + err.Error())
+	}
+	rows := []structs.LetterboxdRow{}
+	found := false
+	for _, file := range structs.LetterboxdFiles {
+		f, err := archive.Open(file + ".csv")
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.New("error reading zip: " + err.Error())
+		}
+		found = true
+		fileRows, err := ReadCSV(f, file)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, fileRows...)
+	}
+	if !found {
+		return nil, errors.New("no ratings.csv, diary.csv or watchlist.csv in zip")
+	}
+	return rows, nil
+}
+
+// ReadCSV reads one file of an export; file is "ratings", "diary" or
+// "watchlist". Columns are found by their header names, unknown ones are
+// ignored. Lines with fields that cannot be read get a ParseError.
+func ReadCSV(r io.Reader, file string) ([]structs.LetterboxdRow, error) {
+	if _, ok := columns[file]; !ok {
+		return nil, errors.New("invalid file format")
+	}
+	in := csv.NewReader(r)
+	in.FieldsPerRecord = -1
+	header, err := in.Read()
+	if err == io.EOF {
+		return []structs.LetterboxdRow{}, nil
+	}
+	if err != nil {
+		return nil, errors.New("error reading " + file + ".csv: " + err.Error())
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.TrimPrefix(strings.TrimSpace(name), "\ufeff")] = i
+	}
+	if _, ok := index["Name"]; !ok {
+		return nil, errors.New("no Name column in " + file + ".csv")
+	}
+	rows := []structs.LetterboxdRow{}
+	for {
+		record, err := in.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, errors.New("error reading " + file + ".csv: " + err.Error())
+		}
+		line, _ := in.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row := structs.LetterboxdRow{File: file, Line: line, Name: field("Name"), URI: field("Letterboxd URI"), Tags: field("Tags")}
+		row.Rewatch = strings.EqualFold(field("Rewatch"), "yes")
+		if err = parseRow(&row, field); err != nil {
+			row.ParseError = err.Error()
+		}
+		rows = append(rows, row)
+	}
+}
+
+// parseRow sets the date, year and rating fields of row.
+func parseRow(row *structs.LetterboxdRow, field func(string) string) error {
+	var err error
+	if s := field("Date"); s != "" {
+		row.Date, err = time.Parse(dateFormat, s)
+		if err != nil {
+			return errors.New("invalid Date format")
+		}
+	}
+	if s := field("Watched Date"); s != "" {
+		row.WatchedDate, err = time.Parse(dateFormat, s)
+		if err != nil {
+			return errors.New("invalid Watched Date format")
+		}
+	}
+	if s := field("Year"); s != "" {
+		row.Year, err = strconv.Atoi(s)
+		if err != nil {
+			return errors.New("invalid Year format")
+		}
+	}
+	if s := field("Rating"); s != "" {
+		stars, err := strconv.ParseFloat(s, 64)
+		if err != nil || stars < 0.5 || stars > 5 {
+			return errors.New("invalid Rating format")
+		}
+		row.Rating = int(math.Round(stars * 2))
+	}
+	if row.Name == "" {
+		return errors.New("no film name")
+	}
+	if row.File == "ratings" && row.Rating == 0 {
+		return errors.New("no rating")
+	}
+	return nil
+}
+
+// WriteCSV writes rows as the file of an export; file is "ratings", "diary"
+// or "watchlist". There are no Letterboxd URIs of our films, rows keep
+// theirs if they have one.
+func WriteCSV(w io.Writer, file string, rows []structs.LetterboxdRow) error {
+	header, ok := columns[file]
+	if !ok {
+		return errors.New("invalid file format")
+	}
+	out := csv.NewWriter(w)
+	err := out.Write(header)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := []string{day(row.Date), row.Name, "", row.URI}
+		if row.Year != 0 {
+			record[2] = strconv.Itoa(row.Year)
+		}
+		if slices.Contains(header, "Rating") {
+			record = append(record, "")
+			if row.Rating != 0 {
+				record[4] = strconv.FormatFloat(float64(row.Rating)/2, 'f', -1, 64)
+			}
+		}
+		if file == "diary" {
+			rewatch := ""
+			if row.Rewatch {
+				rewatch = "Yes"
+			}
+			record = append(record, rewatch, row.Tags, day(row.WatchedDate))
+		}
+		err = out.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// day formats a date of a CSV file, empty when unknown.
+func day(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(dateFormat)
+}
+
+// WriteExport writes a zip with a CSV file for every key of files.
+func WriteExport(w io.Writer, files map[string][]structs.LetterboxdRow) error {
+	archive := zip.NewWriter(w)
+	for _, file := range structs.LetterboxdFiles {
+		rows, ok := files[file]
+		if !ok {
+			continue
+		}
+		f, err := archive.Create(file + ".csv")
+		if err != nil {
+			return err
+		}
+		err = WriteCSV(f, file, rows)
+		if err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
@@ -0,0 +1,164 @@
+// Package library scans media directories for video files and adds their
+// films to the catalogue. Metadata is read from Kodi/Jellyfin .nfo files
+// next to the videos, or from file names like "Title (1999).mkv".
+package library
+
+import (
+	"FilmCollection/db"
+	"FilmCollection/structs"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dirs are the media directories, set with the comma separated MEDIA_DIRS
+// variable.
+var Dirs []string
+
+// Interval is the time between scans of Dirs by the server, set with the
+// MEDIA_SCAN_INTERVAL variable, e.g. "1h". Zero turns periodic scans off.
+var Interval time.Duration
+
+func init() {
+	for _, dir := range strings.Split(os.Getenv("MEDIA_DIRS"), ",") {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			Dirs = append(Dirs, filepath.Clean(dir))
+		}
+	}
+	if s := os.Getenv("MEDIA_SCAN_INTERVAL"); s != "" {
+		var err error
+		Interval, err = time.ParseDuration(s)
+		if err != nil {
+			slog.Error("Invalid media scan interval, periodic scans are off: ", "error", err)
+		}
+	}
+}
+
+// videoExtensions are the file extensions of the files Scan looks at.
+var videoExtensions = map[string]bool{
+	".mkv": true, ".mp4": true, ".m4v": true, ".avi": true, ".mov": true, ".wmv": true,
+	".webm": true, ".mpg": true, ".mpeg": true, ".ts": true, ".m2ts": true, ".iso": true,
+}
+
+// scanning serializes scans, which would otherwise race for the same files.
+var scanning sync.Mutex
+
+// Scan walks dirs and records every video file with its film. Files
+// recorded with the same size and modification time are skipped and
+// recorded files that are gone are forgotten. Problems with single files
+// are reported, not returned.
+func Scan(dirs []string) (structs.ScanReport, error) {
+	scanning.Lock()
+	defer scanning.Unlock()
+	report := structs.ScanReport{Errors: []structs.ScanError{}}
+	fail := func(path string, err error) {
+		report.Failed++
+		report.Errors = append(report.Errors, structs.ScanError{Path: path, Error: err.Error()})
+	}
+	for i, dir := range dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return report, err
+		}
+		dirs[i] = abs
+	}
+	lib, err := db.OpenLibrary()
+	if err != nil {
+		return report, err
+	}
+	defer lib.Close()
+	known, err := lib.Files(dirs)
+	if err != nil {
+		return report, err
+	}
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				fail(path, err)
+				if entry != nil && entry.IsDir() && path != dir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasPrefix(entry.Name(), ".") && path != dir {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if entry.IsDir() || !videoExtensions[strings.ToLower(filepath.Ext(path))] {
+				return nil
+			}
+			report.Files++
+			seen[path] = true
+			info, err := entry.Info()
+			if err != nil {
+				fail(path, err)
+				return nil
+			}
+			modified := info.ModTime().Truncate(time.Microsecond)
+			if file, ok := known[path]; ok && file.Size == info.Size() && file.ModifiedAt.Equal(modified) {
+				report.Unchanged++
+				return nil
+			}
+			film, err := readFilm(path)
+			if err != nil {
+				fail(path, err)
+				return nil
+			}
+			film.Path, film.Size, film.ModifiedAt = path, info.Size(), modified
+			_, created, err := lib.SaveFilm(film)
+			if err != nil {
+				fail(path, err)
+				return nil
+			}
+			if created {
+				report.Created++
+			} else {
+				report.Matched++
+			}
+			return nil
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+	var gone []string
+	for path := range known {
+		if !seen[path] {
+			gone = append(gone, path)
+		}
+	}
+	if len(gone) > 0 {
+		err = lib.RemoveFiles(gone)
+		if err != nil {
+			return report, err
+		}
+		report.Removed = len(gone)
+	}
+	return report, nil
+}
+
+// Start scans Dirs every Interval in the background when both are set.
+func Start() {
+	if len(Dirs) == 0 || Interval <= 0 {
+		return
+	}
+	go func() {
+		for {
+			report, err := Scan(append([]string(nil), Dirs...))
+			if err != nil {
+				slog.Error("Error scanning media library: ", "error", err)
+			} else {
+				slog.Info("Media library scanned", "files", report.Files, "created", report.Created,
+					"matched", report.Matched, "removed", report.Removed, "failed", report.Failed)
+			}
+			time.Sleep(Interval)
+		}
+	}()
+}
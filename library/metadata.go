@@ -0,0 +1,147 @@
+package library
+
+import (
+	"FilmCollection/db"
+	"FilmCollection/structs"
+	"encoding/xml"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Column sizes of Films, Actors and MovieCast.
+const (
+	maxName        = 30
+	maxDescription = 1000
+	maxCharacter   = 100
+)
+
+// nfoMovie is the part of a Kodi/Jellyfin movie NFO file the scanner reads.
+type nfoMovie struct {
+	XMLName   xml.Name `xml:"movie"`
+	Title     string   `xml:"title"`
+	Year      int      `xml:"year"`
+	Premiered string   `xml:"premiered"`
+	Plot      string   `xml:"plot"`
+	Runtime   int      `xml:"runtime"`
+	Rating    float64  `xml:"rating"`
+	Ratings   []struct {
+		Default bool    `xml:"default,attr"`
+		Value   float64 `xml:"value"`
+	} `xml:"ratings>rating"`
+	UniqueIDs []struct {
+		Type  string `xml:"type,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"uniqueid"`
+	ID     string `xml:"id"`
+	Actors []struct {
+		Name  string `xml:"name"`
+		Role  string `xml:"role"`
+		Order *int   `xml:"order"`
+	} `xml:"actor"`
+}
+
+var imdbIDPattern = regexp.MustCompile(`\btt\d{7,9}\b`)
+
+// readFilm reads the metadata of a video file from its NFO file, falling
+// back to its name. An NFO file may also hold just a link to the film on
+// IMDb, as Kodi allows.
+func readFilm(path string) (db.ScannedFilm, error) {
+	film := filmFromName(path)
+	data, err := readNFO(path)
+	if err != nil || data == nil {
+		return film, err
+	}
+	var nfo nfoMovie
+	if xml.Unmarshal(data, &nfo) != nil {
+		film.ImdbID = imdbIDPattern.FindString(string(data))
+		return film, nil
+	}
+	if title := strings.TrimSpace(nfo.Title); title != "" {
+		film.Name = truncate(title, maxName)
+	}
+	if nfo.Year != 0 {
+		film.Year = nfo.Year
+	}
+	if premiered, err := structs.ParseDate(strings.TrimSpace(nfo.Premiered)); err == nil {
+		film.ReleaseDate = premiered
+		film.Year = premiered.Year()
+	}
+	film.Description = truncate(strings.TrimSpace(nfo.Plot), maxDescription)
+	film.Runtime = nfo.Runtime
+	rating := nfo.Rating
+	for i, r := range nfo.Ratings {
+		if r.Default || i == 0 {
+			rating = r.Value
+		}
+	}
+	film.Rating = int(math.Max(0, math.Min(10, math.Round(rating))))
+	film.ImdbID = imdbIDPattern.FindString(nfo.ID)
+	for _, id := range nfo.UniqueIDs {
+		if id.Type == "imdb" {
+			film.ImdbID = imdbIDPattern.FindString(id.Value)
+		}
+	}
+	for i, actor := range nfo.Actors {
+		name := truncate(strings.TrimSpace(actor.Name), maxName)
+		if name == "" {
+			continue
+		}
+		// Kodi orders from 0, billing starts at 1.
+		billing := i + 1
+		if actor.Order != nil {
+			billing = *actor.Order + 1
+		}
+		film.Cast = append(film.Cast, db.ScannedCredit{
+			Name:      name,
+			Character: truncate(strings.TrimSpace(actor.Role), maxCharacter),
+			Billing:   billing,
+		})
+	}
+	return film, nil
+}
+
+// readNFO returns the NFO file of a video, "<name>.nfo" or "movie.nfo" in
+// the same directory, or nil when there is none.
+func readNFO(path string) ([]byte, error) {
+	for _, name := range []string{strings.TrimSuffix(path, filepath.Ext(path)) + ".nfo", filepath.Join(filepath.Dir(path), "movie.nfo")} {
+		data, err := os.ReadFile(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		return data, err
+	}
+	return nil, nil
+}
+
+// namePattern finds the title and the last year in file names such as
+// "Title (1999)", "Title [1999]" or "The.Title.1999.1080p.BluRay".
+var namePattern = regexp.MustCompile(`^(.+)[\s._-]+[(\[]?((?:18|19|20)\d{2})[)\]]?(?:[\s._-].*)?$`)
+
+// filmFromName reads the title and year from the name of a video file.
+func filmFromName(path string) db.ScannedFilm {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	var film db.ScannedFilm
+	if m := namePattern.FindStringSubmatch(name); m != nil {
+		name = m[1]
+		film.Year, _ = strconv.Atoi(m[2])
+	}
+	if !strings.Contains(name, " ") {
+		name = strings.NewReplacer(".", " ", "_", " ").Replace(name)
+	}
+	film.Name = truncate(strings.Trim(name, " .-_([)]"), maxName)
+	return film
+}
+
+// truncate cuts s to at most n runes.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"FilmCollection/handlers"
+	"FilmCollection/library"
 	"log"
 	"log/slog"
 	"net/http"
@@ -29,6 +30,7 @@ func main() {
 	mux := http.NewServeMux()
 
 	handlers.InitHandlers(mux)
+	library.Start()
 
 	hostPort := ":" + os.Getenv("SERVER_PORT")
 	slog.Info("Server started at " + hostPort)
@@ -3,13 +3,24 @@ package main
 import (
 	"FilmCollection/db"
 	"FilmCollection/handlers"
+	"FilmCollection/imdb"
+	"FilmCollection/library"
+	"FilmCollection/moderation"
 	"FilmCollection/structs"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 //use compileboy as usual user and splatjov as admin
@@ -258,3 +269,2092 @@ func TestFilm(t *testing.T) {
 		t.Errorf("GetFilm returned wrong status code: got %v want %v", rr.Code, http.StatusInternalServerError)
 	}
 }
+
+func TestFilmFilters(t *testing.T) {
+	req, err := http.NewRequest("GET", "/get_films?min_rating=abc", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr := httptest.NewRecorder()
+	handlers.Wrap(handlers.GetFilms)(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("GetFilms returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	req, err = http.NewRequest("GET", "/get_films?min_rating=5&max_rating=8&limit=100", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.GetFilms)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("GetFilms returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var films []structs.Film
+	err = json.NewDecoder(rr.Body).Decode(&films)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, film := range films {
+		if film.Rating < 5 || film.Rating > 8 {
+			t.Errorf("GetFilms returned film with rating %d outside of 5..8", film.Rating)
+		}
+	}
+}
+
+func TestFilmQueryLanguage(t *testing.T) {
+	req, err := http.NewRequest("GET", "/get_films?keyword="+url.QueryEscape(`rating>=abc`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr := httptest.NewRecorder()
+	handlers.Wrap(handlers.GetFilms)(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("GetFilms returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	if !strings.Contains(rr.Body.String(), "position 9") {
+		t.Errorf("GetFilms error does not point to the failing position: %s", rr.Body.String())
+	}
+	req, err = http.NewRequest("GET", "/get_films?keyword="+url.QueryEscape(`rating>=8 year:1990..1999 -horror`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.GetFilms)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("GetFilms returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	// Russian words hold bytes that are white space on their own.
+	actorID, err := addTestActor("Никита Запросов")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var filmID int
+	err = db.Conn.QueryRow("INSERT INTO films (name, description, release_date, rating) VALUES ('Утомлённые запросом', 'idk', '1994-01-01', 8) RETURNING id").Scan(&filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AddCredit(filmID, structs.Credit{ActorID: actorID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, keyword := range []string{`запросом`, `actor:"Никита Запросов"`} {
+		req, err = http.NewRequest("GET", "/get_films?keyword="+url.QueryEscape(keyword), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("compileboy", "1234")
+		rr = httptest.NewRecorder()
+		handlers.Wrap(handlers.GetFilms)(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GetFilms returned wrong status code for %s: got %v want %v", keyword, rr.Code, http.StatusOK)
+		}
+		var films []structs.Film
+		err = json.NewDecoder(rr.Body).Decode(&films)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(films) != 1 || films[0].Id != filmID {
+			t.Errorf("GetFilms with keyword %s returned %d films, want film %d", keyword, len(films), filmID)
+		}
+	}
+	_, err = db.Conn.Exec("DELETE FROM moviecast WHERE filmid = $1", filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM films WHERE id = $1", filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM actors WHERE id = $1", actorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMultiKeySort(t *testing.T) {
+	req, err := http.NewRequest("GET", "/get_films?sort=-bogus", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr := httptest.NewRecorder()
+	handlers.Wrap(handlers.GetFilms)(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("GetFilms returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	req, err = http.NewRequest("GET", "/get_films?sort=-rating,cast_size,name&limit=100", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.GetFilms)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("GetFilms returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var films []structs.Film
+	err = json.NewDecoder(rr.Body).Decode(&films)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(films); i++ {
+		if films[i].Rating > films[i-1].Rating {
+			t.Errorf("GetFilms returned films out of rating order")
+		}
+	}
+}
+
+func TestExpandAndFields(t *testing.T) {
+	req, err := http.NewRequest("GET", "/get_films?expand=bogus", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr := httptest.NewRecorder()
+	handlers.Wrap(handlers.GetFilms)(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("GetFilms returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	req, err = http.NewRequest("GET", "/get_films?expand=actors&fields=id,name,actors", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.GetFilms)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("GetFilms returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var films []map[string]json.RawMessage
+	err = json.NewDecoder(rr.Body).Decode(&films)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, film := range films {
+		if _, ok := film["description"]; ok {
+			t.Errorf("GetFilms returned a field that was not requested")
+		}
+		var cast []structs.Actor
+		err = json.Unmarshal(film["actors"], &cast)
+		if err != nil {
+			t.Errorf("GetFilms did not expand actors: %v", err)
+		}
+	}
+}
+
+func TestListAndSingleShape(t *testing.T) {
+	req, err := http.NewRequest("GET", "/get_films?limit=5", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr := httptest.NewRecorder()
+	handlers.Wrap(handlers.GetFilms)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("GetFilms returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var films []structs.Film
+	err = json.NewDecoder(rr.Body).Decode(&films)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, listed := range films {
+		film, err := db.GetFilmByID(listed.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(film.Actors) != len(listed.Actors) {
+			t.Errorf("GetFilms returned %d actors for film %d, GetFilm returns %d", len(listed.Actors), film.Id, len(film.Actors))
+		}
+	}
+}
+
+func TestCastRoles(t *testing.T) {
+	actor, err := addTestActor("RolesTest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodyString := `{
+		"name":"RolesTest",
+		"description": "idk",
+		"rating": 7,
+		"release_date": "01.01.2000",
+		"cast": [{"actor_id": ` + strconv.Itoa(actor) + `, "character": "Danila", "billing": 1, "credit_type": "lead"}]
+	}`
+	req, err := http.NewRequest("GET", "/add_film", strings.NewReader(bodyString))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("splatjov", "1234")
+	rr := httptest.NewRecorder()
+	handlers.Wrap(handlers.AddFilm)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("AddFilm returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	loaded, err := db.GetActorByID(actor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Roles) != 1 || loaded.Roles[0].Character != "Danila" || loaded.Roles[0].CreditType != "lead" {
+		t.Errorf("AddFilm failed to store the role: %+v", loaded.Roles)
+	}
+	// A plain actors list keeps the roles of the actors that stay.
+	other, err := addTestActor("RolesTestOther")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var filmID int
+	err = db.Conn.QueryRow("SELECT id FROM films WHERE name = 'RolesTest'").Scan(&filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodyString = `{"id": ` + strconv.Itoa(filmID) + `, "actors": [` + strconv.Itoa(actor) + `, ` + strconv.Itoa(other) + `]}`
+	req, err = http.NewRequest("POST", "/update_film", strings.NewReader(bodyString))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("splatjov", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.UpdateFilm)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("UpdateFilm returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	credits, err := db.CastByFilm([]int{filmID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cast := credits[filmID]; len(cast) != 2 || cast[0].ActorID != actor || cast[0].Character != "Danila" || cast[0].Billing != 1 {
+		t.Errorf("UpdateFilm with actors lost the stored role: %+v", cast)
+	}
+	bodyString = `{"name":"RolesTest","cast": [{"actor_id": 1, "credit_type": "extra"}]}`
+	req, err = http.NewRequest("GET", "/add_film", strings.NewReader(bodyString))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("splatjov", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.AddFilm)(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("AddFilm returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	_, err = db.Conn.Exec("DELETE FROM moviecast WHERE actorid = $1", actor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM moviecast WHERE actorid = $1", other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM films WHERE name = 'RolesTest'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM actors WHERE id = ANY($1)", []int{actor, other})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// addTestActor inserts a throwaway actor and returns its id.
+func addTestActor(name string) (int, error) {
+	var id int
+	err := db.Conn.QueryRow("INSERT INTO actors (name, gender, birth_date) VALUES ($1, 'idk', '2000-01-01') RETURNING id", name).Scan(&id)
+	return id, err
+}
+
+func TestCrew(t *testing.T) {
+	director, err := addTestActor("CrewTest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodyString := `{
+		"name":"CrewTest",
+		"description": "idk",
+		"rating": 7,
+		"release_date": "01.01.2000",
+		"crew": [{"person_id": ` + strconv.Itoa(director) + `, "department": "directing"}]
+	}`
+	req, err := http.NewRequest("GET", "/add_film", strings.NewReader(bodyString))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("splatjov", "1234")
+	rr := httptest.NewRecorder()
+	handlers.Wrap(handlers.AddFilm)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("AddFilm returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	req, err = http.NewRequest("GET", "/get_person_films?id="+strconv.Itoa(director), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.GetPersonFilms)(rr, req)
+	var films []structs.Film
+	err = json.NewDecoder(rr.Body).Decode(&films)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(films) != 1 || films[0].Name != "CrewTest" {
+		t.Errorf("GetPersonFilms failed to return directed films: %+v", films)
+	}
+	page, err := db.GetPersonPage(director)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Credits["directing"]) != 1 {
+		t.Errorf("GetPersonPage failed to group credits by department: %+v", page.Credits)
+	}
+	_, err = db.Conn.Exec("DELETE FROM crew WHERE personid = $1", director)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM films WHERE name = 'CrewTest'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM actors WHERE id = $1", director)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenres(t *testing.T) {
+	parent, err := db.AddGenre(structs.Genre{Name: "GenreTest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := db.AddGenre(structs.Genre{Name: "GenreTest child", ParentID: parent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodyString := `{
+		"name":"GenreTest",
+		"description": "idk",
+		"rating": 7,
+		"release_date": "01.01.2000",
+		"genres": [{"id": ` + strconv.Itoa(child) + `}]
+	}`
+	req, err := http.NewRequest("GET", "/add_film", strings.NewReader(bodyString))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("splatjov", "1234")
+	rr := httptest.NewRecorder()
+	handlers.Wrap(handlers.AddFilm)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("AddFilm returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	req, err = http.NewRequest("GET", "/get_films?genres="+strconv.Itoa(parent), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.GetFilms)(rr, req)
+	var films []structs.Film
+	err = json.NewDecoder(rr.Body).Decode(&films)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(films) != 1 || len(films[0].Genres) != 1 || films[0].Genres[0].Id != child {
+		t.Errorf("GetFilms failed to filter by parent genre: %+v", films)
+	}
+	req, err = http.NewRequest("GET", "/delete_genre?id="+strconv.Itoa(child), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("splatjov", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.DeleteGenre)(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("DeleteGenre returned wrong status code: got %v want %v", rr.Code, http.StatusConflict)
+	}
+	_, err = db.Conn.Exec("DELETE FROM films WHERE name = 'GenreTest'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int{child, parent} {
+		err = db.DeleteGenre(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	bodyString := `{
+		"name":"TagTest",
+		"description": "idk",
+		"rating": 7,
+		"release_date": "01.01.2000",
+		"tags": ["  Heist ", "HEIST", "based on a true story"]
+	}`
+	req, err := http.NewRequest("GET", "/add_film", strings.NewReader(bodyString))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("splatjov", "1234")
+	rr := httptest.NewRecorder()
+	handlers.Wrap(handlers.AddFilm)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("AddFilm returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	req, err = http.NewRequest("GET", "/get_films?keyword=TagTest&tags=heist", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.GetFilms)(rr, req)
+	var films []structs.Film
+	err = json.NewDecoder(rr.Body).Decode(&films)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(films) != 1 || len(films[0].Tags) != 2 {
+		t.Errorf("GetFilms failed to filter by normalized tags: %+v", films)
+	}
+	req, err = http.NewRequest("GET", "/get_films?keyword=TagTest&exclude_tags=heist", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.GetFilms)(rr, req)
+	films = nil
+	err = json.NewDecoder(rr.Body).Decode(&films)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(films) != 0 {
+		t.Errorf("GetFilms failed to exclude tags: %+v", films)
+	}
+	tags, err := db.SearchTags("hei", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) == 0 || tags[0].Name != "heist" {
+		t.Errorf("SearchTags failed to autocomplete: %+v", tags)
+	}
+	_, err = db.Conn.Exec("DELETE FROM films WHERE name = 'TagTest'")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUserRatingsAndReviews(t *testing.T) {
+	var filmID int
+	err := db.Conn.QueryRow("INSERT INTO films (name, description, release_date, rating) VALUES ('RatingTest', 'idk', '2000-01-01', 5) RETURNING id").Scan(&filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stringId := strconv.Itoa(filmID)
+	req, err := http.NewRequest("GET", "/rate_film", strings.NewReader(`{"film_id": `+stringId+`, "rating": 11}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr := httptest.NewRecorder()
+	handlers.Wrap(handlers.RateFilm)(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("RateFilm returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	// Unknown films and overlong reviews are bad requests, not database errors.
+	for _, c := range []struct {
+		handler http.HandlerFunc
+		body    string
+	}{
+		{handlers.RateFilm, `{"film_id": -1, "rating": 5}`},
+		{handlers.ReviewFilm, `{"film_id": -1, "body": "Fine"}`},
+		{handlers.ReviewFilm, `{"film_id": ` + stringId + `, "body": "` + strings.Repeat("a", 10001) + `"}`},
+	} {
+		req, err = http.NewRequest("POST", "/", strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("compileboy", "1234")
+		rr = httptest.NewRecorder()
+		handlers.Wrap(c.handler)(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%.40s returned wrong status code: got %v want %v", c.body, rr.Code, http.StatusBadRequest)
+		}
+	}
+	for _, rating := range []string{"6", "8"} {
+		req, err = http.NewRequest("GET", "/rate_film", strings.NewReader(`{"film_id": `+stringId+`, "rating": `+rating+`}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("compileboy", "1234")
+		rr = httptest.NewRecorder()
+		handlers.Wrap(handlers.RateFilm)(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("RateFilm returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+	}
+	film, err := db.GetFilmByID(filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if film.Votes != 1 || film.CommunityRating != 8 || film.Rating != 5 {
+		t.Errorf("RateFilm did not replace the previous rating: %+v", film)
+	}
+	req, err = http.NewRequest("GET", "/review_film", strings.NewReader(`{"film_id": `+stringId+`, "body": "Great"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.ReviewFilm)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("ReviewFilm returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var review structs.Review
+	err = json.NewDecoder(rr.Body).Decode(&review)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if review.Login != "compileboy" || review.Rating != 8 {
+		t.Errorf("ReviewFilm returned wrong review: %+v", review)
+	}
+	_, err = db.Conn.Exec("DELETE FROM films WHERE id = $1", filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReviewModeration(t *testing.T) {
+	policy := moderation.Current
+	defer func() { moderation.Current = policy }()
+	moderation.Current = moderation.Policy{
+		Mode:    moderation.HoldFlagged,
+		Filters: []moderation.Filter{moderation.NewBannedWords([]string{"spoiler"})},
+	}
+	var filmID int
+	err := db.Conn.QueryRow("INSERT INTO films (name, description, release_date, rating) VALUES ('ModerationTest', 'idk', '2000-01-01', 5) RETURNING id").Scan(&filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stringId := strconv.Itoa(filmID)
+	reviewIDs := map[string]int{}
+	for login, body := range map[string]string{"compileboy": "Great", "splatjov": "Huge SPOILER inside"} {
+		req, err := http.NewRequest("POST", "/review_film", strings.NewReader(`{"film_id": `+stringId+`, "body": "`+body+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth(login, "1234")
+		rr := httptest.NewRecorder()
+		handlers.Wrap(handlers.ReviewFilm)(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("ReviewFilm returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		var review structs.Review
+		err = json.NewDecoder(rr.Body).Decode(&review)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reviewIDs[login] = review.Id
+	}
+	reviews, err := db.GetFilmReviews(filmID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reviews) != 1 || reviews[0].Login != "compileboy" {
+		t.Errorf("GetFilmReviews returned a held review: %+v", reviews)
+	}
+	held, err := db.GetReviewByID(reviewIDs["splatjov"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if held.Status != moderation.Pending || held.ModerationNote == "" {
+		t.Errorf("ReviewFilm did not hold the flagged review: %+v", held)
+	}
+	mine, err := db.GetUserReviews(held.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, review := range mine {
+		found = found || review.Id == held.Id && review.Status == moderation.Pending
+	}
+	if !found {
+		t.Errorf("GetUserReviews did not return the held review: %+v", mine)
+	}
+
+	req, err := http.NewRequest("POST", "/report_review", strings.NewReader(`{"review_id": `+strconv.Itoa(reviewIDs["compileboy"])+`, "reason": "rude"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("splatjov", "1234")
+	rr := httptest.NewRecorder()
+	handlers.Wrap(handlers.ReportReview)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("ReportReview returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	req, err = http.NewRequest("POST", "/moderate_review", strings.NewReader(`{"review_id": `+strconv.Itoa(reviewIDs["splatjov"])+`, "action": "approve"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.ModerateReview)(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("ModerateReview returned wrong status code: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+	for login, action := range map[string]string{"splatjov": "approve", "compileboy": "hide"} {
+		req, err = http.NewRequest("POST", "/moderate_review", strings.NewReader(`{"review_id": `+strconv.Itoa(reviewIDs[login])+`, "action": "`+action+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("splatjov", "1234")
+		rr = httptest.NewRecorder()
+		handlers.Wrap(handlers.ModerateReview)(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("ModerateReview returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+	}
+	reviews, err = db.GetFilmReviews(filmID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reviews) != 1 || reviews[0].Login != "splatjov" {
+		t.Errorf("GetFilmReviews returned wrong reviews after moderation: %+v", reviews)
+	}
+	reports, err := db.GetReviewReports(false, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, report := range reports {
+		if report.ReviewID == reviewIDs["compileboy"] {
+			t.Errorf("ModerateReview did not resolve the report: %+v", report)
+		}
+	}
+	req, err = http.NewRequest("POST", "/moderate_review", strings.NewReader(`{"review_id": `+strconv.Itoa(reviewIDs["splatjov"])+`, "action": "reject", "note": "no spoilers"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("splatjov", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.ModerateReview)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("ModerateReview returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	for _, login := range []string{"compileboy", "splatjov"} {
+		req, err = http.NewRequest("POST", "/review_film", strings.NewReader(`{"film_id": `+stringId+`, "body": "Edited"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth(login, "1234")
+		rr = httptest.NewRecorder()
+		handlers.Wrap(handlers.ReviewFilm)(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("ReviewFilm returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+	}
+	reviews, err = db.GetFilmReviews(filmID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reviews) != 0 {
+		t.Errorf("GetFilmReviews returned edited rejected or hidden reviews: %+v", reviews)
+	}
+	rejected, err := db.GetReviewByID(reviewIDs["splatjov"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rejected.Status != moderation.Pending || rejected.ModerationNote != "no spoilers" {
+		t.Errorf("ReviewFilm did not hold the edited rejected review: %+v", rejected)
+	}
+	_, err = db.Conn.Exec("DELETE FROM films WHERE id = $1", filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLists(t *testing.T) {
+	var filmIDs []int
+	for _, name := range []string{"ListTest1", "ListTest2"} {
+		var id int
+		err := db.Conn.QueryRow("INSERT INTO films (name, description, release_date, rating) VALUES ($1, 'idk', '1999-01-01', 5) RETURNING id", name).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filmIDs = append(filmIDs, id)
+	}
+	req, err := http.NewRequest("POST", "/add_list", strings.NewReader(`{"name": "Best of 1999"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr := httptest.NewRecorder()
+	handlers.Wrap(handlers.AddList)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("AddList returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var list structs.List
+	err = json.NewDecoder(rr.Body).Decode(&list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Public || list.Watchlist {
+		t.Errorf("AddList returned wrong list: %+v", list)
+	}
+	listID := strconv.Itoa(list.Id)
+	// Overlong fields and unknown films are bad requests, not database errors.
+	for _, c := range []struct {
+		handler http.HandlerFunc
+		body    string
+	}{
+		{handlers.AddList, `{"name": "` + strings.Repeat("a", 151) + `"}`},
+		{handlers.UpdateList, `{"id": ` + listID + `, "description": "` + strings.Repeat("a", 1001) + `"}`},
+		{handlers.AddListItem, `{"list_id": ` + listID + `, "film_id": -1}`},
+	} {
+		req, err = http.NewRequest("POST", "/", strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("compileboy", "1234")
+		rr = httptest.NewRecorder()
+		handlers.Wrap(c.handler)(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%.40s returned wrong status code: got %v want %v", c.body, rr.Code, http.StatusBadRequest)
+		}
+	}
+	for _, id := range filmIDs {
+		req, err = http.NewRequest("POST", "/add_list_item", strings.NewReader(`{"list_id": `+listID+`, "film_id": `+strconv.Itoa(id)+`, "note": "must see"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("compileboy", "1234")
+		rr = httptest.NewRecorder()
+		handlers.Wrap(handlers.AddListItem)(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("AddListItem returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+	}
+	req, err = http.NewRequest("POST", "/reorder_list", strings.NewReader(`{"list_id": `+listID+`, "film_ids": [`+strconv.Itoa(filmIDs[1])+`]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.ReorderList)(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("ReorderList returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	req, err = http.NewRequest("POST", "/reorder_list", strings.NewReader(`{"list_id": `+listID+`, "film_ids": [`+strconv.Itoa(filmIDs[1])+`, `+strconv.Itoa(filmIDs[0])+`]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.ReorderList)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("ReorderList returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	req, err = http.NewRequest("GET", "/get_list?id="+listID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("splatjov", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.GetList)(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("GetList showed a private list: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+	req, err = http.NewRequest("POST", "/share_list?id="+listID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.ShareList)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ShareList returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	err = json.NewDecoder(rr.Body).Decode(&list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err = http.NewRequest("GET", "/get_list?token="+list.ShareToken, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("splatjov", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.GetList)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GetList returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var shared structs.List
+	err = json.NewDecoder(rr.Body).Decode(&shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shared.ShareToken != "" || len(shared.Items) != 2 || shared.Items[0].FilmID != filmIDs[1] || shared.Items[0].Note != "must see" {
+		t.Errorf("GetList returned wrong shared list: %+v", shared)
+	}
+
+	watchlist, err := db.GetWatchlist(list.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AddListItem(list.UserID, structs.ListItem{ListID: watchlist.Id, FilmID: filmIDs[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err = http.NewRequest("GET", "/get_film?id="+strconv.Itoa(filmIDs[0]), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.GetFilm)(rr, req)
+	var film structs.Film
+	err = json.NewDecoder(rr.Body).Decode(&film)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !film.OnWatchlist {
+		t.Errorf("GetFilm did not mark the film as watchlisted: %+v", film)
+	}
+	err = db.DeleteList(list.Id, list.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM films WHERE id = ANY($1)", filmIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiary(t *testing.T) {
+	var filmID int
+	err := db.Conn.QueryRow("INSERT INTO films (name, description, release_date, rating) VALUES ('DiaryTest', 'idk', '2000-01-01', 5) RETURNING id").Scan(&filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stringId := strconv.Itoa(filmID)
+	for _, body := range []string{
+		`{"film_id": ` + stringId + `, "watched_on": "2020-03-01", "rating": 7, "notes": "first time"}`,
+		`{"film_id": ` + stringId + `, "watched_on": "2021-06-15", "rewatch": true}`,
+	} {
+		req, err := http.NewRequest("POST", "/log_watch", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("compileboy", "1234")
+		rr := httptest.NewRecorder()
+		handlers.Wrap(handlers.LogWatch)(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("LogWatch returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+	}
+	req, err := http.NewRequest("POST", "/log_watch", strings.NewReader(`{"film_id": `+stringId+`, "rating": 11}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr := httptest.NewRecorder()
+	handlers.Wrap(handlers.LogWatch)(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("LogWatch returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	req, err = http.NewRequest("GET", "/get_diary?film_id="+stringId+"&from=2021-01-01&to=2021-12-31", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.GetDiary)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GetDiary returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var entries []structs.DiaryEntry
+	err = json.NewDecoder(rr.Body).Decode(&entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || !entries[0].Rewatch || entries[0].Rating != 0 {
+		t.Errorf("GetDiary returned wrong entries: %+v", entries)
+	}
+	film, err := db.GetFilmByID(filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if film.Rating != 5 {
+		t.Errorf("LogWatch changed the film's rating: %+v", film)
+	}
+
+	req, err = http.NewRequest("POST", "/delete_diary_entry?id="+strconv.Itoa(entries[0].Id), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("splatjov", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.DeleteDiaryEntry)(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("DeleteDiaryEntry deleted another user's entry: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+	_, err = db.Conn.Exec("DELETE FROM films WHERE id = $1", filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestYearReview(t *testing.T) {
+	var filmID int
+	err := db.Conn.QueryRow("INSERT INTO films (name, description, release_date, rating, runtime) VALUES ('RecapTest', 'idk', '2000-01-01', 5, 100) RETURNING id").Scan(&filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actorID, err := addTestActor("RecapActor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AddCredit(filmID, structs.Credit{ActorID: actorID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var user int
+	err = db.Conn.QueryRow("SELECT id FROM users WHERE login = 'compileboy'").Scan(&user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, day := range []string{"1901-02-01", "1901-02-20", "1901-07-04"} {
+		watchedOn, err := structs.ParseDate(day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = db.AddDiaryEntry(structs.DiaryEntry{UserID: user, FilmID: filmID, WatchedOn: watchedOn, Rating: 8})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest("GET", "/get_year_review?year=1901", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr := httptest.NewRecorder()
+	handlers.Wrap(handlers.GetYearReview)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GetYearReview returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var review structs.YearReview
+	err = json.NewDecoder(rr.Body).Decode(&review)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if review.FilmsWatched != 3 || review.UniqueFilms != 1 || review.FirstWatches != 1 || review.Rewatches != 2 ||
+		review.TotalRuntime != 300 || review.BusiestMonth != 2 || review.RatingDistribution[7].Count != 3 ||
+		len(review.TopActors) != 1 || review.TopActors[0].ActorID != actorID {
+		t.Errorf("GetYearReview returned wrong review: %+v", review)
+	}
+	req, err = http.NewRequest("GET", "/get_year_review?year=1901&format=csv", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr = httptest.NewRecorder()
+	handlers.Wrap(handlers.GetYearReview)(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "summary,films_watched,3") {
+		t.Errorf("GetYearReview returned wrong CSV: %v %s", rr.Code, rr.Body.String())
+	}
+	_, err = db.Conn.Exec("DELETE FROM moviecast WHERE actorid = $1", actorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM films WHERE id = $1", filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM actors WHERE id = $1", actorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSimilarFilms(t *testing.T) {
+	var filmIDs []int
+	for _, name := range []string{"SimilarTest1", "SimilarTest2", "SimilarTest3"} {
+		var id int
+		err := db.Conn.QueryRow("INSERT INTO films (name, description, release_date, rating) VALUES ($1, 'idk', '1999-01-01', 7) RETURNING id", name).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filmIDs = append(filmIDs, id)
+	}
+	actorID, err := addTestActor("SimilarActor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range filmIDs[:2] {
+		err = db.AddCredit(id, structs.Credit{ActorID: actorID})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest("GET", "/films/"+strconv.Itoa(filmIDs[0])+"/similar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", strconv.Itoa(filmIDs[0]))
+	req.SetBasicAuth("compileboy", "1234")
+	rr := httptest.NewRecorder()
+	handlers.Wrap(handlers.GetSimilarFilms)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GetSimilarFilms returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var similar []structs.SimilarFilm
+	err = json.NewDecoder(rr.Body).Decode(&similar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, s := range similar {
+		if s.Film.Id == filmIDs[2] {
+			t.Errorf("GetSimilarFilms returned a film without shared actors, genres or tags: %+v", s)
+		}
+		if s.Film.Id == filmIDs[1] {
+			found = true
+			if len(s.Reasons) == 0 || s.Reasons[0] != "1 shared actor: SimilarActor" {
+				t.Errorf("GetSimilarFilms returned wrong reasons: %+v", s.Reasons)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("GetSimilarFilms did not return the film with a shared actor: %+v", similar)
+	}
+	_, err = db.Conn.Exec("DELETE FROM moviecast WHERE actorid = $1", actorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM films WHERE id = ANY($1)", filmIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM actors WHERE id = $1", actorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecommendations(t *testing.T) {
+	var filmIDs []int
+	for _, name := range []string{"RecommendTest1", "RecommendTest2"} {
+		var id int
+		err := db.Conn.QueryRow("INSERT INTO films (name, description, release_date, rating, runtime) VALUES ($1, 'idk', '1999-01-01', 7, 90) RETURNING id", name).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filmIDs = append(filmIDs, id)
+	}
+	actorID, err := addTestActor("RecommendActor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range filmIDs {
+		err = db.AddCredit(id, structs.Credit{ActorID: actorID})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var user int
+	err = db.Conn.QueryRow("SELECT id FROM users WHERE login = 'compileboy'").Scan(&user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.RateFilm(structs.UserRating{UserID: user, FilmID: filmIDs[0], Rating: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recommended := func(query string) *structs.Recommendation {
+		req, err := http.NewRequest("GET", "/me/recommendations?limit=1000"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("compileboy", "1234")
+		rr := httptest.NewRecorder()
+		handlers.Wrap(handlers.GetRecommendations)(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GetRecommendations returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		var recommendations []structs.Recommendation
+		err = json.NewDecoder(rr.Body).Decode(&recommendations)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, recommendation := range recommendations {
+			if recommendation.Film.Id == filmIDs[0] {
+				t.Errorf("GetRecommendations returned a rated film: %+v", recommendation)
+			}
+			if recommendation.Film.Id == filmIDs[1] {
+				return &recommendations[i]
+			}
+		}
+		return nil
+	}
+	recommendation := recommended("")
+	if recommendation == nil || len(recommendation.Reasons) == 0 || recommendation.Reasons[0] != "you liked films with RecommendActor" {
+		t.Errorf("GetRecommendations did not recommend the film with a liked actor: %+v", recommendation)
+	}
+	if recommended("&max_runtime=60") != nil {
+		t.Error("GetRecommendations ignored max_runtime")
+	}
+	entryID, err := db.AddDiaryEntry(structs.DiaryEntry{UserID: user, FilmID: filmIDs[1], WatchedOn: structs.Date{Time: time.Now()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recommended("") != nil {
+		t.Error("GetRecommendations returned a watched film")
+	}
+
+	err = db.DeleteDiaryEntry(entryID, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.DeleteRating(user, filmIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Ratings stored without updating the profile are picked up by a rebuild.
+	_, err = db.Conn.Exec("INSERT INTO userratings (userid, filmid, rating) VALUES ($1, $2, 10)", user, filmIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recommended("") != nil {
+		t.Error("GetRecommendations used a rating missing from the profile")
+	}
+	_, err = db.RebuildTasteProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recommended("") == nil {
+		t.Error("RebuildTasteProfiles did not add the stored rating to the profile")
+	}
+	err = db.DeleteRating(user, filmIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM moviecast WHERE actorid = $1", actorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM films WHERE id = ANY($1)", filmIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM actors WHERE id = $1", actorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestActorGraph(t *testing.T) {
+	var actorIDs, filmIDs []int
+	for _, name := range []string{"GraphActorA", "GraphActorB", "GraphActorC", "GraphActorD"} {
+		id, err := addTestActor(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actorIDs = append(actorIDs, id)
+	}
+	// A and B play in the first film, B and C in the second; D is alone.
+	for i, name := range []string{"GraphFilm1", "GraphFilm2"} {
+		var id int
+		err := db.Conn.QueryRow("INSERT INTO films (name, description, release_date, rating) VALUES ($1, 'idk', '1999-01-01', 7) RETURNING id", name).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filmIDs = append(filmIDs, id)
+		for _, actor := range actorIDs[i : i+2] {
+			err = db.AddCredit(id, structs.Credit{ActorID: actor})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	get := func(handler http.HandlerFunc, values map[string]string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/actors", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, value := range values {
+			req.SetPathValue(name, value)
+		}
+		req.SetBasicAuth("compileboy", "1234")
+		rr := httptest.NewRecorder()
+		handlers.Wrap(handler)(rr, req)
+		return rr
+	}
+	rr := get(handlers.GetActorPath, map[string]string{"a": strconv.Itoa(actorIDs[0]), "b": strconv.Itoa(actorIDs[2])})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GetActorPath returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var path structs.ActorPath
+	err := json.NewDecoder(rr.Body).Decode(&path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path.Degrees != 2 || path.Links[0].From.Id != actorIDs[0] || path.Links[0].To.Id != actorIDs[1] ||
+		path.Links[1].Film.Id != filmIDs[1] || path.Links[1].To.Name != "GraphActorC" {
+		t.Errorf("GetActorPath returned wrong path: %+v", path)
+	}
+	rr = get(handlers.GetActorPath, map[string]string{"a": strconv.Itoa(actorIDs[0]), "b": strconv.Itoa(actorIDs[3])})
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("GetActorPath returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+	rr = get(handlers.GetCostars, map[string]string{"id": strconv.Itoa(actorIDs[1])})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GetCostars returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var costars []structs.Costar
+	err = json.NewDecoder(rr.Body).Decode(&costars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(costars) != 2 || costars[0].SharedFilms != 1 {
+		t.Errorf("GetCostars returned wrong co-stars: %+v", costars)
+	}
+	_, err = db.Conn.Exec("DELETE FROM moviecast WHERE filmid = ANY($1)", filmIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM films WHERE id = ANY($1)", filmIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM actors WHERE id = ANY($1)", actorIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGraphExport(t *testing.T) {
+	var actorIDs, filmIDs []int
+	for _, name := range []string{"ExportActorA", "ExportActorB", "ExportActorC"} {
+		id, err := addTestActor(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actorIDs = append(actorIDs, id)
+	}
+	// A and B play in the first film, B and C in the second.
+	for i, name := range []string{"ExportFilm1", "ExportFilm2"} {
+		var id int
+		err := db.Conn.QueryRow("INSERT INTO films (name, description, release_date, rating) VALUES ($1, 'idk', '1999-01-01', 7) RETURNING id", name).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filmIDs = append(filmIDs, id)
+		for _, actor := range actorIDs[i : i+2] {
+			err = db.AddCredit(id, structs.Credit{ActorID: actor, Character: "Role \"" + name + "\""})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	get := func(query string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/export_graph?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("compileboy", "1234")
+		rr := httptest.NewRecorder()
+		handlers.Wrap(handlers.ExportGraph)(rr, req)
+		return rr
+	}
+	// One link around A reaches only the first film, two links reach B too.
+	rr := get("format=json&depth=2&actor=" + strconv.Itoa(actorIDs[0]))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ExportGraph returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var jgf struct {
+		Graph struct {
+			Nodes map[string]struct {
+				Label    string                 `json:"label"`
+				Metadata map[string]interface{} `json:"metadata"`
+			} `json:"nodes"`
+			Edges []struct {
+				Source string `json:"source"`
+				Target string `json:"target"`
+			} `json:"edges"`
+		} `json:"graph"`
+	}
+	err := json.NewDecoder(rr.Body).Decode(&jgf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	film := jgf.Graph.Nodes["f"+strconv.Itoa(filmIDs[0])]
+	if len(jgf.Graph.Nodes) != 3 || len(jgf.Graph.Edges) != 2 || film.Label != "ExportFilm1" || film.Metadata["release_year"] != float64(1999) {
+		t.Errorf("ExportGraph returned wrong graph: %+v", jgf.Graph)
+	}
+	rr = get("format=graphml&depth=3&film=" + strconv.Itoa(filmIDs[1]))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ExportGraph returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if body := rr.Body.String(); !strings.Contains(body, `<node id="a`+strconv.Itoa(actorIDs[0])+`">`) ||
+		!strings.Contains(body, "Role &#34;ExportFilm2&#34;") {
+		t.Errorf("ExportGraph returned wrong GraphML: %s", body)
+	}
+	rr = get("format=dot&depth=0&actor=" + strconv.Itoa(actorIDs[2]))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ExportGraph returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if body := rr.Body.String(); !strings.HasPrefix(body, "graph films {") || strings.Contains(body, " -- ") {
+		t.Errorf("ExportGraph returned wrong DOT: %s", body)
+	}
+	rr = get("actor=" + strconv.Itoa(actorIDs[0]) + "&film=" + strconv.Itoa(filmIDs[0]))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("ExportGraph returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	_, err = db.Conn.Exec("DELETE FROM moviecast WHERE filmid = ANY($1)", filmIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM films WHERE id = ANY($1)", filmIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM actors WHERE id = ANY($1)", actorIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCatalogueStats(t *testing.T) {
+	var actorIDs, filmIDs []int
+	for _, name := range []string{"StatsActorA", "StatsActorB"} {
+		id, err := addTestActor(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actorIDs = append(actorIDs, id)
+	}
+	// Both actors play in the first film, only A in the second; the third
+	// film has no cast. All are released in years no other test uses.
+	for i, date := range []string{"1901-03-01", "1905-06-01", "1912-01-01"} {
+		var id int
+		err := db.Conn.QueryRow("INSERT INTO films (name, description, release_date, rating) VALUES ('StatsFilm', 'idk', $1, $2) RETURNING id",
+			date, 4+i*2).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filmIDs = append(filmIDs, id)
+	}
+	for _, credit := range [][2]int{{0, 0}, {0, 1}, {1, 0}} {
+		err := db.AddCredit(filmIDs[credit[0]], structs.Credit{ActorID: actorIDs[credit[1]]})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest("GET", "/stats?released_from=1900-01-01&released_to=1919-12-31", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr := httptest.NewRecorder()
+	handlers.Wrap(handlers.GetStats)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GetStats returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var stats structs.CatalogueStats
+	err = json.NewDecoder(rr.Body).Decode(&stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Films != 3 || stats.Actors != 2 || stats.AverageRating != 6 || stats.RatingHistogram[8].Count != 1 {
+		t.Errorf("GetStats returned wrong counts: %+v", stats)
+	}
+	if len(stats.FilmsPerYear) != 3 || len(stats.FilmsPerDecade) != 2 ||
+		stats.FilmsPerDecade[0] != (structs.YearCount{Year: 1900, Count: 2}) {
+		t.Errorf("GetStats returned wrong years: %+v %+v", stats.FilmsPerYear, stats.FilmsPerDecade)
+	}
+	if len(stats.CastSizes) != 3 || stats.CastSizes[0] != (structs.CastSizeCount{CastSize: 0, Films: 1}) {
+		t.Errorf("GetStats returned wrong cast sizes: %+v", stats.CastSizes)
+	}
+	if len(stats.Genders) != 1 || stats.Genders[0].Count != 2 {
+		t.Errorf("GetStats returned wrong genders: %+v", stats.Genders)
+	}
+	if len(stats.TopActors) != 2 || stats.TopActors[0].ActorID != actorIDs[0] || stats.TopActors[0].Count != 2 {
+		t.Errorf("GetStats returned wrong top actors: %+v", stats.TopActors)
+	}
+	// Films without a release date are stored with 0001-01-01.
+	var undated int
+	err = db.Conn.QueryRow("INSERT INTO films (name, description, release_date) VALUES ('StatsFilm', 'idk', '0001-01-01') RETURNING id").Scan(&undated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filmIDs = append(filmIDs, undated)
+	stats, err = db.GetCatalogueStats(db.StatsFilter{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.UnknownReleaseDate == 0 || stats.FilmsPerYear[0].Year == 1 || stats.FilmsPerDecade[0].Year == 0 {
+		t.Errorf("GetStats counted a film without release date in a year: %d %+v %+v", stats.UnknownReleaseDate, stats.FilmsPerYear, stats.FilmsPerDecade)
+	}
+	_, err = db.Conn.Exec("DELETE FROM moviecast WHERE filmid = ANY($1)", filmIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM films WHERE id = ANY($1)", filmIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM actors WHERE id = ANY($1)", actorIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestActorAnalytics(t *testing.T) {
+	var actorIDs, filmIDs []int
+	for _, name := range []string{"CareerActor", "CareerCostar"} {
+		id, err := addTestActor(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actorIDs = append(actorIDs, id)
+	}
+	// addTestActor sets the birth date to 2000-01-01.
+	ratings := []int{6, 8, 10}
+	for i, date := range []string{"2010-06-01", "2012-03-01", "2012-09-01"} {
+		var id int
+		err := db.Conn.QueryRow("INSERT INTO films (name, description, release_date, rating) VALUES ('CareerFilm', 'idk', $1, $2) RETURNING id",
+			date, ratings[i]).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filmIDs = append(filmIDs, id)
+	}
+	// An unrated film counts in the career but not in the average rating.
+	var unrated int
+	err := db.Conn.QueryRow("INSERT INTO films (name, description, release_date) VALUES ('CareerFilm', 'idk', '2012-12-01') RETURNING id").Scan(&unrated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filmIDs = append(filmIDs, unrated)
+	// A film with an unknown release date has no year and no age.
+	var undated int
+	err = db.Conn.QueryRow("INSERT INTO films (name, description, release_date) VALUES ('CareerFilm', 'idk', '0001-01-01') RETURNING id").Scan(&undated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filmIDs = append(filmIDs, undated)
+	for _, id := range filmIDs {
+		err = db.AddCredit(id, structs.Credit{ActorID: actorIDs[0]})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = db.AddCredit(filmIDs[1], structs.Credit{ActorID: actorIDs[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(handler http.HandlerFunc, target string, id string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", target, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetPathValue("id", id)
+		req.SetBasicAuth("compileboy", "1234")
+		rr := httptest.NewRecorder()
+		handlers.Wrap(handler)(rr, req)
+		return rr
+	}
+	rr := get(handlers.GetActorAnalytics, "/actors/analytics", strconv.Itoa(actorIDs[0]))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GetActorAnalytics returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var analytics structs.ActorAnalytics
+	err = json.NewDecoder(rr.Body).Decode(&analytics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analytics.Films != 5 || analytics.CareerYears != 2 || analytics.AverageRating != 8 ||
+		analytics.FirstRelease.Year() != 2010 || analytics.LastRelease.Month() != time.December {
+		t.Errorf("GetActorAnalytics returned wrong career: %+v", analytics)
+	}
+	if rating := analytics.Filmography[3].Rating; rating != nil {
+		t.Errorf("GetActorAnalytics returned rating %d for an unrated film", *rating)
+	}
+	if len(analytics.FilmsPerYear) != 2 || analytics.FilmsPerYear[1] != (structs.YearCount{Year: 2012, Count: 3}) {
+		t.Errorf("GetActorAnalytics returned wrong films per year: %+v", analytics.FilmsPerYear)
+	}
+	if age := analytics.Filmography[0].AgeAtRelease; age == nil || *age != 10 {
+		t.Errorf("GetActorAnalytics returned wrong age at release: %v", age)
+	}
+	if film := analytics.Filmography[4]; film.FilmID != undated || !film.ReleaseDate.IsZero() || film.AgeAtRelease != nil {
+		t.Errorf("GetActorAnalytics returned wrong film without release date: %+v", film)
+	}
+	// Actors without a birth date are stored with 0001-01-01.
+	_, err = db.Conn.Exec("UPDATE actors SET birth_date = '0001-01-01' WHERE id = $1", actorIDs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = get(handlers.GetActorAnalytics, "/actors/analytics", strconv.Itoa(actorIDs[1]))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GetActorAnalytics returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var costar structs.ActorAnalytics
+	err = json.NewDecoder(rr.Body).Decode(&costar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(costar.Filmography) != 1 || costar.Filmography[0].AgeAtRelease != nil {
+		t.Errorf("GetActorAnalytics returned an age for an actor without birth date: %+v", costar.Filmography)
+	}
+	if len(analytics.TopCostars) != 1 || analytics.TopCostars[0].ActorID != actorIDs[1] {
+		t.Errorf("GetActorAnalytics returned wrong co-stars: %+v", analytics.TopCostars)
+	}
+	rr = get(handlers.GetActorAnalytics, "/actors/analytics", "0")
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("GetActorAnalytics returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+	rr = get(handlers.GetActorLeaderboard, "/actors/leaderboard?min_films=3&limit=100000", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GetActorLeaderboard returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var leaderboard []structs.ActorRating
+	err = json.NewDecoder(rr.Body).Decode(&leaderboard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ranked, costarRanked bool
+	for _, actor := range leaderboard {
+		if actor.ActorID == actorIDs[0] {
+			ranked = actor.Films == 3 && actor.AverageRating == 8
+		}
+		costarRanked = costarRanked || actor.ActorID == actorIDs[1]
+	}
+	if !ranked || costarRanked {
+		t.Errorf("GetActorLeaderboard returned wrong leaderboard: %+v", leaderboard)
+	}
+	_, err = db.Conn.Exec("DELETE FROM moviecast WHERE filmid = ANY($1)", filmIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM films WHERE id = ANY($1)", filmIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM actors WHERE id = ANY($1)", actorIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImport(t *testing.T) {
+	post := func(query, contentType, body string) structs.ImportReport {
+		req, err := http.NewRequest("POST", "/import?"+query, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.SetBasicAuth("splatjov", "1234")
+		rr := httptest.NewRecorder()
+		handlers.Wrap(handlers.Import)(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Import returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		var report structs.ImportReport
+		err = json.NewDecoder(rr.Body).Decode(&report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+	count := func() int {
+		var n int
+		err := db.Conn.QueryRow(`SELECT (SELECT count(*) FROM films WHERE name = 'ImportFilm') +
+			(SELECT count(*) FROM actors WHERE name = 'ImportActor')`).Scan(&n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	csvBody := "type,name,release_date,rating,birth_date,film_name,film_release_date,actor_name,actor_birth_date,character\n" +
+		"film,ImportFilm,1887-01-01,7,,,,,,\n" +
+		"actor,ImportActor,,,1850-01-01,,,,,\n" +
+		"cast,,,,,ImportFilm,1887-01-01,ImportActor,1850-01-01,Hero\n"
+	report := post("dry_run=true", "text/csv", csvBody)
+	if !report.DryRun || report.Created != 2 || report.Linked != 1 || report.Failed != 0 || report.Results[2].Committed {
+		t.Errorf("Import dry run returned wrong report: %+v", report)
+	}
+	if count() != 0 {
+		t.Fatal("Import dry run committed rows")
+	}
+	// In a dry run later batches still find the records of earlier ones.
+	report = post("dry_run=true&batch_size=1", "text/csv", csvBody)
+	if report.Batches != 3 || report.BatchesCommitted != 0 || report.Created != 2 || report.Linked != 1 || report.Failed != 0 {
+		t.Errorf("Import dry run in batches returned wrong report: %+v", report)
+	}
+	if count() != 0 {
+		t.Fatal("Import dry run in batches committed rows")
+	}
+	// A failing row rolls back the whole import.
+	report = post("", "text/csv", csvBody+"cast,,,,,ImportFilm,1887-01-01,NoSuchActor,1850-01-01,\n")
+	if report.Failed != 1 || report.Results[3].Error != "actor not found" || report.Results[3].Line != 5 || report.BatchesCommitted != 0 {
+		t.Errorf("Import returned wrong report: %+v", report)
+	}
+	if count() != 0 {
+		t.Fatal("Import committed rows of a failed import")
+	}
+	report = post("", "text/csv", csvBody)
+	if report.Created != 2 || report.Linked != 1 || report.BatchesCommitted != 1 || !report.Results[0].Committed {
+		t.Errorf("Import returned wrong report: %+v", report)
+	}
+	filmID := report.Results[0].Id
+	// Importing again updates the matched film and role, one batch per row.
+	jsonBody := `[{"type": "film", "name": "ImportFilm", "release_date": "1887-01-01", "rating": 9},
+		{"type": "cast", "film_id": ` + strconv.Itoa(filmID) + `, "actor_name": "ImportActor", "actor_birth_date": "1850-01-01", "character": "Villain"},
+		{"type": "film", "name": "ImportFilm", "rating": 11}]`
+	report = post("batch_size=1", "application/json", jsonBody)
+	if report.Updated != 2 || report.Failed != 1 || report.Batches != 3 || report.BatchesCommitted != 2 || report.Results[0].Id != filmID {
+		t.Errorf("Import returned wrong report: %+v", report)
+	}
+	film, err := db.GetFilmByID(filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cast, err := db.CastByFilm([]int{filmID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if film.Rating != 9 || len(cast[filmID]) != 1 || cast[filmID][0].Character != "Villain" {
+		t.Errorf("Import did not update the film: %+v %+v", film, cast[filmID])
+	}
+	// An update with only id and name keeps the other fields.
+	report = post("", "application/json", `[{"type": "film", "id": `+strconv.Itoa(filmID)+`, "name": "ImportFilm"}]`)
+	if report.Updated != 1 || report.BatchesCommitted != 1 {
+		t.Errorf("Import returned wrong report: %+v", report)
+	}
+	film, err = db.GetFilmByID(filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if film.Rating != 9 || film.ReleaseDate.Year() != 1887 {
+		t.Errorf("Import cleared fields missing from the row: %+v", film)
+	}
+	_, err = db.Conn.Exec("DELETE FROM moviecast WHERE filmid = $1", filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM films WHERE id = $1", filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM actors WHERE name = 'ImportActor'")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	actorID, err := addTestActor("ExportCatalogActor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var filmID int
+	err = db.Conn.QueryRow("INSERT INTO films (name, description, release_date, rating) VALUES ('ExportCatalogFilm', 'idk', '1999-01-01', 7) RETURNING id").Scan(&filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AddCredit(filmID, structs.Credit{ActorID: actorID, Character: "Exporter"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	export := func(format string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/export?format="+format, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("splatjov", "1234")
+		rr := httptest.NewRecorder()
+		handlers.Wrap(handlers.Export)(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Export returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		return rr
+	}
+	rr := export("ndjson")
+	var film, credit bool
+	decoder := json.NewDecoder(rr.Body)
+	for decoder.More() {
+		var line struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		err = decoder.Decode(&line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		film = film || line.Type == "film" && strings.Contains(string(line.Data), `"name":"ExportCatalogFilm"`)
+		credit = credit || line.Type == "cast" && strings.Contains(string(line.Data), `"character":"Exporter"`)
+	}
+	if !film || !credit {
+		t.Errorf("Export NDJSON is missing the film or its cast")
+	}
+	rr = export("json")
+	var catalogue struct {
+		Films  []structs.FilmRecord `json:"films"`
+		Actors []structs.Person     `json:"actors"`
+		Cast   []structs.Credit     `json:"cast"`
+	}
+	err = json.NewDecoder(rr.Body).Decode(&catalogue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(catalogue.Films) == 0 || len(catalogue.Actors) == 0 || len(catalogue.Cast) == 0 {
+		t.Errorf("Export JSON is missing records: %d films, %d actors, %d cast links", len(catalogue.Films), len(catalogue.Actors), len(catalogue.Cast))
+	}
+	rr = export("zip")
+	archive, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, file := range archive.File {
+		files = append(files, file.Name)
+	}
+	if strings.Join(files, ",") != "films.csv,actors.csv,cast.csv" {
+		t.Errorf("Export zip has wrong files: %v", files)
+	}
+	_, err = db.Conn.Exec("DELETE FROM moviecast WHERE filmid = $1", filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM films WHERE id = $1", filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM actors WHERE id = $1", actorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImdbImport(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		imdb.TitlesFile: "tconst\ttitleType\tprimaryTitle\toriginalTitle\tisAdult\tstartYear\tendYear\truntimeMinutes\tgenres\n" +
+			"tt99999901\tmovie\tImdbTestFilm\tImdbTestFilm\t0\t1994\t\\N\t142\tDrama\n" +
+			"tt99999902\ttvSeries\tImdbTestSeries\tImdbTestSeries\t0\t1994\t\\N\t\\N\tDrama\n" +
+			"tt99999903\tmovie\tImdbTestUnpopular\tImdbTestUnpopular\t0\t1994\t\\N\t90\tDrama\n",
+		imdb.RatingsFile: "tconst\taverageRating\tnumVotes\n" +
+			"tt99999901\t9.3\t2000\ntt99999902\t8.0\t2000\ntt99999903\t5.0\t10\n",
+		imdb.PrincipalsFile: "tconst\tordering\tnconst\tcategory\tjob\tcharacters\n" +
+			"tt99999901\t1\tnm99999901\tactor\t\\N\t[\"Andy Dufresne\"]\n" +
+			"tt99999901\t2\tnm99999902\tdirector\t\\N\t\\N\n" +
+			"tt99999902\t1\tnm99999903\tactress\t\\N\t[\"Someone\"]\n",
+		imdb.NamesFile: "nconst\tprimaryName\tbirthYear\tdeathYear\tprimaryProfession\tknownForTitles\n" +
+			"nm99999901\tImdbTestActor\t1958\t\\N\tactor\ttt99999901\n" +
+			"nm99999902\tImdbTestDirector\t1959\t\\N\tdirector\ttt99999901\n" +
+			"nm99999903\tImdbTestActress\t1960\t\\N\tactress\ttt99999902\n",
+	}
+	for name, content := range files {
+		var b bytes.Buffer
+		gz := gzip.NewWriter(&b)
+		_, err := gz.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = gz.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(dir, name), b.Bytes(), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := db.ResetImdbImport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := imdb.Options{Dir: dir, TitleTypes: []string{"movie"}, MinVotes: 100, BatchSize: 1}
+	logf := func(format string, args ...interface{}) { t.Logf(format, args...) }
+	// The second run finds every stage done, the third updates after a restart.
+	for run := 0; run < 3; run++ {
+		if run == 2 {
+			err = db.ResetImdbImport()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err = imdb.Run(options, logf)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var films, actors, links, rating, runtime int
+	var character string
+	err = db.Conn.QueryRow(`SELECT (SELECT count(*) FROM films WHERE imdb_id LIKE 'tt999999%'),
+		(SELECT count(*) FROM actors WHERE imdb_id LIKE 'nm999999%'),
+		(SELECT count(*) FROM moviecast mc JOIN films f ON f.id = mc.filmid WHERE f.imdb_id LIKE 'tt999999%')`).Scan(&films, &actors, &links)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if films != 1 || actors != 1 || links != 1 {
+		t.Errorf("imdb.Run imported %d films, %d actors and %d cast links, want 1 each", films, actors, links)
+	}
+	err = db.Conn.QueryRow(`SELECT f.rating, f.runtime, mc.character FROM films f JOIN moviecast mc ON mc.filmid = f.id
+		WHERE f.imdb_id = 'tt99999901'`).Scan(&rating, &runtime, &character)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rating != 9 || runtime != 142 || character != "Andy Dufresne" {
+		t.Errorf("imdb.Run imported wrong film: rating %d, runtime %d, character %q", rating, runtime, character)
+	}
+	// Other filters need a restart.
+	err = imdb.Run(imdb.Options{Dir: dir, TitleTypes: []string{"movie"}}, logf)
+	if !errors.Is(err, db.ErrImportOptions) {
+		t.Errorf("imdb.Run with other filters returned %v, want %v", err, db.ErrImportOptions)
+	}
+	_, err = db.Conn.Exec("DELETE FROM moviecast WHERE filmid IN (SELECT id FROM films WHERE imdb_id LIKE 'tt999999%')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM films WHERE imdb_id LIKE 'tt999999%'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM actors WHERE imdb_id LIKE 'nm999999%'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.ResetImdbImport()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLibraryScan(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"ScanTestFilm (1901).mkv":               "",
+		"nfo/ScanTestNfoFilm.mp4":               "",
+		"nfo/ScanTestNfoFilm.nfo":               `<movie><title>ScanTestNfoFilm</title><year>1902</year><plot>From an NFO file</plot><rating>7.6</rating><actor><name>ScanTestActor</name><role>Narrator</role></actor></movie>`,
+		".hidden/ScanTestHiddenFilm (1903).mkv": "",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	dirs := library.Dirs
+	library.Dirs = []string{dir}
+	defer func() { library.Dirs = dirs }()
+	scan := func() structs.ScanReport {
+		req, err := http.NewRequest("POST", "/scan_library", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("splatjov", "1234")
+		rr := httptest.NewRecorder()
+		handlers.Wrap(handlers.ScanLibrary)(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("ScanLibrary returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		var report structs.ScanReport
+		err = json.NewDecoder(rr.Body).Decode(&report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+
+	report := scan()
+	if report.Files != 2 || report.Created != 2 || report.Failed != 0 {
+		t.Errorf("ScanLibrary first scan returned %+v, want 2 files created", report)
+	}
+	var description, character string
+	var rating int
+	var year time.Time
+	err := db.Conn.QueryRow(`SELECT f.description, f.rating, f.release_date, mc.character FROM films f
+		JOIN moviecast mc ON mc.filmid = f.id JOIN actors a ON a.id = mc.actorid
+		WHERE f.name = 'ScanTestNfoFilm' AND a.name = 'ScanTestActor'`).Scan(&description, &rating, &year, &character)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if description != "From an NFO file" || rating != 8 || year.Year() != 1902 || character != "Narrator" {
+		t.Errorf("ScanLibrary read wrong NFO film: %q, rating %d, year %d, character %q", description, rating, year.Year(), character)
+	}
+	err = db.Conn.QueryRow("SELECT count(*) FROM films WHERE name = 'ScanTestFilm' AND release_date = '1901-01-01'").Scan(&rating)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rating != 1 {
+		t.Errorf("ScanLibrary created %d films from the file name, want 1", rating)
+	}
+
+	report = scan()
+	if report.Files != 2 || report.Unchanged != 2 || report.Created != 0 {
+		t.Errorf("ScanLibrary rescan returned %+v, want 2 unchanged files", report)
+	}
+	// A new copy of a known film is matched, a deleted file is removed.
+	err = os.WriteFile(filepath.Join(dir, "ScanTestFilm.1901.1080p.mp4"), nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Remove(filepath.Join(dir, "nfo", "ScanTestNfoFilm.mp4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report = scan()
+	if report.Files != 2 || report.Unchanged != 1 || report.Matched != 1 || report.Removed != 1 {
+		t.Errorf("ScanLibrary scan after changes returned %+v, want 1 unchanged, 1 matched and 1 removed", report)
+	}
+
+	_, err = db.Conn.Exec("DELETE FROM moviecast WHERE filmid IN (SELECT id FROM films WHERE name LIKE 'ScanTest%')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM films WHERE name LIKE 'ScanTest%'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Conn.Exec("DELETE FROM actors WHERE name = 'ScanTestActor'")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLetterboxd(t *testing.T) {
+	var filmID int
+	err := db.Conn.QueryRow("INSERT INTO films (name, description, release_date, rating) VALUES ('LbxdTestFilm', 'idk', '1999-03-31', 7) RETURNING id").Scan(&filmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var longID int
+	err = db.Conn.QueryRow("INSERT INTO films (name, description, release_date, rating) VALUES ('LbxdTestFilm Of Thirty Letters', 'idk', '2005-06-01', 6) RETURNING id").Scan(&longID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_, err := db.Conn.Exec("DELETE FROM films WHERE id = ANY($1)", []int{filmID, longID})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	var export bytes.Buffer
+	archive := zip.NewWriter(&export)
+	files := map[string]string{
+		"ratings.csv": "Date,Name,Year,Letterboxd URI,Rating\n" +
+			"2024-01-05,lbxdtestfilm,1999,https://boxd.it/abc,4.5\n" +
+			"2024-01-05,LbxdTestMissing,2001,https://boxd.it/def,3\n",
+		"diary.csv": "Date,Name,Year,Letterboxd URI,Rating,Rewatch,Tags,Watched Date\n" +
+			"2024-01-05,LbxdTestFilm,1999,https://boxd.it/ghi,4.5,Yes,,2024-01-04\n",
+		"watchlist.csv": "Date,Name,Year,Letterboxd URI\n" +
+			"2023-12-01,LbxdTestFilm,,https://boxd.it/abc\n" +
+			"2023-12-02,LbxdTestFilm Of Thirty Letters And More,2005,\n",
+		"deleted/diary.csv": "Date,Name,Year,Letterboxd URI,Rating,Rewatch,Tags,Watched Date\n" +
+			"2020-01-01,LbxdTestFilm,1999,,1,,,2020-01-01\n",
+	}
+	for name, content := range files {
+		f, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = archive.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	importLetterboxd := func(query string) structs.LetterboxdReport {
+		req, err := http.NewRequest("POST", "/import_letterboxd"+query, bytes.NewReader(export.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("compileboy", "1234")
+		rr := httptest.NewRecorder()
+		handlers.Wrap(handlers.ImportLetterboxd)(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("ImportLetterboxd returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body)
+		}
+		var report structs.LetterboxdReport
+		err = json.NewDecoder(rr.Body).Decode(&report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+
+	report := importLetterboxd("?dry_run=true")
+	if !report.DryRun || report.Ratings != 1 {
+		t.Errorf("ImportLetterboxd dry run returned %+v, want one rating", report)
+	}
+	var rated int
+	err = db.Conn.QueryRow("SELECT count(*) FROM userratings WHERE filmid = $1", filmID).Scan(&rated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rated != 0 {
+		t.Errorf("ImportLetterboxd dry run stored %d ratings", rated)
+	}
+	report = importLetterboxd("")
+	if report.Rows != 5 || report.Ratings != 1 || report.Diary != 1 || report.Watchlist != 2 || report.Skipped != 0 {
+		t.Errorf("ImportLetterboxd returned %+v, want one rating, diary entry and two watchlist films", report)
+	}
+	if len(report.Unmatched) != 1 || report.Unmatched[0].Name != "LbxdTestMissing" || report.Unmatched[0].Line != 3 {
+		t.Errorf("ImportLetterboxd returned unmatched %+v, want LbxdTestMissing on line 3", report.Unmatched)
+	}
+	report = importLetterboxd("")
+	if report.Ratings != 1 || report.Diary != 0 || report.Watchlist != 0 || report.Skipped != 3 {
+		t.Errorf("ImportLetterboxd again returned %+v, want the diary entry and watchlist films skipped", report)
+	}
+
+	req, err := http.NewRequest("GET", "/export_letterboxd", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("compileboy", "1234")
+	rr := httptest.NewRecorder()
+	handlers.Wrap(handlers.ExportLetterboxd)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ExportLetterboxd returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	exported, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"ratings.csv":   "2024-01-05,LbxdTestFilm,1999,,4.5\n",
+		"diary.csv":     "2024-01-05,LbxdTestFilm,1999,,4.5,Yes,,2024-01-04\n",
+		"watchlist.csv": "2023-12-01,LbxdTestFilm,1999,\n",
+	}
+	for name, line := range want {
+		f, err := exported.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		_, err = b.ReadFrom(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("ExportLetterboxd wrote %s without %q:\n%s", name, line, b.String())
+		}
+	}
+}
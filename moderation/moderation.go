@@ -0,0 +1,99 @@
+package moderation
+
+import (
+	"FilmCollection/structs"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Review statuses.
+const (
+	Pending   = "pending"
+	Published = "published"
+	Rejected  = "rejected"
+	Hidden    = "hidden"
+)
+
+// Modes of the moderation policy, set with the REVIEW_MODERATION variable.
+const (
+	// AutoPublish publishes every review right away.
+	AutoPublish = "auto"
+	// HoldAll keeps every review pending until an admin approves it.
+	HoldAll = "hold"
+	// HoldFlagged publishes reviews unless one of the filters flags them.
+	HoldFlagged = "filter"
+)
+
+// Filter inspects a review and tells why it should be held for approval.
+type Filter interface {
+	Flag(review structs.Review) (reason string, flagged bool)
+}
+
+// Policy decides the initial status of submitted reviews.
+type Policy struct {
+	Mode    string
+	Filters []Filter
+}
+
+// Current is the policy used by the handlers, read from the environment.
+var Current Policy
+
+func init() {
+	Current = Policy{Mode: os.Getenv("REVIEW_MODERATION")}
+	switch Current.Mode {
+	case AutoPublish, HoldAll, HoldFlagged:
+	case "":
+		Current.Mode = AutoPublish
+	default:
+		slog.Error("Unknown review moderation mode, holding all reviews: ", "mode", Current.Mode)
+		Current.Mode = HoldAll
+	}
+	if words := os.Getenv("REVIEW_BANNED_WORDS"); words != "" {
+		Current.Filters = append(Current.Filters, NewBannedWords(strings.Split(words, ",")))
+	}
+}
+
+// Status returns the status a newly submitted or edited review gets and,
+// for held reviews, the reason.
+func (p Policy) Status(review structs.Review) (string, string) {
+	switch p.Mode {
+	case AutoPublish:
+		return Published, ""
+	case HoldFlagged:
+		for _, filter := range p.Filters {
+			if reason, flagged := filter.Flag(review); flagged {
+				return Pending, reason
+			}
+		}
+		return Published, ""
+	default:
+		return Pending, "awaiting approval"
+	}
+}
+
+// BannedWords flags reviews containing any of its words, ignoring case.
+type BannedWords struct {
+	words []string
+}
+
+func NewBannedWords(words []string) BannedWords {
+	var filter BannedWords
+	for _, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" {
+			filter.words = append(filter.words, word)
+		}
+	}
+	return filter
+}
+
+func (b BannedWords) Flag(review structs.Review) (string, bool) {
+	body := strings.ToLower(review.Body)
+	for _, word := range b.words {
+		if strings.Contains(body, word) {
+			return "contains banned word " + `"` + word + `"`, true
+		}
+	}
+	return "", false
+}
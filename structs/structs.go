@@ -8,26 +8,110 @@ type Date struct {
 	time.Time
 }
 
-func (d *Date) UnmarshalJSON(data []byte) error {
+// ParseDate accepts the same layouts as Date.UnmarshalJSON plus a bare ISO date.
+func ParseDate(s string) (Date, error) {
 	var err error
+	for _, layout := range []string{"02.01.2006", "2006-01-02T00:00:00Z", "2006-01-02"} {
+		var t time.Time
+		t, err = time.Parse(layout, s)
+		if err == nil {
+			return Date{Time: t}, nil
+		}
+	}
+	return Date{}, err
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
 	data = data[1 : len(data)-1]
-	d.Time, err = time.Parse("02.01.2006", string(data))
+	parsed, err := ParseDate(string(data))
 	if err != nil {
-		d.Time, err = time.Parse("2006-01-02T00:00:00Z", string(data))
-		if err != nil {
-			return err
-		}
-		err = nil
+		return err
 	}
-	return err
+	d.Time = parsed.Time
+	return nil
 }
 
-type Actor struct {
+// CreditTypes lists the accepted values of Credit.CreditType.
+var CreditTypes = []string{"lead", "supporting", "cameo", "voice"}
+
+// FilmRecord is a film without its relations, as written by the export.
+type FilmRecord struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Rating      int    `json:"rating"`
+	ReleaseDate Date   `json:"release_date"`
+	Runtime     int    `json:"runtime"`
+}
+
+// Credit is a single moviecast link: who played whom in which film.
+type Credit struct {
+	FilmID     int    `json:"film_id"`
+	ActorID    int    `json:"actor_id"`
+	Character  string `json:"character,omitempty"`
+	Billing    int    `json:"billing,omitempty"`
+	CreditType string `json:"credit_type,omitempty"`
+}
+
+// Departments lists the accepted values of CrewCredit.Department.
+var Departments = []string{"directing", "writing", "production", "music", "camera", "editing", "sound", "art"}
+
+// CrewCredit links a person to a film in a non-acting department.
+type CrewCredit struct {
+	FilmID     int    `json:"film_id"`
+	PersonID   int    `json:"person_id"`
+	Department string `json:"department"`
+	Job        string `json:"job,omitempty"`
+}
+
+// Person is anyone who worked on a film. Actors are people with acting credits.
+type Person struct {
 	Id        int    `json:"id"`
 	Name      string `json:"name"`
 	Gender    string `json:"gender"`
 	BirthDate Date   `json:"birth_date"`
-	Films     []int  `json:"films"`
+}
+
+// PersonCredit is one line of a person page: a film and what they did on it.
+// Role holds the character for acting credits and the job for crew credits.
+type PersonCredit struct {
+	FilmID      int    `json:"film_id"`
+	FilmName    string `json:"film_name"`
+	ReleaseDate Date   `json:"release_date"`
+	Role        string `json:"role,omitempty"`
+}
+
+// PersonPage lists all credits of a person grouped by department, "acting" included.
+type PersonPage struct {
+	Person
+	Credits map[string][]PersonCredit `json:"credits"`
+}
+
+type Actor struct {
+	Person
+	Films []int    `json:"films"`
+	Roles []Credit `json:"roles"`
+}
+
+// Genre is an entry of the admin-managed genre taxonomy. ParentID is 0 for
+// top-level genres.
+type Genre struct {
+	Id       int    `json:"id"`
+	Name     string `json:"name,omitempty"`
+	ParentID int    `json:"parent_id,omitempty"`
+}
+
+// Tag is a free-form curator label. Films is the number of tagged films.
+type Tag struct {
+	Id    int    `json:"id"`
+	Name  string `json:"name"`
+	Films int    `json:"films"`
+}
+
+// TagMerge is the request body of merge_tags: tags in From are folded into Into.
+type TagMerge struct {
+	From []int `json:"from"`
+	Into int   `json:"into"`
 }
 
 type Film struct {
@@ -36,5 +120,414 @@ type Film struct {
 	Description string `json:"description"`
 	Rating      int    `json:"rating"`
 	ReleaseDate Date   `json:"release_date"`
-	Actors      []int  `json:"actors"`
+	// Runtime is the length in minutes, 0 when unknown.
+	Runtime int          `json:"runtime"`
+	Actors  []int        `json:"actors"`
+	Cast    []Credit     `json:"cast"`
+	Crew    []CrewCredit `json:"crew"`
+	Genres  []Genre      `json:"genres"`
+	Tags    []string     `json:"tags"`
+	// CommunityRating is the average of the users' ratings, next to the
+	// editorial Rating. Votes is the number of users who rated the film.
+	CommunityRating float64 `json:"community_rating"`
+	Votes           int     `json:"votes"`
+	// OnWatchlist tells whether the film is on the caller's watchlist.
+	OnWatchlist bool `json:"on_watchlist"`
+}
+
+// UserRating is a user's own 1-10 score of a film.
+type UserRating struct {
+	FilmID  int       `json:"film_id"`
+	UserID  int       `json:"user_id"`
+	Rating  int       `json:"rating"`
+	RatedAt time.Time `json:"rated_at"`
+}
+
+// Review is a user's written opinion of a film. Rating is the author's
+// UserRating of the same film, 0 if they didn't rate it.
+type Review struct {
+	Id        int       `json:"id"`
+	FilmID    int       `json:"film_id"`
+	UserID    int       `json:"user_id"`
+	Login     string    `json:"login"`
+	Body      string    `json:"body"`
+	Rating    int       `json:"rating,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Status is one of pending, published, rejected or hidden. Only
+	// published reviews are visible to other users.
+	Status         string `json:"status"`
+	ModerationNote string `json:"moderation_note,omitempty"`
+}
+
+// List is a user-owned ordered collection of films. Lists are private
+// unless made public or shared with a link containing ShareToken. Every
+// user also has one watchlist, created on first use.
+type List struct {
+	Id          int        `json:"id"`
+	UserID      int        `json:"user_id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Public      bool       `json:"public"`
+	ShareToken  string     `json:"share_token,omitempty"`
+	Watchlist   bool       `json:"watchlist"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Items       []ListItem `json:"items"`
+}
+
+// ListUpdate is the request body of update_list. Fields left out keep their
+// current values.
+type ListUpdate struct {
+	Id          int     `json:"id"`
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Public      *bool   `json:"public"`
+}
+
+// ListItem is a film on a list. Position starts at 1.
+type ListItem struct {
+	ListID   int       `json:"list_id"`
+	FilmID   int       `json:"film_id"`
+	FilmName string    `json:"film_name"`
+	Position int       `json:"position"`
+	Note     string    `json:"note"`
+	AddedAt  time.Time `json:"added_at"`
+}
+
+// ListOrder is the request body of reorder_list: every film of the list in
+// its new order.
+type ListOrder struct {
+	ListID  int   `json:"list_id"`
+	FilmIDs []int `json:"film_ids"`
+}
+
+// DiaryEntry records that a user watched a film on a day. Rating is the
+// optional score given that day, 0 when absent. It is kept apart from the
+// film's editorial rating and from the user's UserRating.
+type DiaryEntry struct {
+	Id        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	FilmID    int       `json:"film_id"`
+	FilmName  string    `json:"film_name"`
+	WatchedOn Date      `json:"watched_on"`
+	Rating    int       `json:"rating,omitempty"`
+	Notes     string    `json:"notes"`
+	Rewatch   bool      `json:"rewatch"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// YearReview summarizes a user's diary for one calendar year.
+type YearReview struct {
+	Year int `json:"year"`
+	// FilmsWatched counts diary entries, UniqueFilms distinct films.
+	FilmsWatched int `json:"films_watched"`
+	UniqueFilms  int `json:"unique_films"`
+	// A watch is a rewatch if it is flagged so or the film was logged before.
+	FirstWatches int `json:"first_watches"`
+	Rewatches    int `json:"rewatches"`
+	// TotalRuntime is in minutes. Watches of films with unknown runtime are
+	// counted in UnknownRuntime instead.
+	TotalRuntime       int           `json:"total_runtime"`
+	UnknownRuntime     int           `json:"unknown_runtime"`
+	AverageRating      float64       `json:"average_rating"`
+	RatingDistribution []RatingCount `json:"rating_distribution"`
+	Months             []MonthCount  `json:"months"`
+	BusiestMonth       int           `json:"busiest_month"`
+	TopActors          []ActorCount  `json:"top_actors"`
+}
+
+// RatingCount is the number of diary entries or films rated Rating.
+type RatingCount struct {
+	Rating int `json:"rating"`
+	Count  int `json:"count"`
+}
+
+// MonthCount is the number of watches in a month, 1 is January.
+type MonthCount struct {
+	Month int `json:"month"`
+	Count int `json:"count"`
+}
+
+// ActorCount is the number of watches of films an actor appears in, or the
+// number of films for catalogue statistics.
+type ActorCount struct {
+	ActorID int    `json:"actor_id"`
+	Name    string `json:"name"`
+	Count   int    `json:"count"`
+}
+
+// CatalogueStats describes the films of the catalogue and their cast.
+type CatalogueStats struct {
+	Films         int     `json:"films"`
+	Actors        int     `json:"actors"`
+	AverageRating float64 `json:"average_rating"`
+	// RatingHistogram holds every rating from 0 to 10.
+	RatingHistogram []RatingCount `json:"rating_histogram"`
+	// FilmsPerYear and FilmsPerDecade only hold years with films. A decade
+	// is named by its first year, e.g. 1990.
+	FilmsPerYear       []YearCount     `json:"films_per_year"`
+	FilmsPerDecade     []YearCount     `json:"films_per_decade"`
+	UnknownReleaseDate int             `json:"unknown_release_date"`
+	CastSizes          []CastSizeCount `json:"cast_sizes"`
+	Genders            []GenderCount   `json:"genders"`
+	TopActors          []ActorCount    `json:"top_actors"`
+}
+
+// YearCount is the number of films released in a year or decade.
+type YearCount struct {
+	Year  int `json:"year"`
+	Count int `json:"count"`
+}
+
+// CastSizeCount is the number of films with CastSize actors.
+type CastSizeCount struct {
+	CastSize int `json:"cast_size"`
+	Films    int `json:"films"`
+}
+
+// GenderCount is the number of actors of a gender, "unknown" when not set.
+type GenderCount struct {
+	Gender string `json:"gender"`
+	Count  int    `json:"count"`
+}
+
+// ActorAnalytics describes the career of an actor. FirstRelease and
+// LastRelease are zero when none of the films has a release date.
+type ActorAnalytics struct {
+	Actor         Person       `json:"actor"`
+	Films         int          `json:"films"`
+	FirstRelease  Date         `json:"first_release"`
+	LastRelease   Date         `json:"last_release"`
+	CareerYears   int          `json:"career_years"`
+	AverageRating float64      `json:"average_rating"`
+	FilmsPerYear  []YearCount  `json:"films_per_year"`
+	Filmography   []CareerFilm `json:"filmography"`
+	TopCostars    []Costar     `json:"top_costars"`
+}
+
+// CareerFilm is a film of an actor. Rating is null for unrated films;
+// AgeAtRelease is missing when the birth or release date is unknown.
+type CareerFilm struct {
+	FilmID       int    `json:"film_id"`
+	Name         string `json:"name"`
+	ReleaseDate  Date   `json:"release_date"`
+	Rating       *int   `json:"rating"`
+	AgeAtRelease *int   `json:"age_at_release,omitempty"`
+}
+
+// ActorRating is an entry of the actor leaderboard.
+type ActorRating struct {
+	ActorID       int     `json:"actor_id"`
+	Name          string  `json:"name"`
+	Films         int     `json:"films"`
+	AverageRating float64 `json:"average_rating"`
+}
+
+// ImportTypes lists the accepted values of ImportRow.Type.
+var ImportTypes = []string{"film", "actor", "cast"}
+
+// ImportRow is one record of a bulk import. Films and actors are updated
+// when Id is set or an existing record has the same name and release or
+// birth date, and created otherwise; updates keep the stored values of
+// the fields the row leaves empty or zero, like UpdateFilm. Cast rows
+// link a film and an actor found the same way through the Film and Actor
+// fields.
+type ImportRow struct {
+	// Line is the line of the CSV file or the index in the JSON array.
+	Line        int    `json:"-"`
+	Type        string `json:"type"`
+	Id          int    `json:"id,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	ReleaseDate Date   `json:"release_date"`
+	Rating      int    `json:"rating,omitempty"`
+	Runtime     int    `json:"runtime,omitempty"`
+	Gender      string `json:"gender,omitempty"`
+	BirthDate   Date   `json:"birth_date"`
+
+	FilmID          int    `json:"film_id,omitempty"`
+	FilmName        string `json:"film_name,omitempty"`
+	FilmReleaseDate Date   `json:"film_release_date"`
+	ActorID         int    `json:"actor_id,omitempty"`
+	ActorName       string `json:"actor_name,omitempty"`
+	ActorBirthDate  Date   `json:"actor_birth_date"`
+	Character       string `json:"character,omitempty"`
+	Billing         int    `json:"billing,omitempty"`
+	CreditType      string `json:"credit_type,omitempty"`
+
+	// ParseError is set when the row could not be read.
+	ParseError string `json:"-"`
+}
+
+// ImportResult is what happened, or would happen in a dry run, to a row.
+// Action is "create", "update", "link" or "error". Id is the film or actor
+// id; cast rows set FilmID and ActorID instead.
+type ImportResult struct {
+	Line      int    `json:"line"`
+	Type      string `json:"type"`
+	Action    string `json:"action"`
+	Id        int    `json:"id,omitempty"`
+	FilmID    int    `json:"film_id,omitempty"`
+	ActorID   int    `json:"actor_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Committed bool   `json:"committed"`
+}
+
+// ImportReport summarizes a bulk import. Batches counts the transactions;
+// a batch with a failed row is rolled back as a whole. The counts include
+// rows that were rolled back, Committed of the results tells them apart.
+type ImportReport struct {
+	DryRun           bool           `json:"dry_run"`
+	Rows             int            `json:"rows"`
+	Created          int            `json:"created"`
+	Updated          int            `json:"updated"`
+	Linked           int            `json:"linked"`
+	Failed           int            `json:"failed"`
+	Batches          int            `json:"batches"`
+	BatchesCommitted int            `json:"batches_committed"`
+	Results          []ImportResult `json:"results"`
+}
+
+// LetterboxdFiles lists the CSV files of a Letterboxd export that are
+// imported and exported, without their .csv extension.
+var LetterboxdFiles = []string{"ratings", "diary", "watchlist"}
+
+// LetterboxdRow is one line of ratings.csv, diary.csv or watchlist.csv of a
+// Letterboxd export. Date is the day the line was logged; Rating is on our
+// 1-10 scale, Letterboxd's half stars doubled, and 0 when absent. Year is 0
+// when unknown.
+type LetterboxdRow struct {
+	File        string
+	Line        int
+	Date        time.Time
+	Name        string
+	Year        int
+	URI         string
+	Rating      int
+	Rewatch     bool
+	Tags        string
+	WatchedDate time.Time
+	// ParseError is set when the line could not be read.
+	ParseError string
+}
+
+// LetterboxdUnmatched is a line of a Letterboxd import that was not
+// imported, with the reason.
+type LetterboxdUnmatched struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Name   string `json:"name"`
+	Year   int    `json:"year,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// LetterboxdReport summarizes a Letterboxd import. Ratings, Diary and
+// Watchlist count the imported lines; Skipped counts diary entries and
+// watchlist films the user already had.
+type LetterboxdReport struct {
+	DryRun    bool                  `json:"dry_run"`
+	Rows      int                   `json:"rows"`
+	Ratings   int                   `json:"ratings"`
+	Diary     int                   `json:"diary"`
+	Watchlist int                   `json:"watchlist"`
+	Skipped   int                   `json:"skipped"`
+	Unmatched []LetterboxdUnmatched `json:"unmatched"`
+}
+
+// ScanReport summarizes a run of the media library scanner. Unchanged
+// files were scanned before with the same size and modification time;
+// Removed counts recorded files that are gone.
+type ScanReport struct {
+	Files     int         `json:"files"`
+	Unchanged int         `json:"unchanged"`
+	Matched   int         `json:"matched"`
+	Created   int         `json:"created"`
+	Removed   int         `json:"removed"`
+	Failed    int         `json:"failed"`
+	Errors    []ScanError `json:"errors"`
+}
+
+// ScanError is a file or directory the scanner could not handle.
+type ScanError struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// SimilarFilm is a film recommended for another one. Reasons explain the
+// score, e.g. "3 shared actors".
+type SimilarFilm struct {
+	Film    Film     `json:"film"`
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+// Recommendation is a film suggested to a user from their taste profile.
+// Reasons name the actors, genres and tags that contributed most.
+type Recommendation struct {
+	Film    Film     `json:"film"`
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+// Ref names an actor or a film.
+type Ref struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// PathLink says that two actors appeared in the same film.
+type PathLink struct {
+	From Ref `json:"from"`
+	Film Ref `json:"film"`
+	To   Ref `json:"to"`
+}
+
+// ActorPath is a shortest chain of co-star links between two actors.
+// Degrees is the number of links.
+type ActorPath struct {
+	Degrees int        `json:"degrees"`
+	Links   []PathLink `json:"links"`
+}
+
+// Costar is an actor who appeared in films together with another one.
+type Costar struct {
+	ActorID     int    `json:"actor_id"`
+	Name        string `json:"name"`
+	SharedFilms int    `json:"shared_films"`
+	Films       []int  `json:"films"`
+}
+
+// Graph is the bipartite actor-film network built from moviecast.
+type Graph struct {
+	Actors []Person    `json:"actors"`
+	Films  []Film      `json:"films"`
+	Edges  []GraphEdge `json:"edges"`
+}
+
+// GraphEdge links an actor to a film they appeared in.
+type GraphEdge struct {
+	ActorID    int    `json:"actor_id"`
+	FilmID     int    `json:"film_id"`
+	Character  string `json:"character,omitempty"`
+	CreditType string `json:"credit_type,omitempty"`
+}
+
+// ReviewReport is a user's complaint about someone else's review.
+type ReviewReport struct {
+	Id        int       `json:"id"`
+	ReviewID  int       `json:"review_id"`
+	UserID    int       `json:"user_id"`
+	Login     string    `json:"login"`
+	Reason    string    `json:"reason"`
+	Resolved  bool      `json:"resolved"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ModerationAction is the request body of moderate_review. Action is one of
+// approve, reject or hide.
+type ModerationAction struct {
+	ReviewID int    `json:"review_id"`
+	Action   string `json:"action"`
+	Note     string `json:"note"`
 }